@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/formats"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/servercontext"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+// Blocks listed in a dry run summary before the rest are grouped together
+const HISTOGRAM_LIMIT = 16
+
+type converter struct {
+	output  io.Writer
+	formats []formats.Format
+	remap   formats.Remap
+	dryRun  bool
+	force   bool
+}
+
+func (converter *converter) summarize(level *world.Level) {
+	width, height, length := level.Size()
+	spawn := level.Spawn()
+	fmt.Fprintf(converter.output, "  Size %dx%dx%d, spawn %.1f,%.1f,%.1f\n", width, height, length, spawn.X, spawn.Y, spawn.Z)
+	histogram := formats.Histogram(level)
+	total := int(width) * int(height) * int(length)
+	for i, entry := range histogram {
+		if i == HISTOGRAM_LIMIT {
+			other := 0
+			for _, rest := range histogram[i:] {
+				other += rest.Count
+			}
+			fmt.Fprintf(converter.output, "  %10d  %5.1f%%  %d other blocks\n", other, float64(other)*100/float64(total), len(histogram)-i)
+			break
+		}
+		fmt.Fprintf(converter.output, "  %10d  %5.1f%%  %s\n", entry.Count, float64(entry.Count)*100/float64(total), formats.BlockName(entry.Block))
+	}
+}
+
+func (converter *converter) convert(input, output string) error {
+	inputFormat, err := formats.FormatFor(converter.formats, input)
+	if err != nil {
+		return err
+	}
+	var outputFormat formats.Format
+	if !converter.dryRun {
+		if outputFormat, err = formats.FormatFor(converter.formats, output); err != nil {
+			return err
+		}
+		if outputFormat.Save == nil {
+			return cerror.NewErrorf(formats.FORMAT_READ_ONLY, "%s levels can only be imported", outputFormat.Name)
+		}
+	}
+	fmt.Fprintf(converter.output, "%s (%s)\n", input, inputFormat.Name)
+	level, report, err := inputFormat.Load(input)
+	if err != nil {
+		return err
+	}
+	for _, warning := range report.Warnings() {
+		fmt.Fprintf(converter.output, "  Warning: %s\n", warning)
+	}
+	changed, err := converter.remap.Apply(level)
+	if err != nil {
+		return err
+	}
+	if changed > 0 {
+		fmt.Fprintf(converter.output, "  Remapped %d blocks\n", changed)
+	}
+	problems := formats.Validate(level)
+	for _, problem := range problems {
+		fmt.Fprintf(converter.output, "  Invalid: %s\n", problem)
+	}
+	if converter.dryRun {
+		converter.summarize(level)
+		return nil
+	}
+	if len(problems) > 0 && !converter.force {
+		return cerror.NewErrorf(formats.FORMAT_INVALID_LEVEL, "Level is invalid, use -force to save it anyway")
+	}
+	if zones := len(level.Behaviors().Zones); zones > 0 {
+		fmt.Fprintf(converter.output, "  Warning: %d zones can't be stored in %s files and were dropped\n", zones, outputFormat.Name)
+	}
+	if err := outputFormat.Save(level, output); err != nil {
+		return err
+	}
+	fmt.Fprintf(converter.output, "  Saved %s\n", output)
+	return nil
+}
+
+// Pairs each level in the input with where it should be written. Directories are converted file by file, skipping unknown formats
+func (converter *converter) jobs(input, output, extension string) ([][2]string, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+	outputPath := func(path string) string {
+		name := filepath.Base(path)
+		return filepath.Join(output, strings.TrimSuffix(name, filepath.Ext(name))+extension)
+	}
+	if !info.IsDir() {
+		if outputInfo, err := os.Stat(output); err == nil && outputInfo.IsDir() {
+			return [][2]string{{input, outputPath(input)}}, nil
+		}
+		return [][2]string{{input, output}}, nil
+	}
+	entries, err := os.ReadDir(input)
+	if err != nil {
+		return nil, err
+	}
+	var jobs [][2]string
+	for _, entry := range entries {
+		path := filepath.Join(input, entry.Name())
+		if entry.IsDir() {
+			continue
+		}
+		if _, err := formats.FormatFor(converter.formats, path); err != nil {
+			continue
+		}
+		jobs = append(jobs, [2]string{path, outputPath(path)})
+	}
+	if !converter.dryRun {
+		if err := os.MkdirAll(output, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+// Converts levels between formats without starting the server. Returns the exit status
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "validate and summarize levels without writing anything")
+	force := flags.Bool("force", false, "save levels even if they failed validation")
+	remapPath := flags.String("remap", "", "JSON table of blocks to replace, such as {\"sponge\": \"air\"}")
+	palettePath := flags.String("palette", servercontext.PALETTE_FILE, "JSON palette mapping schematic block ids onto Classic blocks")
+	extension := flags.String("format", strings.TrimPrefix(world.CLASSICWORLD_EXTENSION, "."), "extension to convert to when the output is a directory")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: burrowing-classic convert [options] <input file or directory> <output file or directory>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 && !(*dryRun && flags.NArg() == 1) {
+		flags.Usage()
+		return 2
+	}
+
+	palette, err := formats.LoadPalette(*palettePath)
+	if errors.Is(err, os.ErrNotExist) {
+		palette = formats.NewPalette()
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load palette %s: %v\n", *palettePath, err)
+		return 1
+	}
+	converter := &converter{output: os.Stdout, formats: formats.Formats(palette), dryRun: *dryRun, force: *force}
+	if *remapPath != "" {
+		if converter.remap, err = formats.LoadRemap(*remapPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load remapping table %s: %v\n", *remapPath, err)
+			return 1
+		}
+	}
+
+	jobs, err := converter.jobs(flags.Arg(0), flags.Arg(1), "."+strings.TrimPrefix(*extension, "."))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	failed := 0
+	for _, job := range jobs {
+		if err := converter.convert(job[0], job[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to convert %s: %v\n", job[0], err)
+			failed++
+		}
+	}
+	verb := "Converted"
+	if *dryRun {
+		verb = "Checked"
+	}
+	fmt.Fprintf(converter.output, "%s %d of %d levels\n", verb, len(jobs)-failed, len(jobs))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -7,12 +7,22 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/Hedwig7s/Burrowing-Classic/internal/console"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/heartbeat"
 	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/server"
 	"github.com/Hedwig7s/Burrowing-Classic/internal/servercontext"
 )
 
+// How often worlds are checked for having been empty long enough to unload
+const IDLE_WORLD_CHECK_INTERVAL = time.Minute
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		os.Exit(runConvert(os.Args[2:]))
+	}
+
 	var wg sync.WaitGroup
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
@@ -22,15 +32,44 @@ func main() {
 	errCh := make(chan error, 1)
 
 	serverCtx := servercontext.DefaultServerContext()
+	serverCtx.LoadFiles()
+	serverCtx.LoadWorlds()
 
 	srv := server.NewServer("0.0.0.0", 25564, serverCtx)
 
+	// Saving blocks on the disk, so it's kept off the tick
+	unloadTask := serverCtx.Scheduler.ScheduleRepeating(IDLE_WORLD_CHECK_INTERVAL, IDLE_WORLD_CHECK_INTERVAL, func() {
+		go srv.UnloadIdleWorlds()
+	})
+	defer unloadTask.Cancel()
+
+	if len(serverCtx.Config().HeartbeatURLs) > 0 {
+		beat := heartbeat.NewHeartbeat(nil, serverCtx.Config().HeartbeatURLs, srv.HeartbeatInfo)
+		// Beats block on the network, so they're kept off the tick
+		task := serverCtx.Scheduler.ScheduleRepeating(0, heartbeat.DEFAULT_INTERVAL, func() {
+			go beat.Beat(ctx)
+		})
+		defer task.Cancel()
+	}
+
 	defer srv.Close()
 	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serverCtx.Scheduler.Run(ctx)
+	}()
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		errCh <- srv.Start(ctx)
 	}()
+	// Only useful when someone is there to type, and would otherwise spin on a closed or redirected stdin
+	if console.IsTerminal(os.Stdin) {
+		operator := console.NewConsole(srv, os.Stdin, os.Stderr)
+		log.SetOutput(operator)
+		defer log.SetOutput(os.Stderr)
+		go operator.Run(ctx)
+	}
 
 	select {
 	case <-ctx.Done():
@@ -43,5 +82,9 @@ func main() {
 	}
 	cancel()
 	wg.Wait()
+	// Nothing changes the worlds once the server and scheduler have stopped, whichever way it was shut down
+	if err := serverCtx.SaveFiles(); err != nil {
+		log.Printf("Failed to save on shutdown: %v", err)
+	}
 	log.Println("Shutdown complete")
 }
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+const SALT_LENGTH = 16
+
+const saltAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// Random salt shared with server lists, which give clients a key derived from it
+func GenerateSalt() string {
+	random := make([]byte, SALT_LENGTH)
+	rand.Read(random)
+	salt := make([]byte, SALT_LENGTH)
+	for i, b := range random {
+		salt[i] = saltAlphabet[int(b)%len(saltAlphabet)]
+	}
+	return string(salt)
+}
+
+// Key a server list gives a player, as lowercase hex
+func NameKey(salt, name string) string {
+	sum := md5.Sum([]byte(salt + name))
+	return hex.EncodeToString(sum[:])
+}
+
+// Whether the key sent in Identification proves the server list vouched for the name
+func VerifyName(salt, name, key string) bool {
+	expected := NameKey(salt, name)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(key))) == 1
+}
@@ -0,0 +1,166 @@
+package chat
+
+import "strings"
+
+const LINE_LENGTH = 64
+
+const COLOR_PREFIX = '&'
+
+const (
+	COLOR_BLACK      = "&0"
+	COLOR_DARK_BLUE  = "&1"
+	COLOR_DARK_GREEN = "&2"
+	COLOR_DARK_AQUA  = "&3"
+	COLOR_DARK_RED   = "&4"
+	COLOR_PURPLE     = "&5"
+	COLOR_GOLD       = "&6"
+	COLOR_GRAY       = "&7"
+	COLOR_DARK_GRAY  = "&8"
+	COLOR_BLUE       = "&9"
+	COLOR_GREEN      = "&a"
+	COLOR_AQUA       = "&b"
+	COLOR_RED        = "&c"
+	COLOR_PINK       = "&d"
+	COLOR_YELLOW     = "&e"
+	COLOR_WHITE      = "&f"
+	COLOR_DEFAULT    = COLOR_WHITE
+)
+
+// Marks lines carried on from the previous one
+const CONTINUATION_PREFIX = "> "
+
+func isDefaultColor(code byte) bool {
+	return (code >= '0' && code <= '9') || (code >= 'a' && code <= 'f')
+}
+
+func lower(code byte) byte {
+	if code >= 'A' && code <= 'F' {
+		return code + ('a' - 'A')
+	}
+	return code
+}
+
+// Whether the client will understand the color code. Custom colors may be nil
+func ValidColor(code byte, custom *TextColors) bool {
+	if isDefaultColor(lower(code)) {
+		return true
+	}
+	if custom == nil {
+		return false
+	}
+	_, ok := custom.Get(code)
+	return ok
+}
+
+// Removes color codes the client doesn't know, which crash vanilla clients, along with any trailing & or color codes
+func Sanitize(message string, custom *TextColors) string {
+	var builder strings.Builder
+	builder.Grow(len(message))
+	for i := 0; i < len(message); i++ {
+		if message[i] != COLOR_PREFIX {
+			builder.WriteByte(message[i])
+			continue
+		}
+		if i+1 >= len(message) {
+			break
+		}
+		code := message[i+1]
+		i++
+		if !ValidColor(code, custom) {
+			continue
+		}
+		builder.WriteByte(COLOR_PREFIX)
+		if isDefaultColor(lower(code)) {
+			code = lower(code)
+		}
+		builder.WriteByte(code)
+	}
+	return trimColors(strings.TrimRight(builder.String(), " "))
+}
+
+// Strips color codes with no text after them
+func trimColors(line string) string {
+	for len(line) >= 2 && line[len(line)-2] == COLOR_PREFIX {
+		line = strings.TrimRight(line[:len(line)-2], " ")
+	}
+	return line
+}
+
+// Last color code used in the line, or an empty string if none
+func lastColor(line string) string {
+	for i := len(line) - 2; i >= 0; i-- {
+		if line[i] == COLOR_PREFIX {
+			return line[i : i+2]
+		}
+	}
+	return ""
+}
+
+// Splits a sanitized message into lines which fit in a Message packet, breaking on spaces where possible.
+// Continuation lines carry on in the color the previous line ended with
+func WordWrap(message string) []string {
+	var lines []string
+	prefix := ""
+	for {
+		if len(prefix)+len(message) <= LINE_LENGTH {
+			if line := trimColors(prefix + message); line != "" {
+				lines = append(lines, line)
+			}
+			return lines
+		}
+		available := LINE_LENGTH - len(prefix)
+		cut := strings.LastIndexByte(message[:available+1], ' ')
+		if cut <= 0 {
+			cut = available
+		}
+		// Never split a color code from its character
+		if message[cut-1] == COLOR_PREFIX {
+			cut--
+		}
+		// A code at the very start can't be moved onto the next line, so is split rather than never making progress
+		if cut == 0 {
+			cut = available
+		}
+		line := trimColors(prefix + message[:cut])
+		if line != "" {
+			lines = append(lines, line)
+		}
+		color := lastColor(line)
+		if color == "" {
+			color = lastColor(prefix)
+		}
+		prefix = color + CONTINUATION_PREFIX
+		message = strings.TrimLeft(message[cut:], " ")
+	}
+}
+
+// Formats a player's chat message for broadcasting
+func FormatMessage(color, prefix, name, message string) string {
+	return color + prefix + name + COLOR_DEFAULT + ": " + message
+}
+
+// Drops ASCII control characters. Bytes above 0x7F are kept, as every one is a valid CP437 glyph
+func StripUnprintable(message string) string {
+	var builder strings.Builder
+	builder.Grow(len(message))
+	for i := 0; i < len(message); i++ {
+		if message[i] >= ' ' && message[i] != 0x7F {
+			builder.WriteByte(message[i])
+		}
+	}
+	return builder.String()
+}
+
+// Removes all color codes, for output which can't display them
+func StripColors(message string) string {
+	var builder strings.Builder
+	builder.Grow(len(message))
+	for i := 0; i < len(message); i++ {
+		if message[i] == COLOR_PREFIX && i+1 < len(message) {
+			i++
+			continue
+		}
+		builder.WriteByte(message[i])
+	}
+	return builder.String()
+}
@@ -0,0 +1,73 @@
+package chat
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWordWrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    []string
+	}{
+		{"Short", "hello", []string{"hello"}},
+		{"Empty", "", nil},
+		{"Spaces", strings.Repeat("word ", 13) + "end", []string{strings.TrimSpace(strings.Repeat("word ", 13)), "> end"}},
+		{"NoSpaces", strings.Repeat("x", 100), []string{strings.Repeat("x", 64), "> " + strings.Repeat("x", 36)}},
+		{"CarriesColor", "&c" + strings.Repeat("ab ", 21) + "cd", []string{"&c" + strings.TrimSpace(strings.Repeat("ab ", 20)) + " ab", "&c> cd"}},
+		{"KeepsCodeTogether", strings.Repeat("x", 63) + "&ayy", []string{strings.Repeat("x", 63), "> &ayy"}},
+		{"CodeStartsNextLine", strings.Repeat("x", 62) + " &a " + strings.Repeat("y", 10), []string{strings.Repeat("x", 62), "> &a " + strings.Repeat("y", 10)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := WordWrap(test.message); !slices.Equal(got, test.want) {
+				t.Errorf("WordWrap(%q) = %q, want %q", test.message, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWordWrapCodeAtStart(t *testing.T) {
+	// A code at the start followed by the only space used to leave nothing to cut
+	lines := WordWrap("& " + strings.Repeat("x", 100))
+	if len(lines) < 2 {
+		t.Fatalf("Expected the message to be wrapped, got %q", lines)
+	}
+	for _, line := range lines {
+		if len(line) > LINE_LENGTH {
+			t.Errorf("Line %q is longer than %d", line, LINE_LENGTH)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	custom := NewTextColors()
+	if err := custom.Set(TextColor{Code: 'g', Red: 255, Alpha: 255}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		message string
+		custom  *TextColors
+		want    string
+	}{
+		{"Plain", "hello", nil, "hello"},
+		{"Valid", "&chello &fthere", nil, "&chello &fthere"},
+		{"Uppercase", "&Chello", nil, "&chello"},
+		{"Invalid", "hi &zthere", nil, "hi there"},
+		{"TrailingPrefix", "hello&", nil, "hello"},
+		{"TrailingCodes", "hello &c &e", nil, "hello"},
+		{"OnlyCodes", "&c&e", nil, ""},
+		{"Custom", "&ghello", custom, "&ghello"},
+		{"UnknownCustom", "&ghello", nil, "hello"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Sanitize(test.message, test.custom); got != test.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", test.message, got, test.want)
+			}
+		})
+	}
+}
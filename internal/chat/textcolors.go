@@ -0,0 +1,102 @@
+package chat
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+)
+
+const (
+	TEXTCOLOR_INVALID_CODE = iota
+	TEXTCOLOR_NOT_FOUND
+)
+
+// Codes which can't be redefined as they're used for other purposes by clients
+var reservedCodes = []byte{' ', '%', '&', '/'}
+
+type TextColor struct {
+	Code  byte `json:"code"`
+	Red   byte `json:"red"`
+	Green byte `json:"green"`
+	Blue  byte `json:"blue"`
+	Alpha byte `json:"alpha"`
+}
+
+type TextColors struct {
+	mutex  sync.RWMutex
+	colors map[byte]TextColor
+}
+
+func ValidTextColorCode(code byte) bool {
+	return code > ' ' && code <= '~' && !slices.Contains(reservedCodes, code)
+}
+
+func (colors *TextColors) Set(color TextColor) error {
+	if !ValidTextColorCode(color.Code) {
+		return cerror.NewErrorf(TEXTCOLOR_INVALID_CODE, "%q can't be used as a color code", color.Code)
+	}
+	colors.mutex.Lock()
+	defer colors.mutex.Unlock()
+	colors.colors[color.Code] = color
+	return nil
+}
+
+func (colors *TextColors) Remove(code byte) error {
+	colors.mutex.Lock()
+	defer colors.mutex.Unlock()
+	if _, ok := colors.colors[code]; !ok {
+		return cerror.NewErrorf(TEXTCOLOR_NOT_FOUND, "No custom color for %q", code)
+	}
+	delete(colors.colors, code)
+	return nil
+}
+
+func (colors *TextColors) Get(code byte) (TextColor, bool) {
+	colors.mutex.RLock()
+	defer colors.mutex.RUnlock()
+	color, ok := colors.colors[code]
+	return color, ok
+}
+
+func (colors *TextColors) All() []TextColor {
+	colors.mutex.RLock()
+	defer colors.mutex.RUnlock()
+	all := make([]TextColor, 0, len(colors.colors))
+	for _, color := range colors.colors {
+		all = append(all, color)
+	}
+	return all
+}
+
+func (colors *TextColors) Save(path string) error {
+	data, err := json.MarshalIndent(colors.All(), "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func LoadTextColors(path string) (*TextColors, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var all []TextColor
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	colors := NewTextColors()
+	for _, color := range all {
+		if err := colors.Set(color); err != nil {
+			return nil, err
+		}
+	}
+	return colors, nil
+}
+
+func NewTextColors() *TextColors {
+	return &TextColors{colors: make(map[byte]TextColor)}
+}
@@ -0,0 +1,105 @@
+package console
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/server"
+)
+
+const PROMPT = "> "
+
+// Clears the current line so output doesn't land after the prompt
+const CLEAR_LINE = "\r\033[K"
+
+// Operator REPL which runs lines as commands. Use it as the log output so the prompt is redrawn after each message
+type Console struct {
+	server *server.Server
+	input  io.Reader
+	output io.Writer
+	mutex  sync.Mutex
+	// Whether a prompt is showing, so it isn't drawn twice when a command also logs
+	prompted bool
+}
+
+func (console *Console) Write(data []byte) (int, error) {
+	console.mutex.Lock()
+	defer console.mutex.Unlock()
+	if _, err := io.WriteString(console.output, CLEAR_LINE); err != nil {
+		return 0, err
+	}
+	written, err := console.output.Write(data)
+	if err != nil {
+		return written, err
+	}
+	_, err = io.WriteString(console.output, PROMPT)
+	console.prompted = true
+	return written, err
+}
+
+func (console *Console) prompt() {
+	console.mutex.Lock()
+	defer console.mutex.Unlock()
+	if !console.prompted {
+		io.WriteString(console.output, PROMPT)
+		console.prompted = true
+	}
+}
+
+// The terminal moves to a new line once enter is pressed
+func (console *Console) entered() {
+	console.mutex.Lock()
+	defer console.mutex.Unlock()
+	console.prompted = false
+}
+
+// Runs until the input ends or ctx is done. Reading is left blocked on exit, as stdin can't be interrupted
+func (console *Console) Run(ctx context.Context) {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(console.input)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	console.prompt()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			console.entered()
+			console.server.ExecuteCommand(server.ConsoleSender{}, line)
+			console.prompt()
+		}
+	}
+}
+
+// Whether the file is an interactive terminal rather than a pipe, regular file or the null device
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+func NewConsole(server *server.Server, input io.Reader, output io.Writer) *Console {
+	return &Console{
+		server: server,
+		input:  input,
+		output: output,
+	}
+}
@@ -0,0 +1,205 @@
+package formats
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/nbt"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+const CLASSIC_DAT_EXTENSION = ".dat"
+
+const CLASSIC_DAT_MAGIC = 0x271BB788
+
+const (
+	// Name, creator, creation time and size, then the blocks
+	CLASSIC_DAT_VERSION_RAW = 1
+	// A serialized level object
+	CLASSIC_DAT_VERSION_SERIALIZED = 2
+)
+
+const CLASSIC_LEVEL_CLASS = "com.mojang.minecraft.level.Level"
+
+// Size of the levels saved before the header was added
+const (
+	CLASSIC_DAT_LEGACY_WIDTH  = 256
+	CLASSIC_DAT_LEGACY_HEIGHT = 64
+	CLASSIC_DAT_LEGACY_LENGTH = 256
+)
+
+const CLASSIC_DAT_SERVICE = "Minecraft Classic"
+
+const (
+	CLASSIC_DAT_UNSUPPORTED_VERSION = iota
+	CLASSIC_DAT_NOT_LEVEL
+	CLASSIC_DAT_MISSING_FIELD
+	CLASSIC_DAT_INVALID_SIZE
+)
+
+// Classic calls the vertical axis depth and the Z axis height
+type classicDat struct {
+	Width, Depth, Height   int
+	Blocks                 []byte
+	SpawnX, SpawnY, SpawnZ int
+	SpawnYaw               float32
+	Creator                string
+	// Milliseconds since the Unix epoch
+	CreateTime int64
+	spawn      bool
+}
+
+func classicDatSize(width, depth, height int) (int16, int16, int16, error) {
+	if !world.ValidLevelSize(width, depth, height) {
+		return 0, 0, 0, cerror.NewErrorf(CLASSIC_DAT_INVALID_SIZE, "Invalid level size %dx%dx%d", width, depth, height)
+	}
+	return int16(width), int16(depth), int16(height), nil
+}
+
+func readClassicDatRaw(reader io.Reader) (*classicDat, error) {
+	dat := &classicDat{}
+	var err error
+	// Level name, which is replaced by the file name
+	if _, err = readJavaUTF(reader); err != nil {
+		return nil, err
+	}
+	if dat.Creator, err = readJavaUTF(reader); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.BigEndian, &dat.CreateTime); err != nil {
+		return nil, err
+	}
+	var size [3]int16
+	if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	dat.Width, dat.Height, dat.Depth = int(size[0]), int(size[1]), int(size[2])
+	if _, _, _, err := classicDatSize(dat.Width, dat.Depth, dat.Height); err != nil {
+		return nil, err
+	}
+	dat.Blocks = make([]byte, dat.Width*dat.Depth*dat.Height)
+	if _, err := io.ReadFull(reader, dat.Blocks); err != nil {
+		return nil, err
+	}
+	return dat, nil
+}
+
+func readJavaUTF(reader io.Reader) (string, error) {
+	javaReader := &javaReader{reader: reader}
+	return javaReader.utf()
+}
+
+func readClassicDatSerialized(reader io.Reader) (*classicDat, error) {
+	object, err := readJavaObject(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !object.InstanceOf(CLASSIC_LEVEL_CLASS) {
+		return nil, cerror.NewErrorf(CLASSIC_DAT_NOT_LEVEL, "Serialized object is a %s rather than a level", object.Class.Name)
+	}
+	dat := &classicDat{}
+	for name, field := range map[string]*int{"width": &dat.Width, "depth": &dat.Depth, "height": &dat.Height} {
+		value, ok := javaInt(object, name)
+		if !ok {
+			return nil, cerror.NewErrorf(CLASSIC_DAT_MISSING_FIELD, "Level is missing %s", name)
+		}
+		*field = value
+	}
+	blocks, ok := object.Fields["blocks"].([]byte)
+	if !ok {
+		return nil, cerror.NewError(CLASSIC_DAT_MISSING_FIELD, "Level is missing blocks")
+	}
+	dat.Blocks = blocks
+	dat.SpawnX, dat.spawn = javaInt(object, "xSpawn")
+	dat.SpawnY, _ = javaInt(object, "ySpawn")
+	dat.SpawnZ, _ = javaInt(object, "zSpawn")
+	dat.SpawnYaw, _ = object.Fields["rotSpawn"].(float32)
+	dat.Creator, _ = object.Fields["creator"].(string)
+	dat.CreateTime, _ = object.Fields["createTime"].(int64)
+	return dat, nil
+}
+
+// Reads a level saved by the original Classic client or server, in any of the formats it has used
+func ReadClassicDat(name string, reader io.Reader) (*world.Level, *Report, error) {
+	report := &Report{}
+	decompressed, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer decompressed.Close()
+	buffered := bufio.NewReader(decompressed)
+	header, err := buffered.Peek(4)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var dat *classicDat
+	if binary.BigEndian.Uint32(header) != CLASSIC_DAT_MAGIC {
+		dat = &classicDat{Width: CLASSIC_DAT_LEGACY_WIDTH, Depth: CLASSIC_DAT_LEGACY_HEIGHT, Height: CLASSIC_DAT_LEGACY_LENGTH}
+		dat.Blocks = make([]byte, dat.Width*dat.Depth*dat.Height)
+		if _, err := io.ReadFull(buffered, dat.Blocks); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		buffered.Discard(len(header))
+		version, err := buffered.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch version {
+		case CLASSIC_DAT_VERSION_RAW:
+			dat, err = readClassicDatRaw(buffered)
+		case CLASSIC_DAT_VERSION_SERIALIZED:
+			dat, err = readClassicDatSerialized(buffered)
+		default:
+			err = cerror.NewErrorf(CLASSIC_DAT_UNSUPPORTED_VERSION, "Unsupported Classic level version %d", version)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	width, height, length, err := classicDatSize(dat.Width, dat.Depth, dat.Height)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Classic itself never had the CustomBlocks ones
+	for i, block := range dat.Blocks {
+		if block >= world.CLASSIC_BLOCK_COUNT {
+			dat.Blocks[i] = FALLBACK_BLOCK
+			report.Replaced("unknown blocks")
+		}
+	}
+	level, err := world.NewLevelFromBlocks(name, width, height, length, dat.Blocks)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dat.spawn {
+		level.SetSpawn(world.Position{
+			X:   float32(dat.SpawnX) + 0.5,
+			Y:   float32(dat.SpawnY) + world.PLAYER_HEIGHT,
+			Z:   float32(dat.SpawnZ) + 0.5,
+			Yaw: byte(int(dat.SpawnYaw*256/360) & 0xFF),
+		})
+	}
+	if dat.Creator != "" {
+		level.Extra["CreatedBy"] = nbt.Compound{"Service": CLASSIC_DAT_SERVICE, "Username": dat.Creator}
+	}
+	if dat.CreateTime > 0 {
+		level.Extra["TimeCreated"] = dat.CreateTime / 1000
+	}
+	return level, report, nil
+}
+
+func LoadClassicDat(path string) (*world.Level, *Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	return ReadClassicDat(levelName(path), file)
+}
@@ -0,0 +1,395 @@
+package formats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/nbt"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+var update = flag.Bool("update", false, "regenerate the files in testdata")
+
+// Fixture levels are 8 wide, 4 tall and 6 long
+const (
+	fixtureWidth      = 8
+	fixtureHeight     = 4
+	fixtureLength     = 6
+	fixtureCreator    = "notch"
+	fixtureCreateTime = 1245678901234
+	// Not a block in the classic set, so it should be replaced
+	fixtureUnknownBlock = 60
+)
+
+// Stone floor, a grass layer, a gold block at 1,2,3 and an unknown block at 7,3,5
+func fixtureBlocks() []byte {
+	blocks := make([]byte, fixtureWidth*fixtureHeight*fixtureLength)
+	index := func(x, y, z int) int { return (y*fixtureLength+z)*fixtureWidth + x }
+	for z := range fixtureLength {
+		for x := range fixtureWidth {
+			blocks[index(x, 0, z)] = world.BLOCK_STONE
+			blocks[index(x, 1, z)] = world.BLOCK_GRASS
+		}
+	}
+	blocks[index(1, 2, 3)] = world.BLOCK_GOLD
+	blocks[index(7, 3, 5)] = fixtureUnknownBlock
+	return blocks
+}
+
+// Writes objects the way Java's ObjectOutputStream does, sharing handles for repeated strings and classes
+type javaWriter struct {
+	buffer  bytes.Buffer
+	handles map[string]int32
+	next    int32
+}
+
+type javaTestField struct {
+	name, className string
+	fieldType       byte
+	value           any
+}
+
+type javaTestClass struct {
+	name          string
+	serialVersion int64
+	flags         byte
+	// Sorted as Java does, primitives first then by name
+	fields []javaTestField
+	super  *javaTestClass
+	// Written after the fields by classes with their own writeObject
+	annotations func(writer *javaWriter)
+}
+
+func newJavaWriter() *javaWriter {
+	writer := &javaWriter{handles: make(map[string]int32), next: JAVA_BASE_HANDLE}
+	binary.Write(&writer.buffer, binary.BigEndian, []uint16{JAVA_STREAM_MAGIC, JAVA_STREAM_VERSION})
+	return writer
+}
+
+func (writer *javaWriter) write(values ...any) {
+	for _, value := range values {
+		binary.Write(&writer.buffer, binary.BigEndian, value)
+	}
+}
+
+func (writer *javaWriter) utf(value string) {
+	writer.write(uint16(len(value)))
+	writer.buffer.WriteString(value)
+}
+
+func (writer *javaWriter) handle() int32 {
+	handle := writer.next
+	writer.next++
+	return handle
+}
+
+// Strings and classes are written once, then referred to by handle
+func (writer *javaWriter) reference(key string) bool {
+	if handle, ok := writer.handles[key]; ok {
+		writer.write(byte(JAVA_TC_REFERENCE), handle)
+		return true
+	}
+	return false
+}
+
+func (writer *javaWriter) string(value string) {
+	if writer.reference("string:" + value) {
+		return
+	}
+	writer.write(byte(JAVA_TC_STRING))
+	writer.utf(value)
+	writer.handles["string:"+value] = writer.handle()
+}
+
+func (writer *javaWriter) class(class *javaTestClass) {
+	if class == nil {
+		writer.write(byte(JAVA_TC_NULL))
+		return
+	}
+	if writer.reference("class:" + class.name) {
+		return
+	}
+	writer.write(byte(JAVA_TC_CLASSDESC))
+	writer.utf(class.name)
+	writer.write(class.serialVersion)
+	writer.handles["class:"+class.name] = writer.handle()
+	writer.write(class.flags, uint16(len(class.fields)))
+	for _, field := range class.fields {
+		writer.write(field.fieldType)
+		writer.utf(field.name)
+		if field.className != "" {
+			writer.string(field.className)
+		}
+	}
+	writer.write(byte(JAVA_TC_ENDBLOCKDATA))
+	writer.class(class.super)
+}
+
+func (writer *javaWriter) object(class *javaTestClass) {
+	writer.write(byte(JAVA_TC_OBJECT))
+	writer.class(class)
+	writer.handle()
+	var hierarchy []*javaTestClass
+	for current := class; current != nil; current = current.super {
+		hierarchy = append([]*javaTestClass{current}, hierarchy...)
+	}
+	for _, current := range hierarchy {
+		for _, field := range current.fields {
+			writer.value(field.value)
+		}
+		if current.annotations != nil {
+			current.annotations(writer)
+			writer.write(byte(JAVA_TC_ENDBLOCKDATA))
+		}
+	}
+}
+
+func (writer *javaWriter) value(value any) {
+	switch value := value.(type) {
+	case nil:
+		writer.write(byte(JAVA_TC_NULL))
+	case string:
+		writer.string(value)
+	case []byte:
+		writer.write(byte(JAVA_TC_ARRAY))
+		writer.class(&javaTestClass{name: "[B", serialVersion: -0x530CE807F9F7AB20, flags: JAVA_SC_SERIALIZABLE})
+		writer.write(int32(len(value)))
+		writer.handle()
+		writer.buffer.Write(value)
+	case *javaTestClass:
+		writer.object(value)
+	case bool:
+		if value {
+			writer.write(byte(1))
+		} else {
+			writer.write(byte(0))
+		}
+	default:
+		writer.write(value)
+	}
+}
+
+// An empty java.util.ArrayList, which adds its capacity with writeObject
+func emptyArrayList() *javaTestClass {
+	return &javaTestClass{
+		name:          "java.util.ArrayList",
+		serialVersion: 0x7881D21D99C7619D,
+		flags:         JAVA_SC_SERIALIZABLE | JAVA_SC_WRITE_METHOD,
+		fields:        []javaTestField{{name: "size", fieldType: 'I', value: int32(0)}},
+		annotations: func(writer *javaWriter) {
+			writer.write(byte(JAVA_TC_BLOCKDATA), byte(4), int32(0))
+		},
+	}
+}
+
+// The fields of Classic's own level class, with the fixture's values
+func classicLevelObject() *javaTestClass {
+	blockMap := &javaTestClass{
+		name:  "com.mojang.minecraft.level.BlockMap",
+		flags: JAVA_SC_SERIALIZABLE,
+		fields: []javaTestField{
+			{name: "depth", fieldType: 'I', value: int32(fixtureHeight)},
+			{name: "height", fieldType: 'I', value: int32(fixtureLength)},
+			{name: "width", fieldType: 'I', value: int32(fixtureWidth)},
+			{name: "all", fieldType: 'L', className: "Ljava/util/List;", value: emptyArrayList()},
+		},
+	}
+	return &javaTestClass{
+		name:  CLASSIC_LEVEL_CLASS,
+		flags: JAVA_SC_SERIALIZABLE,
+		fields: []javaTestField{
+			{name: "cloudColor", fieldType: 'I', value: int32(0xFFFFFF)},
+			{name: "createTime", fieldType: 'J', value: int64(fixtureCreateTime)},
+			{name: "creativeMode", fieldType: 'Z', value: false},
+			{name: "depth", fieldType: 'I', value: int32(fixtureHeight)},
+			{name: "fogColor", fieldType: 'I', value: int32(0xFFFFFF)},
+			{name: "growTrees", fieldType: 'Z', value: true},
+			{name: "height", fieldType: 'I', value: int32(fixtureLength)},
+			{name: "rotSpawn", fieldType: 'F', value: float32(90)},
+			{name: "skyColor", fieldType: 'I', value: int32(0x99CCFF)},
+			{name: "tickCount", fieldType: 'I', value: int32(1234)},
+			{name: "unprocessed", fieldType: 'I', value: int32(0)},
+			{name: "waterLevel", fieldType: 'I', value: int32(2)},
+			{name: "width", fieldType: 'I', value: int32(fixtureWidth)},
+			{name: "xSpawn", fieldType: 'I', value: int32(2)},
+			{name: "ySpawn", fieldType: 'I', value: int32(2)},
+			{name: "zSpawn", fieldType: 'I', value: int32(4)},
+			{name: "blockMap", fieldType: 'L', className: "Lcom/mojang/minecraft/level/BlockMap;", value: blockMap},
+			{name: "blocks", fieldType: '[', className: "[B", value: fixtureBlocks()},
+			{name: "creator", fieldType: 'L', className: "Ljava/lang/String;", value: fixtureCreator},
+			{name: "name", fieldType: 'L', className: "Ljava/lang/String;", value: "A Nice World"},
+		},
+	}
+}
+
+func gzipped(data []byte) []byte {
+	var buffer bytes.Buffer
+	compressed := gzip.NewWriter(&buffer)
+	compressed.Write(data)
+	compressed.Close()
+	return buffer.Bytes()
+}
+
+func serializedFixture() []byte {
+	var header bytes.Buffer
+	binary.Write(&header, binary.BigEndian, uint32(CLASSIC_DAT_MAGIC))
+	header.WriteByte(CLASSIC_DAT_VERSION_SERIALIZED)
+	writer := newJavaWriter()
+	writer.object(classicLevelObject())
+	return gzipped(append(header.Bytes(), writer.buffer.Bytes()...))
+}
+
+func rawFixture() []byte {
+	var data bytes.Buffer
+	binary.Write(&data, binary.BigEndian, uint32(CLASSIC_DAT_MAGIC))
+	data.WriteByte(CLASSIC_DAT_VERSION_RAW)
+	writer := &javaWriter{}
+	writer.utf("A Nice World")
+	writer.utf(fixtureCreator)
+	writer.write(int64(fixtureCreateTime), []int16{fixtureWidth, fixtureLength, fixtureHeight})
+	data.Write(writer.buffer.Bytes())
+	data.Write(fixtureBlocks())
+	return gzipped(data.Bytes())
+}
+
+// Headerless levels are always 256x64x256, so only a few blocks are set
+func legacyFixture() []byte {
+	blocks := make([]byte, CLASSIC_DAT_LEGACY_WIDTH*CLASSIC_DAT_LEGACY_HEIGHT*CLASSIC_DAT_LEGACY_LENGTH)
+	blocks[0] = world.BLOCK_BEDROCK
+	blocks[len(blocks)-1] = world.BLOCK_GLASS
+	return gzipped(blocks)
+}
+
+var classicDatFixtures = map[string]func() []byte{
+	"serialized.dat": serializedFixture,
+	"raw.dat":        rawFixture,
+	"legacy.dat":     legacyFixture,
+}
+
+func loadFixture(t *testing.T, name string) (*world.Level, *Report) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, classicDatFixtures[name](), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	level, report, err := LoadClassicDat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return level, report
+}
+
+func checkFixtureLevel(t *testing.T, level *world.Level, report *Report) {
+	t.Helper()
+	if width, height, length := level.Size(); width != fixtureWidth || height != fixtureHeight || length != fixtureLength {
+		t.Fatalf("Expected size %dx%dx%d, got %dx%dx%d", fixtureWidth, fixtureHeight, fixtureLength, width, height, length)
+	}
+	expected := fixtureBlocks()
+	expected[len(expected)-1] = FALLBACK_BLOCK
+	if !bytes.Equal(level.Blocks(), expected) {
+		t.Fatal("Blocks differ from the fixture")
+	}
+	if block, _ := level.GetBlock(1, 2, 3); block != world.BLOCK_GOLD {
+		t.Fatalf("Expected gold at 1,2,3, got %d", block)
+	}
+	if !hasWarning(report, "1 unknown blocks") {
+		t.Fatalf("Replaced block wasn't reported: %v", report.Warnings())
+	}
+	createdBy, _ := nbt.Get[nbt.Compound](level.Extra, "CreatedBy")
+	if username, _ := nbt.Get[string](createdBy, "Username"); username != fixtureCreator {
+		t.Fatalf("Expected creator %q, got %q", fixtureCreator, username)
+	}
+	if created, _ := nbt.Get[int64](level.Extra, "TimeCreated"); created != fixtureCreateTime/1000 {
+		t.Fatalf("Expected creation time %d, got %d", fixtureCreateTime/1000, created)
+	}
+}
+
+func TestClassicDatSerialized(t *testing.T) {
+	level, report := loadFixture(t, "serialized.dat")
+	if level.Name() != "serialized" {
+		t.Fatalf("Expected the file name as the level name, got %q", level.Name())
+	}
+	checkFixtureLevel(t, level, report)
+	spawn := level.Spawn()
+	if spawn.X != 2.5 || spawn.Y != 2+world.PLAYER_HEIGHT || spawn.Z != 4.5 {
+		t.Fatalf("Wrong spawn %+v", spawn)
+	}
+	if spawn.Yaw != 64 {
+		t.Fatalf("Expected a quarter turn of yaw, got %d", spawn.Yaw)
+	}
+}
+
+func TestClassicDatRaw(t *testing.T) {
+	level, report := loadFixture(t, "raw.dat")
+	checkFixtureLevel(t, level, report)
+}
+
+func TestClassicDatLegacy(t *testing.T) {
+	level, report := loadFixture(t, "legacy.dat")
+	if width, height, length := level.Size(); width != 256 || height != 64 || length != 256 {
+		t.Fatalf("Expected size 256x64x256, got %dx%dx%d", width, height, length)
+	}
+	if block, _ := level.GetBlock(0, 0, 0); block != world.BLOCK_BEDROCK {
+		t.Fatalf("Expected bedrock at the origin, got %d", block)
+	}
+	if block, _ := level.GetBlock(255, 63, 255); block != world.BLOCK_GLASS {
+		t.Fatalf("Expected glass in the far corner, got %d", block)
+	}
+	if warnings := report.Warnings(); len(warnings) != 0 {
+		t.Fatalf("Unexpected warnings: %v", warnings)
+	}
+}
+
+// Fixtures are checked in, so they must match what the generators write
+func TestClassicDatFixturesUpToDate(t *testing.T) {
+	for name, generate := range classicDatFixtures {
+		path := filepath.Join("testdata", name)
+		stored, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decompressFixture(t, stored), decompressFixture(t, generate())) {
+			t.Errorf("%s is out of date, regenerate it with -update", path)
+		}
+	}
+}
+
+func decompressFixture(t *testing.T, data []byte) []byte {
+	t.Helper()
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	if _, err := buffer.ReadFrom(reader); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestClassicDatRejectsInvalid(t *testing.T) {
+	serialized := decompressFixture(t, serializedFixture())
+	wrongClass := bytes.Replace(serialized, []byte(CLASSIC_LEVEL_CLASS), []byte("com.mojang.minecraft.level.Other"), 1)
+	badSize := decompressFixture(t, rawFixture())
+	binary.BigEndian.PutUint16(badSize[len(badSize)-len(fixtureBlocks())-6:], math.MaxUint16)
+	tests := map[string][]byte{
+		"unsupported version": {0x27, 0x1B, 0xB7, 0x88, 3},
+		"wrong class":         wrongClass,
+		"truncated object":    serialized[:len(serialized)/2],
+		"truncated blocks":    serialized[:len(serialized)-1],
+		"negative size":       badSize,
+	}
+	for name, data := range tests {
+		if _, _, err := ReadClassicDat(name, bytes.NewReader(gzipped(data))); err == nil {
+			t.Errorf("Read a level with %s", name)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+const (
+	FORMAT_UNKNOWN = iota
+	FORMAT_READ_ONLY
+	FORMAT_INVALID_REMAP
+	FORMAT_INVALID_LEVEL
+)
+
+type Format struct {
+	Name      string
+	Extension string
+	Load      func(path string) (*world.Level, *Report, error)
+	// Nil for formats which can only be imported
+	Save func(level *world.Level, path string) error
+}
+
+func loadClassicWorld(path string) (*world.Level, *Report, error) {
+	level, err := world.LoadClassicWorld(path)
+	return level, &Report{}, err
+}
+
+func saveClassicWorld(level *world.Level, path string) error {
+	return level.SaveClassicWorld(path)
+}
+
+// Every supported level format. Schematics are loaded as a level the size of the structure, mapped through the palette
+func Formats(palette *Palette) []Format {
+	return []Format{
+		{Name: "ClassicWorld", Extension: world.CLASSICWORLD_EXTENSION, Load: loadClassicWorld, Save: saveClassicWorld},
+		{Name: "MCGalaxy", Extension: LVL_EXTENSION, Load: LoadLvl, Save: SaveLvl},
+		{Name: "Minecraft Classic", Extension: CLASSIC_DAT_EXTENSION, Load: LoadClassicDat},
+		{Name: "fCraft", Extension: FCM_EXTENSION, Load: LoadFcm},
+		{
+			Name:      "MCEdit schematic",
+			Extension: SCHEMATIC_EXTENSION,
+			Load: func(path string) (*world.Level, *Report, error) {
+				schematic, report, err := LoadSchematic(path, palette)
+				if err != nil {
+					return nil, nil, err
+				}
+				level, err := schematic.Level(levelName(path))
+				return level, report, err
+			},
+		},
+	}
+}
+
+// Matched by extension, ignoring case
+func FormatFor(formats []Format, path string) (Format, error) {
+	extension := strings.ToLower(filepath.Ext(path))
+	for _, format := range formats {
+		if format.Extension == extension {
+			return format, nil
+		}
+	}
+	return Format{}, cerror.NewErrorf(FORMAT_UNKNOWN, "Unknown level format %q", extension)
+}
+
+// Level with the schematic's blocks, spawning on top of its centre
+func (schematic *Schematic) Level(name string) (*world.Level, error) {
+	level, err := world.NewLevelFromBlocks(name, schematic.width, schematic.height, schematic.length, schematic.blocks)
+	if err != nil {
+		return nil, err
+	}
+	level.SetSpawn(world.Position{
+		X: float32(schematic.width) / 2,
+		Y: float32(schematic.height) + world.PLAYER_HEIGHT,
+		Z: float32(schematic.length) / 2,
+	})
+	return level, nil
+}
+
+// Replaces one block with another throughout a level
+type Remap map[byte]byte
+
+// Both sides must be known blocks. Anything else was already replaced with the fallback block while loading, so could never match
+func parseRemap(table map[string]string) (Remap, error) {
+	remap := make(Remap, len(table))
+	for from, to := range table {
+		source, ok := world.BlockByName(from)
+		if !ok {
+			id, err := strconv.ParseUint(from, 10, 8)
+			if err != nil {
+				return nil, cerror.NewErrorf(FORMAT_INVALID_REMAP, "Unknown block %s", from)
+			}
+			source = byte(id)
+		}
+		if !world.ValidBlock(source) {
+			return nil, cerror.NewErrorf(FORMAT_INVALID_REMAP, "Block %s isn't a known block, so can't be remapped", from)
+		}
+		target, ok := world.BlockByName(to)
+		if !ok {
+			return nil, cerror.NewErrorf(FORMAT_INVALID_REMAP, "Unknown block %s", to)
+		}
+		remap[source] = target
+	}
+	return remap, nil
+}
+
+// A JSON object of block names or ids, such as {"sponge": "air", "49": "stone"}
+func LoadRemap(path string) (Remap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var table map[string]string
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, err
+	}
+	return parseRemap(table)
+}
+
+// Returns how many blocks were changed
+func (remap Remap) Apply(level *world.Level) (int, error) {
+	if len(remap) == 0 {
+		return 0, nil
+	}
+	width, _, length := level.Size()
+	blocks := level.Blocks()
+	changed := 0
+	for i, block := range blocks {
+		if target, ok := remap[block]; ok && target != block {
+			x := int16(i % int(width))
+			z := int16(i / int(width) % int(length))
+			y := int16(i / (int(width) * int(length)))
+			if err := level.SetBlock(x, y, z, target); err != nil {
+				return changed, err
+			}
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+func BlockName(block byte) string {
+	if world.ValidBlock(block) {
+		return world.BLOCK_NAMES[block]
+	}
+	return fmt.Sprintf("block %d", block)
+}
+
+type BlockCount struct {
+	Block byte
+	Count int
+}
+
+// Most common blocks first
+func Histogram(level *world.Level) []BlockCount {
+	var counts [256]int
+	for _, block := range level.Blocks() {
+		counts[block]++
+	}
+	var histogram []BlockCount
+	for block, count := range counts {
+		if count > 0 {
+			histogram = append(histogram, BlockCount{Block: byte(block), Count: count})
+		}
+	}
+	slices.SortStableFunc(histogram, func(a, b BlockCount) int {
+		return b.Count - a.Count
+	})
+	return histogram
+}
+
+// Problems which would stop the level being played as is
+func Validate(level *world.Level) []string {
+	var problems []string
+	invalid := 0
+	for _, block := range level.Blocks() {
+		if !world.ValidBlock(block) {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		problems = append(problems, fmt.Sprintf("%d blocks are unknown", invalid))
+	}
+	// Standing above the top of the level is allowed
+	width, _, length := level.Size()
+	spawn := level.Spawn()
+	if spawn.X < 0 || spawn.Z < 0 || spawn.Y < 0 || spawn.X >= float32(width) || spawn.Z >= float32(length) {
+		problems = append(problems, fmt.Sprintf("Spawn %.1f,%.1f,%.1f is outside the level", spawn.X, spawn.Y, spawn.Z))
+	}
+	return problems
+}
@@ -0,0 +1,37 @@
+package formats
+
+import (
+	"testing"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+func TestRemap(t *testing.T) {
+	remap, err := parseRemap(map[string]string{"sponge": "air", "20": "stone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	level, err := world.NewLevelFromBlocks("remap", 2, 1, 2, []byte{world.BLOCK_SPONGE, world.BLOCK_GLASS, world.BLOCK_SPONGE, world.BLOCK_DIRT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := remap.Apply(level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 3 {
+		t.Fatalf("Expected 3 blocks to change, got %d", changed)
+	}
+	expected := []byte{world.BLOCK_AIR, world.BLOCK_STONE, world.BLOCK_AIR, world.BLOCK_DIRT}
+	for i, block := range level.Blocks() {
+		if block != expected[i] {
+			t.Fatalf("Expected blocks %v, got %v", expected, level.Blocks())
+		}
+	}
+
+	for _, table := range []map[string]string{{"163": "stone"}, {"stone": "163"}, {"nothing": "air"}} {
+		if _, err := parseRemap(table); err == nil {
+			t.Errorf("Accepted remap %v", table)
+		}
+	}
+}
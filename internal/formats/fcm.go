@@ -0,0 +1,242 @@
+package formats
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/nbt"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+const FCM_EXTENSION = ".fcm"
+
+const (
+	FCM_V2_IDENTIFIER = 0xFC000002
+	FCM_V3_IDENTIFIER = 0x0FC2AF40
+	FCM_V3_REVISION   = 13
+)
+
+const (
+	FCM_V2_ZONE_KEY    = "@zone"
+	FCM_V3_ZONE_GROUP  = "zones"
+	FCM_METADATA       = "fCraft"
+	FCM_MAX_STRING     = 1 << 20
+	FCM_POSITION_SCALE = 32
+)
+
+const (
+	FCM_UNSUPPORTED_VERSION = iota
+	FCM_INVALID_SIZE
+	FCM_INVALID_STRING
+)
+
+type fcmHeader struct {
+	// fCraft calls the Z axis length and the vertical axis height
+	Width, Length, Height  uint16
+	SpawnX, SpawnY, SpawnZ int16
+	Yaw, Pitch             byte
+}
+
+type fcmMetadata struct {
+	Group, Key, Value string
+}
+
+func readFcmString(reader io.Reader) (string, error) {
+	var length int32
+	if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
+		return "", err
+	}
+	if length < 0 || length > FCM_MAX_STRING {
+		return "", cerror.NewErrorf(FCM_INVALID_STRING, "Invalid string length %d", length)
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(reader, data)
+	return string(data), err
+}
+
+// Version 2 keeps its metadata uncompressed, followed by gzip compressed blocks
+func readFcmV2(reader io.Reader) ([]fcmMetadata, io.ReadCloser, error) {
+	var count uint16
+	if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+		return nil, nil, err
+	}
+	metadata := make([]fcmMetadata, 0, count)
+	for range count {
+		key, err := readFcmString(reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, err := readFcmString(reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		metadata = append(metadata, fcmMetadata{Key: key, Value: value})
+	}
+	blocks, err := gzip.NewReader(reader)
+	return metadata, blocks, err
+}
+
+// Version 3 deflates both its grouped metadata and the blocks
+func readFcmV3(reader *bufio.Reader) ([]fcmMetadata, io.ReadCloser, error) {
+	// Creation and modification times, then the level's GUID
+	if _, err := reader.Discard(4 + 4 + 16); err != nil {
+		return nil, nil, err
+	}
+	var count int32
+	if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+		return nil, nil, err
+	}
+	if count < 0 {
+		return nil, nil, cerror.NewErrorf(FCM_INVALID_SIZE, "Negative metadata count %d", count)
+	}
+	// Some versions wrote a zlib header before the raw deflate data
+	if header, err := reader.Peek(2); err == nil && header[0] == 0x78 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		reader.Discard(2)
+	}
+	decompressed := flate.NewReader(reader)
+	metadata := make([]fcmMetadata, 0, min(count, 1024))
+	for range count {
+		var entry fcmMetadata
+		var err error
+		for _, field := range []*string{&entry.Group, &entry.Key, &entry.Value} {
+			if *field, err = readFcmString(decompressed); err != nil {
+				decompressed.Close()
+				return nil, nil, err
+			}
+		}
+		metadata = append(metadata, entry)
+	}
+	return metadata, decompressed, nil
+}
+
+// Serialized as "name x1 y1 h1 x2 y2 h2 rank,included players,excluded players,..." where h is vertical
+func readFcmZone(value string, report *Report) (world.Zone, bool) {
+	parts := strings.Split(value, ",")
+	header := strings.Fields(parts[0])
+	if len(header) < 7 {
+		report.Warn("Skipped malformed zone %q", value)
+		return world.Zone{}, false
+	}
+	var bounds [6]int16
+	for i := range bounds {
+		coordinate, err := strconv.ParseInt(header[i+1], 10, 16)
+		if err != nil {
+			report.Warn("Skipped zone %s with invalid bounds", header[0])
+			return world.Zone{}, false
+		}
+		bounds[i] = int16(coordinate)
+	}
+	zone := world.Zone{
+		Name:      header[0],
+		Min:       world.BlockPos{X: min(bounds[0], bounds[3]), Y: min(bounds[2], bounds[5]), Z: min(bounds[1], bounds[4])},
+		Max:       world.BlockPos{X: max(bounds[0], bounds[3]), Y: max(bounds[2], bounds[5]), Z: max(bounds[1], bounds[4])},
+		Protected: true,
+	}
+	if len(parts) > 1 {
+		zone.Builders = strings.Fields(parts[1])
+	}
+	if len(header) > 7 {
+		// Ranks are server specific, so only the players listed by name carry over
+		report.Warn("Zone %s allowed rank %s to build, which can't be converted; only its %d included players may build", zone.Name, header[7], len(zone.Builders))
+	}
+	return zone, true
+}
+
+// fCraft zones become protected zones, and other metadata is kept so it isn't lost
+func ReadFcm(name string, reader io.Reader) (*world.Level, *Report, error) {
+	report := &Report{}
+	buffered := bufio.NewReader(reader)
+	var identifier uint32
+	if err := binary.Read(buffered, binary.LittleEndian, &identifier); err != nil {
+		return nil, nil, err
+	}
+	if identifier == FCM_V3_IDENTIFIER {
+		revision, err := buffered.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		if revision != FCM_V3_REVISION {
+			return nil, nil, cerror.NewErrorf(FCM_UNSUPPORTED_VERSION, "Unsupported fCraft map revision %d", revision)
+		}
+	} else if identifier != FCM_V2_IDENTIFIER {
+		return nil, nil, cerror.NewError(FCM_UNSUPPORTED_VERSION, "Not an fCraft version 2 or 3 map")
+	}
+	var header fcmHeader
+	if err := binary.Read(buffered, binary.LittleEndian, &header); err != nil {
+		return nil, nil, err
+	}
+	if !world.ValidLevelSize(int(header.Width), int(header.Height), int(header.Length)) {
+		return nil, nil, cerror.NewErrorf(FCM_INVALID_SIZE, "Invalid level size %dx%dx%d", header.Width, header.Height, header.Length)
+	}
+
+	var metadata []fcmMetadata
+	var blockReader io.ReadCloser
+	var err error
+	if identifier == FCM_V3_IDENTIFIER {
+		metadata, blockReader, err = readFcmV3(buffered)
+	} else {
+		metadata, blockReader, err = readFcmV2(buffered)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer blockReader.Close()
+	width, height, length := int16(header.Width), int16(header.Height), int16(header.Length)
+	blocks := make([]byte, int(width)*int(height)*int(length))
+	if _, err := io.ReadFull(blockReader, blocks); err != nil {
+		return nil, nil, err
+	}
+	for i, block := range blocks {
+		if !world.ValidBlock(block) {
+			blocks[i] = FALLBACK_BLOCK
+			report.Replaced("unknown blocks")
+		}
+	}
+	level, err := world.NewLevelFromBlocks(name, width, height, length, blocks)
+	if err != nil {
+		return nil, nil, err
+	}
+	level.SetSpawn(world.Position{
+		X:     float32(header.SpawnX) / FCM_POSITION_SCALE,
+		Y:     float32(header.SpawnZ) / FCM_POSITION_SCALE,
+		Z:     float32(header.SpawnY) / FCM_POSITION_SCALE,
+		Yaw:   header.Yaw,
+		Pitch: header.Pitch,
+	})
+
+	var kept nbt.Compound
+	for _, entry := range metadata {
+		if entry.Key == FCM_V2_ZONE_KEY || entry.Group == FCM_V3_ZONE_GROUP {
+			if zone, ok := readFcmZone(entry.Value, report); ok {
+				behaviors := level.Behaviors()
+				behaviors.Zones = append(behaviors.Zones, zone)
+			}
+			continue
+		}
+		if kept == nil {
+			kept = softwareMetadata(level, FCM_METADATA)
+		}
+		key := entry.Key
+		if entry.Group != "" {
+			key = entry.Group + "." + entry.Key
+		}
+		kept[key] = entry.Value
+	}
+	return level, report, nil
+}
+
+func LoadFcm(path string) (*world.Level, *Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	return ReadFcm(levelName(path), file)
+}
@@ -0,0 +1,208 @@
+package formats
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/nbt"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+// fCraft's vertical axis is its Z, so the spawn's height is SpawnZ
+var fcmTestHeader = fcmHeader{
+	Width:  10,
+	Length: 12,
+	Height: 8,
+	SpawnX: 5*FCM_POSITION_SCALE + 16,
+	SpawnY: 6*FCM_POSITION_SCALE + 16,
+	SpawnZ: 3*FCM_POSITION_SCALE + 51,
+	Yaw:    64,
+	Pitch:  32,
+}
+
+// Bounds are x1 y1 h1 x2 y2 h2, with fCraft's y being the level's Z
+const (
+	fcmRankedZone = "spawnzone 1 2 0 5 6 3 op,alice bob,eve"
+	fcmPlainZone  = "free 4 0 7 2 3 1"
+)
+
+func fcmTestBlocks() []byte {
+	blocks := make([]byte, int(fcmTestHeader.Width)*int(fcmTestHeader.Length)*int(fcmTestHeader.Height))
+	for i := range blocks {
+		blocks[i] = byte(i % world.BLOCK_COUNT)
+	}
+	return blocks
+}
+
+func appendFcmString(data []byte, value string) []byte {
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(value)))
+	return append(data, value...)
+}
+
+// Builds a version 2 map, with uncompressed key value metadata followed by gzip compressed blocks
+func buildFcmV2(t *testing.T, header fcmHeader, blocks []byte, metadata []fcmMetadata) []byte {
+	t.Helper()
+	data := binary.LittleEndian.AppendUint32(nil, FCM_V2_IDENTIFIER)
+	data, _ = binary.Append(data, binary.LittleEndian, header)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(metadata)))
+	for _, entry := range metadata {
+		data = appendFcmString(data, entry.Key)
+		data = appendFcmString(data, entry.Value)
+	}
+	buffer := bytes.NewBuffer(data)
+	compressed := gzip.NewWriter(buffer)
+	compressed.Write(blocks)
+	if err := compressed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// Builds a version 3 map, optionally with the zlib header some fCraft versions wrote before the deflate data
+func buildFcmV3(t *testing.T, header fcmHeader, blocks []byte, metadata []fcmMetadata, zlibHeader bool) []byte {
+	t.Helper()
+	data := binary.LittleEndian.AppendUint32(nil, FCM_V3_IDENTIFIER)
+	data = append(data, FCM_V3_REVISION)
+	data, _ = binary.Append(data, binary.LittleEndian, header)
+	// Creation and modification times, then the GUID
+	data = append(data, make([]byte, 4+4+16)...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(metadata)))
+	if zlibHeader {
+		data = append(data, 0x78, 0x9C)
+	}
+	var body []byte
+	for _, entry := range metadata {
+		body = appendFcmString(body, entry.Group)
+		body = appendFcmString(body, entry.Key)
+		body = appendFcmString(body, entry.Value)
+	}
+	body = append(body, blocks...)
+	buffer := bytes.NewBuffer(data)
+	compressed, err := flate.NewWriter(buffer, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed.Write(body)
+	if err := compressed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func readFcmBytes(t *testing.T, data []byte) (*world.Level, *Report) {
+	t.Helper()
+	level, report, err := ReadFcm("test", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return level, report
+}
+
+func TestFcm(t *testing.T) {
+	blocks := fcmTestBlocks()
+	v2Metadata := []fcmMetadata{
+		{Key: FCM_V2_ZONE_KEY, Value: fcmRankedZone},
+		{Key: FCM_V2_ZONE_KEY, Value: fcmPlainZone},
+		{Key: "Author", Value: "someone"},
+	}
+	v3Metadata := []fcmMetadata{
+		{Group: FCM_V3_ZONE_GROUP, Key: "spawnzone", Value: fcmRankedZone},
+		{Group: FCM_V3_ZONE_GROUP, Key: "free", Value: fcmPlainZone},
+		{Group: "Options", Key: "Author", Value: "someone"},
+	}
+	tests := []struct {
+		name      string
+		data      []byte
+		authorKey string
+	}{
+		{"V2", buildFcmV2(t, fcmTestHeader, blocks, v2Metadata), "Author"},
+		{"V3", buildFcmV3(t, fcmTestHeader, blocks, v3Metadata, false), "Options.Author"},
+		{"V3ZlibHeader", buildFcmV3(t, fcmTestHeader, blocks, v3Metadata, true), "Options.Author"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			level, report := readFcmBytes(t, test.data)
+			if width, height, length := level.Size(); width != 10 || height != 8 || length != 12 {
+				t.Fatalf("Expected size 10x8x12, got %dx%dx%d", width, height, length)
+			}
+			if !bytes.Equal(level.Blocks(), blocks) {
+				t.Fatal("Blocks differ from the map")
+			}
+			expectedSpawn := world.Position{X: 5.5, Y: 3 + 51.0/FCM_POSITION_SCALE, Z: 6.5, Yaw: 64, Pitch: 32}
+			if spawn := level.Spawn(); spawn != expectedSpawn {
+				t.Fatalf("Expected spawn %+v, got %+v", expectedSpawn, spawn)
+			}
+
+			expectedZones := []world.Zone{
+				{
+					Name:      "spawnzone",
+					Min:       world.BlockPos{X: 1, Y: 0, Z: 2},
+					Max:       world.BlockPos{X: 5, Y: 3, Z: 6},
+					Protected: true,
+					Builders:  []string{"alice", "bob"},
+				},
+				{
+					Name:      "free",
+					Min:       world.BlockPos{X: 2, Y: 1, Z: 0},
+					Max:       world.BlockPos{X: 4, Y: 7, Z: 3},
+					Protected: true,
+				},
+			}
+			if zones := level.Behaviors().Zones; !reflect.DeepEqual(zones, expectedZones) {
+				t.Fatalf("Expected zones %+v, got %+v", expectedZones, zones)
+			}
+			if !hasWarning(report, "allowed rank op to build") {
+				t.Errorf("Missing rank warning in %v", report.Warnings())
+			}
+			if len(report.Warnings()) != 1 {
+				t.Errorf("Expected only the rank warning, got %v", report.Warnings())
+			}
+			if author, _ := nbt.Get[string](existingMetadata(level, FCM_METADATA), test.authorKey); author != "someone" {
+				t.Fatalf("Expected %s to be kept as someone, got %q", test.authorKey, author)
+			}
+		})
+	}
+}
+
+func TestFcmUnknownBlocksUseFallback(t *testing.T) {
+	blocks := fcmTestBlocks()
+	blocks[3] = 200
+	level, report := readFcmBytes(t, buildFcmV3(t, fcmTestHeader, blocks, nil, false))
+	if block := level.Blocks()[3]; block != FALLBACK_BLOCK {
+		t.Fatalf("Expected unknown block to become %d, got %d", FALLBACK_BLOCK, block)
+	}
+	if !hasWarning(report, "1 unknown blocks") {
+		t.Fatalf("Missing replacement in %v", report.Warnings())
+	}
+}
+
+func TestFcmRejectsInvalid(t *testing.T) {
+	oversized := fcmTestHeader
+	oversized.Width, oversized.Length, oversized.Height = 32767, 32767, 32767
+	revision := buildFcmV3(t, fcmTestHeader, fcmTestBlocks(), nil, false)
+	revision[4] = FCM_V3_REVISION + 1
+	tests := []struct {
+		name string
+		data []byte
+		code int
+	}{
+		{"Oversized", buildFcmV2(t, oversized, nil, nil), FCM_INVALID_SIZE},
+		{"UnknownRevision", revision, FCM_UNSUPPORTED_VERSION},
+		{"UnknownIdentifier", []byte{1, 2, 3, 4}, FCM_UNSUPPORTED_VERSION},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := ReadFcm("test", bytes.NewReader(test.data))
+			var coded cerror.CodedError
+			if !errors.As(err, &coded) || coded.Code != test.code {
+				t.Fatalf("Expected error code %d, got %v", test.code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package formats
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/nbt"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+// Used in place of blocks which can't be represented
+const FALLBACK_BLOCK = world.BLOCK_STONE
+
+// Compound in a level's extra ClassicWorld tags where other software keeps its own data
+const METADATA_TAG = world.CLASSICWORLD_METADATA
+
+// Anything which couldn't be imported, for the user to review
+type Report struct {
+	warnings []string
+	// Counts of replaced blocks, keyed by reason
+	replaced map[string]int
+}
+
+func (report *Report) Warn(format string, args ...any) {
+	report.warnings = append(report.warnings, fmt.Sprintf(format, args...))
+}
+
+func (report *Report) Replaced(reason string) {
+	if report.replaced == nil {
+		report.replaced = make(map[string]int)
+	}
+	report.replaced[reason]++
+}
+
+func (report *Report) Warnings() []string {
+	warnings := slices.Clone(report.warnings)
+	reasons := make([]string, 0, len(report.replaced))
+	for reason := range report.replaced {
+		reasons = append(reasons, reason)
+	}
+	slices.Sort(reasons)
+	for _, reason := range reasons {
+		warnings = append(warnings, fmt.Sprintf("%d %s replaced with %s", report.replaced[reason], reason, world.BLOCK_NAMES[FALLBACK_BLOCK]))
+	}
+	return warnings
+}
+
+func (report *Report) String() string {
+	return strings.Join(report.Warnings(), "\n")
+}
+
+// Software specific metadata compound, created if missing
+func softwareMetadata(level *world.Level, software string) nbt.Compound {
+	metadata, ok := nbt.Get[nbt.Compound](level.Extra, METADATA_TAG)
+	if !ok {
+		metadata = make(nbt.Compound)
+		level.Extra[METADATA_TAG] = metadata
+	}
+	compound, ok := nbt.Get[nbt.Compound](metadata, software)
+	if !ok {
+		compound = make(nbt.Compound)
+		metadata[software] = compound
+	}
+	return compound
+}
+
+// Software specific metadata compound, or nil if the level has none
+func existingMetadata(level *world.Level, software string) nbt.Compound {
+	metadata, _ := nbt.Get[nbt.Compound](level.Extra, METADATA_TAG)
+	compound, _ := nbt.Get[nbt.Compound](metadata, software)
+	return compound
+}
+
+// Level name from a file path, without the directory or extension
+func levelName(path string) string {
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// Like io.ReadFull, but treats hitting the end of the data as a missing section rather than an error
+func readOptional(reader io.Reader, buffer []byte) (bool, error) {
+	_, err := io.ReadFull(reader, buffer)
+	if err == io.EOF {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,468 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+)
+
+// Just enough of Java's object serialization stream to read the objects Classic saved levels with
+
+const (
+	JAVA_STREAM_MAGIC   = 0xACED
+	JAVA_STREAM_VERSION = 5
+	JAVA_BASE_HANDLE    = 0x7E0000
+)
+
+const (
+	JAVA_TC_NULL           = 0x70
+	JAVA_TC_REFERENCE      = 0x71
+	JAVA_TC_CLASSDESC      = 0x72
+	JAVA_TC_OBJECT         = 0x73
+	JAVA_TC_STRING         = 0x74
+	JAVA_TC_ARRAY          = 0x75
+	JAVA_TC_CLASS          = 0x76
+	JAVA_TC_BLOCKDATA      = 0x77
+	JAVA_TC_ENDBLOCKDATA   = 0x78
+	JAVA_TC_RESET          = 0x79
+	JAVA_TC_BLOCKDATALONG  = 0x7A
+	JAVA_TC_LONGSTRING     = 0x7C
+	JAVA_TC_PROXYCLASSDESC = 0x7D
+	JAVA_TC_ENUM           = 0x7E
+)
+
+const (
+	JAVA_SC_WRITE_METHOD   = 0x01
+	JAVA_SC_SERIALIZABLE   = 0x02
+	JAVA_SC_EXTERNALIZABLE = 0x04
+	JAVA_SC_BLOCK_DATA     = 0x08
+)
+
+const (
+	JAVA_INVALID_STREAM = iota
+	JAVA_UNSUPPORTED
+	JAVA_INVALID_HANDLE
+)
+
+// Deeper nesting than a level's object graph uses, to stop malicious files exhausting the stack
+const JAVA_MAX_DEPTH = 256
+
+type javaField struct {
+	Type      byte
+	Name      string
+	ClassName string
+}
+
+type javaClass struct {
+	Name   string
+	Flags  byte
+	Fields []javaField
+	Super  *javaClass
+}
+
+// Field values are Go equivalents of the Java types, with objects as *javaObject and arrays as slices
+type javaObject struct {
+	Class  *javaClass
+	Fields map[string]any
+}
+
+// Whether the object's class or any superclass has the given name
+func (object *javaObject) InstanceOf(name string) bool {
+	for class := object.Class; class != nil; class = class.Super {
+		if class.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+type javaBlockData []byte
+
+// Marks the end of a class's custom serialization data
+type javaEndBlockData struct{}
+
+type javaReader struct {
+	reader  io.Reader
+	handles []any
+}
+
+func javaInvalid(format string, args ...any) error {
+	return cerror.NewErrorf(JAVA_INVALID_STREAM, format, args...)
+}
+
+func (reader *javaReader) read(value any) error {
+	return binary.Read(reader.reader, binary.BigEndian, value)
+}
+
+func (reader *javaReader) byte() (byte, error) {
+	var value byte
+	err := reader.read(&value)
+	return value, err
+}
+
+func (reader *javaReader) data(length int64) ([]byte, error) {
+	var buffer bytes.Buffer
+	if _, err := io.CopyN(&buffer, reader.reader, length); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Modified UTF-8 only differs for null characters and characters outside the basic plane, which level names don't use
+func (reader *javaReader) utf() (string, error) {
+	var length uint16
+	if err := reader.read(&length); err != nil {
+		return "", err
+	}
+	data, err := reader.data(int64(length))
+	return string(data), err
+}
+
+func (reader *javaReader) longUTF() (string, error) {
+	var length int64
+	if err := reader.read(&length); err != nil {
+		return "", err
+	}
+	if length < 0 {
+		return "", javaInvalid("Negative string length %d", length)
+	}
+	data, err := reader.data(length)
+	return string(data), err
+}
+
+func (reader *javaReader) newHandle(value any) int {
+	reader.handles = append(reader.handles, value)
+	return len(reader.handles) - 1
+}
+
+func (reader *javaReader) reference() (any, error) {
+	var handle int32
+	if err := reader.read(&handle); err != nil {
+		return nil, err
+	}
+	index := int(handle) - JAVA_BASE_HANDLE
+	if index < 0 || index >= len(reader.handles) {
+		return nil, cerror.NewErrorf(JAVA_INVALID_HANDLE, "Reference to unknown handle 0x%X", handle)
+	}
+	return reader.handles[index], nil
+}
+
+func (reader *javaReader) string(tag byte) (string, error) {
+	var value string
+	var err error
+	if tag == JAVA_TC_LONGSTRING {
+		value, err = reader.longUTF()
+	} else {
+		value, err = reader.utf()
+	}
+	if err != nil {
+		return "", err
+	}
+	reader.newHandle(value)
+	return value, nil
+}
+
+// Skips annotations written by custom serialization until the end marker
+func (reader *javaReader) annotations(depth int) error {
+	for {
+		value, err := reader.content(depth + 1)
+		if err != nil {
+			return err
+		}
+		if _, ok := value.(javaEndBlockData); ok {
+			return nil
+		}
+	}
+}
+
+func (reader *javaReader) classDescription(depth int) (*javaClass, error) {
+	tag, err := reader.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case JAVA_TC_NULL:
+		return nil, nil
+	case JAVA_TC_REFERENCE:
+		value, err := reader.reference()
+		if err != nil {
+			return nil, err
+		}
+		class, ok := value.(*javaClass)
+		if !ok {
+			return nil, cerror.NewErrorf(JAVA_INVALID_HANDLE, "Handle is a %T rather than a class", value)
+		}
+		return class, nil
+	case JAVA_TC_CLASSDESC:
+		class := &javaClass{}
+		if class.Name, err = reader.utf(); err != nil {
+			return nil, err
+		}
+		var serialVersion int64
+		if err := reader.read(&serialVersion); err != nil {
+			return nil, err
+		}
+		reader.newHandle(class)
+		if class.Flags, err = reader.byte(); err != nil {
+			return nil, err
+		}
+		var fieldCount uint16
+		if err := reader.read(&fieldCount); err != nil {
+			return nil, err
+		}
+		for range fieldCount {
+			var field javaField
+			if field.Type, err = reader.byte(); err != nil {
+				return nil, err
+			}
+			if field.Name, err = reader.utf(); err != nil {
+				return nil, err
+			}
+			if field.Type == 'L' || field.Type == '[' {
+				className, err := reader.content(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				field.ClassName, _ = className.(string)
+			}
+			class.Fields = append(class.Fields, field)
+		}
+		if err := reader.annotations(depth); err != nil {
+			return nil, err
+		}
+		if class.Super, err = reader.classDescription(depth + 1); err != nil {
+			return nil, err
+		}
+		return class, nil
+	case JAVA_TC_PROXYCLASSDESC:
+		return nil, cerror.NewError(JAVA_UNSUPPORTED, "Proxy classes are unsupported")
+	default:
+		return nil, javaInvalid("Expected a class description, got tag 0x%02X", tag)
+	}
+}
+
+func (reader *javaReader) value(fieldType byte, depth int) (any, error) {
+	switch fieldType {
+	case 'B':
+		var value int8
+		return value, reader.read(&value)
+	case 'C':
+		var value uint16
+		return value, reader.read(&value)
+	case 'D':
+		var value float64
+		return value, reader.read(&value)
+	case 'F':
+		var value float32
+		return value, reader.read(&value)
+	case 'I':
+		var value int32
+		return value, reader.read(&value)
+	case 'J':
+		var value int64
+		return value, reader.read(&value)
+	case 'S':
+		var value int16
+		return value, reader.read(&value)
+	case 'Z':
+		value, err := reader.byte()
+		return value != 0, err
+	case 'L', '[':
+		return reader.content(depth + 1)
+	default:
+		return nil, javaInvalid("Unknown field type %q", fieldType)
+	}
+}
+
+func (reader *javaReader) object(depth int) (*javaObject, error) {
+	class, err := reader.classDescription(depth)
+	if err != nil {
+		return nil, err
+	}
+	if class == nil {
+		return nil, javaInvalid("Object without a class")
+	}
+	object := &javaObject{Class: class, Fields: make(map[string]any)}
+	reader.newHandle(object)
+	// Superclass data comes first
+	var hierarchy []*javaClass
+	for current := class; current != nil; current = current.Super {
+		if len(hierarchy) > JAVA_MAX_DEPTH {
+			return nil, javaInvalid("Class %s has a circular hierarchy", class.Name)
+		}
+		hierarchy = append([]*javaClass{current}, hierarchy...)
+	}
+	for _, current := range hierarchy {
+		if current.Flags&JAVA_SC_EXTERNALIZABLE != 0 {
+			if current.Flags&JAVA_SC_BLOCK_DATA == 0 {
+				return nil, cerror.NewErrorf(JAVA_UNSUPPORTED, "Externalizable class %s uses the old protocol", current.Name)
+			}
+			if err := reader.annotations(depth); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, field := range current.Fields {
+			value, err := reader.value(field.Type, depth)
+			if err != nil {
+				return nil, err
+			}
+			object.Fields[field.Name] = value
+		}
+		if current.Flags&JAVA_SC_WRITE_METHOD != 0 {
+			if err := reader.annotations(depth); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return object, nil
+}
+
+func (reader *javaReader) array(depth int) (any, error) {
+	class, err := reader.classDescription(depth)
+	if err != nil {
+		return nil, err
+	}
+	if class == nil || len(class.Name) < 2 || class.Name[0] != '[' {
+		return nil, javaInvalid("Array without an array class")
+	}
+	var length int32
+	if err := reader.read(&length); err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		return nil, javaInvalid("Negative array length %d", length)
+	}
+	handle := reader.newHandle(nil)
+	elementType := class.Name[1]
+	if elementType == 'B' {
+		data, err := reader.data(int64(length))
+		if err != nil {
+			return nil, err
+		}
+		reader.handles[handle] = data
+		return data, nil
+	}
+	values := make([]any, 0, min(length, 1024))
+	for range length {
+		value, err := reader.value(elementType, depth)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	reader.handles[handle] = values
+	return values, nil
+}
+
+// Reads the next item in the stream, returning javaEndBlockData for the end of annotations
+func (reader *javaReader) content(depth int) (any, error) {
+	if depth > JAVA_MAX_DEPTH {
+		return nil, javaInvalid("Objects nested more than %d deep", JAVA_MAX_DEPTH)
+	}
+	tag, err := reader.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case JAVA_TC_NULL:
+		return nil, nil
+	case JAVA_TC_REFERENCE:
+		return reader.reference()
+	case JAVA_TC_OBJECT:
+		return reader.object(depth)
+	case JAVA_TC_STRING, JAVA_TC_LONGSTRING:
+		return reader.string(tag)
+	case JAVA_TC_ARRAY:
+		return reader.array(depth)
+	case JAVA_TC_CLASS:
+		class, err := reader.classDescription(depth)
+		if err != nil {
+			return nil, err
+		}
+		reader.newHandle(class)
+		return class, nil
+	case JAVA_TC_ENUM:
+		if _, err := reader.classDescription(depth); err != nil {
+			return nil, err
+		}
+		handle := reader.newHandle(nil)
+		name, err := reader.content(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		reader.handles[handle] = name
+		return name, nil
+	case JAVA_TC_BLOCKDATA:
+		length, err := reader.byte()
+		if err != nil {
+			return nil, err
+		}
+		data, err := reader.data(int64(length))
+		return javaBlockData(data), err
+	case JAVA_TC_BLOCKDATALONG:
+		var length int32
+		if err := reader.read(&length); err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, javaInvalid("Negative block data length %d", length)
+		}
+		data, err := reader.data(int64(length))
+		return javaBlockData(data), err
+	case JAVA_TC_ENDBLOCKDATA:
+		return javaEndBlockData{}, nil
+	case JAVA_TC_RESET:
+		reader.handles = reader.handles[:0]
+		return reader.content(depth)
+	default:
+		return nil, javaInvalid("Unknown tag 0x%02X", tag)
+	}
+}
+
+// Reads the first object in a serialization stream
+func readJavaObject(input io.Reader) (*javaObject, error) {
+	reader := &javaReader{reader: bufio.NewReader(input)}
+	var magic, version uint16
+	if err := reader.read(&magic); err != nil {
+		return nil, err
+	}
+	if err := reader.read(&version); err != nil {
+		return nil, err
+	}
+	if magic != JAVA_STREAM_MAGIC || version != JAVA_STREAM_VERSION {
+		return nil, javaInvalid("Not a Java serialization stream")
+	}
+	value, err := reader.content(0)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := value.(*javaObject)
+	if !ok {
+		return nil, javaInvalid("Stream starts with a %T rather than an object", value)
+	}
+	return object, nil
+}
+
+// Numeric field as an int, accepting any of Java's integer types
+func javaInt(object *javaObject, name string) (int, bool) {
+	switch value := object.Fields[name].(type) {
+	case int8:
+		return int(value), true
+	case int16:
+		return int(value), true
+	case int32:
+		return int(value), true
+	case int64:
+		if value < math.MinInt32 || value > math.MaxInt32 {
+			return 0, false
+		}
+		return int(value), true
+	default:
+		return 0, false
+	}
+}
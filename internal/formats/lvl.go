@@ -0,0 +1,351 @@
+package formats
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+const LVL_EXTENSION = ".lvl"
+
+// Written before the header by every MCGalaxy version which stores permissions
+const LVL_VERSION = 1874
+
+const (
+	LVL_SECTION_CUSTOM_BLOCKS = 0xBD
+	LVL_SECTION_PHYSICS       = 0xFC
+	LVL_SECTION_BLOCK_DEFS    = 0x51
+)
+
+// Blocks in the main array which say the real block is in a custom block layer
+const (
+	LVL_CUSTOM_BLOCK   = 163
+	LVL_CUSTOM_BLOCK_2 = 198
+	LVL_CUSTOM_BLOCK_3 = 199
+)
+
+// Blocks at or above this in the main array are MCGalaxy's own physics blocks
+const LVL_PHYSICS_BLOCKS_START = 66
+
+// Each physics entry is a block index and its physics data, both 32 bit
+const LVL_PHYSICS_ENTRY_SIZE = 8
+
+const LVL_CHUNK_SIZE = 16
+
+const (
+	LVL_INVALID_SIZE = iota
+)
+
+const LVL_METADATA = "MCGalaxy"
+
+type lvlHeader struct {
+	Width, Length, Height    uint16
+	SpawnX, SpawnZ, SpawnY   uint16
+	Yaw, Pitch               byte
+	VisitAccess, BuildAccess byte
+}
+
+func lvlChunks(size int16) int {
+	return (int(size) + LVL_CHUNK_SIZE - 1) / LVL_CHUNK_SIZE
+}
+
+func lvlChunkIndex(width, length, x, y, z int16) (chunk int, offset int) {
+	chunkX, chunkY, chunkZ := int(x)/LVL_CHUNK_SIZE, int(y)/LVL_CHUNK_SIZE, int(z)/LVL_CHUNK_SIZE
+	chunk = (chunkY*lvlChunks(length)+chunkZ)*lvlChunks(width) + chunkX
+	localX, localY, localZ := int(x)%LVL_CHUNK_SIZE, int(y)%LVL_CHUNK_SIZE, int(z)%LVL_CHUNK_SIZE
+	return chunk, (localY*LVL_CHUNK_SIZE+localZ)*LVL_CHUNK_SIZE + localX
+}
+
+func readLvlHeader(reader io.Reader) (lvlHeader, bool, error) {
+	var header lvlHeader
+	var first uint16
+	if err := binary.Read(reader, binary.LittleEndian, &first); err != nil {
+		return header, false, err
+	}
+	versioned := first == LVL_VERSION
+	if versioned {
+		return header, true, binary.Read(reader, binary.LittleEndian, &header)
+	}
+	// Older files start straight away with the width, and have no permissions
+	header.Width = first
+	var rest [14]byte
+	if _, err := io.ReadFull(reader, rest[:]); err != nil {
+		return header, false, err
+	}
+	header.Length = binary.LittleEndian.Uint16(rest[0:])
+	header.Height = binary.LittleEndian.Uint16(rest[2:])
+	header.SpawnX = binary.LittleEndian.Uint16(rest[4:])
+	header.SpawnZ = binary.LittleEndian.Uint16(rest[6:])
+	header.SpawnY = binary.LittleEndian.Uint16(rest[8:])
+	header.Yaw, header.Pitch = rest[10], rest[11]
+	return header, false, nil
+}
+
+// Replaces custom block markers with the block in the custom layer, which is the id clients know the block by
+func readLvlCustomBlocks(reader io.Reader, width, height, length int16, blocks []byte, report *Report) error {
+	chunkCount := lvlChunks(width) * lvlChunks(height) * lvlChunks(length)
+	chunks := make([][]byte, chunkCount)
+	flag := make([]byte, 1)
+	for i := range chunks {
+		present, err := readOptional(reader, flag)
+		if err != nil {
+			return err
+		}
+		if !present {
+			report.Warn("Custom block section ends early, after %d of %d chunks", i, chunkCount)
+			break
+		}
+		if flag[0] != 1 {
+			continue
+		}
+		chunks[i] = make([]byte, LVL_CHUNK_SIZE*LVL_CHUNK_SIZE*LVL_CHUNK_SIZE)
+		if _, err := io.ReadFull(reader, chunks[i]); err != nil {
+			return err
+		}
+	}
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				index := (int(y)*int(length)+int(z))*int(width) + int(x)
+				if blocks[index] != LVL_CUSTOM_BLOCK {
+					continue
+				}
+				chunk, offset := lvlChunkIndex(width, length, x, y, z)
+				if chunks[chunk] == nil {
+					blocks[index] = FALLBACK_BLOCK
+					report.Replaced("custom blocks missing from the custom block section")
+					continue
+				}
+				blocks[index] = chunks[chunk][offset]
+			}
+		}
+	}
+	return nil
+}
+
+// Skips the physics section using its entry count, returning false if the data ends first
+func skipLvlPhysics(reader io.Reader) (bool, error) {
+	count := make([]byte, 4)
+	present, err := readOptional(reader, count)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	if !present {
+		return false, nil
+	}
+	entries := int64(int32(binary.LittleEndian.Uint32(count)))
+	if entries < 0 {
+		return false, nil
+	}
+	skipped, err := io.CopyN(io.Discard, reader, entries*LVL_PHYSICS_ENTRY_SIZE)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return skipped == entries*LVL_PHYSICS_ENTRY_SIZE, nil
+}
+
+// Blocks which can't be represented are replaced, and anything else not understood is added to the report
+func ReadLvl(name string, reader io.Reader) (*world.Level, *Report, error) {
+	report := &Report{}
+	decompressed, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer decompressed.Close()
+	header, versioned, err := readLvlHeader(decompressed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !world.ValidLevelSize(int(header.Width), int(header.Height), int(header.Length)) {
+		return nil, nil, cerror.NewErrorf(LVL_INVALID_SIZE, "Invalid level size %dx%dx%d", header.Width, header.Height, header.Length)
+	}
+	width, height, length := int16(header.Width), int16(header.Height), int16(header.Length)
+	blocks := make([]byte, int(width)*int(height)*int(length))
+	if _, err := io.ReadFull(decompressed, blocks); err != nil {
+		return nil, nil, err
+	}
+
+	for i, block := range blocks {
+		switch {
+		case block == LVL_CUSTOM_BLOCK:
+		case block == LVL_CUSTOM_BLOCK_2 || block == LVL_CUSTOM_BLOCK_3:
+			blocks[i] = FALLBACK_BLOCK
+			report.Replaced("custom blocks with ids above 255")
+		case block >= LVL_PHYSICS_BLOCKS_START:
+			blocks[i] = FALLBACK_BLOCK
+			report.Replaced("MCGalaxy physics blocks")
+		}
+	}
+
+	customBlocks := false
+	section := make([]byte, 1)
+	for {
+		present, err := readOptional(decompressed, section)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !present {
+			break
+		}
+		if section[0] == LVL_SECTION_CUSTOM_BLOCKS && !customBlocks {
+			if err := readLvlCustomBlocks(decompressed, width, height, length, blocks, report); err != nil {
+				return nil, nil, err
+			}
+			customBlocks = true
+			continue
+		}
+		if section[0] == LVL_SECTION_PHYSICS {
+			skipped, err := skipLvlPhysics(decompressed)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !skipped {
+				report.Warn("Physics section ends early")
+				break
+			}
+			report.Warn("Physics state is unsupported and was skipped")
+			continue
+		}
+		// Neither have a length to skip by, so nothing after them can be read
+		switch section[0] {
+		case LVL_SECTION_BLOCK_DEFS:
+			report.Warn("Level block definitions are unsupported, so they and anything after them were skipped")
+		default:
+			report.Warn("Unknown section 0x%02X and anything after it were skipped", section[0])
+		}
+		break
+	}
+	if !customBlocks {
+		for i, block := range blocks {
+			if block == LVL_CUSTOM_BLOCK {
+				blocks[i] = FALLBACK_BLOCK
+				report.Replaced("custom blocks missing from the custom block section")
+			}
+		}
+	}
+	// The custom block layer can hold blocks past the CustomBlocks ones, which are still unknown
+	for i, block := range blocks {
+		if !world.ValidBlock(block) {
+			blocks[i] = FALLBACK_BLOCK
+			report.Replaced("unknown blocks")
+		}
+	}
+	level, err := world.NewLevelFromBlocks(name, width, height, length, blocks)
+	if err != nil {
+		return nil, nil, err
+	}
+	level.SetSpawn(world.Position{
+		X:     float32(header.SpawnX) + 0.5,
+		Y:     float32(header.SpawnY) + world.PLAYER_HEIGHT,
+		Z:     float32(header.SpawnZ) + 0.5,
+		Yaw:   header.Yaw,
+		Pitch: header.Pitch,
+	})
+	if versioned {
+		metadata := softwareMetadata(level, LVL_METADATA)
+		metadata["VisitAccess"] = int8(header.VisitAccess)
+		metadata["BuildAccess"] = int8(header.BuildAccess)
+	}
+	return level, report, nil
+}
+
+func clampUShort(value float32) uint16 {
+	return uint16(max(0, min(math.MaxUint16, math.Floor(float64(value)))))
+}
+
+// Blocks above the range MCGalaxy keeps in the main array are stored in the custom block layer
+func WriteLvl(level *world.Level, writer io.Writer) error {
+	width, height, length := level.Size()
+	spawn := level.Spawn()
+	header := lvlHeader{
+		Width:  uint16(width),
+		Length: uint16(length),
+		Height: uint16(height),
+		SpawnX: clampUShort(spawn.X),
+		SpawnZ: clampUShort(spawn.Z),
+		SpawnY: clampUShort(spawn.Y - world.PLAYER_HEIGHT),
+		Yaw:    spawn.Yaw,
+		Pitch:  spawn.Pitch,
+	}
+	metadata := existingMetadata(level, LVL_METADATA)
+	if visit, ok := metadata["VisitAccess"].(int8); ok {
+		header.VisitAccess = byte(visit)
+	}
+	if build, ok := metadata["BuildAccess"].(int8); ok {
+		header.BuildAccess = byte(build)
+	}
+
+	blocks := level.Blocks()
+	chunks := make([][]byte, lvlChunks(width)*lvlChunks(height)*lvlChunks(length))
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				index := (int(y)*int(length)+int(z))*int(width) + int(x)
+				if blocks[index] < LVL_PHYSICS_BLOCKS_START {
+					continue
+				}
+				chunk, offset := lvlChunkIndex(width, length, x, y, z)
+				if chunks[chunk] == nil {
+					chunks[chunk] = make([]byte, LVL_CHUNK_SIZE*LVL_CHUNK_SIZE*LVL_CHUNK_SIZE)
+				}
+				chunks[chunk][offset] = blocks[index]
+				blocks[index] = LVL_CUSTOM_BLOCK
+			}
+		}
+	}
+
+	compressed := gzip.NewWriter(writer)
+	if err := binary.Write(compressed, binary.LittleEndian, uint16(LVL_VERSION)); err != nil {
+		return err
+	}
+	if err := binary.Write(compressed, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if _, err := compressed.Write(blocks); err != nil {
+		return err
+	}
+	if _, err := compressed.Write([]byte{LVL_SECTION_CUSTOM_BLOCKS}); err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if chunk == nil {
+			if _, err := compressed.Write([]byte{0}); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := compressed.Write([]byte{1}); err != nil {
+			return err
+		}
+		if _, err := compressed.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return compressed.Close()
+}
+
+func LoadLvl(path string) (*world.Level, *Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	return ReadLvl(levelName(path), file)
+}
+
+func SaveLvl(level *world.Level, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteLvl(level, file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,209 @@
+package formats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/nbt"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+// Builds an MCGalaxy level file by hand, with the given sections after the blocks
+func buildLvl(t *testing.T, header lvlHeader, blocks []byte, sections ...[]byte) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	compressed := gzip.NewWriter(&buffer)
+	binary.Write(compressed, binary.LittleEndian, uint16(LVL_VERSION))
+	binary.Write(compressed, binary.LittleEndian, header)
+	compressed.Write(blocks)
+	for _, section := range sections {
+		compressed.Write(section)
+	}
+	if err := compressed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// A custom block section with only the first chunk present
+func firstChunkSection(values map[int]byte) []byte {
+	chunk := make([]byte, LVL_CHUNK_SIZE*LVL_CHUNK_SIZE*LVL_CHUNK_SIZE)
+	for offset, value := range values {
+		chunk[offset] = value
+	}
+	return append([]byte{LVL_SECTION_CUSTOM_BLOCKS, 1}, chunk...)
+}
+
+func physicsSection(entries int32) []byte {
+	section := []byte{LVL_SECTION_PHYSICS}
+	section = binary.LittleEndian.AppendUint32(section, uint32(entries))
+	return append(section, make([]byte, int(entries)*LVL_PHYSICS_ENTRY_SIZE)...)
+}
+
+func readLvlBytes(t *testing.T, data []byte) (*world.Level, *Report) {
+	t.Helper()
+	level, report, err := ReadLvl("test", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return level, report
+}
+
+func hasWarning(report *Report, text string) bool {
+	return slices.ContainsFunc(report.Warnings(), func(warning string) bool {
+		return strings.Contains(warning, text)
+	})
+}
+
+func TestLvlRoundTrip(t *testing.T) {
+	blocks := make([]byte, 20*10*30)
+	for i := range blocks {
+		blocks[i] = byte(i % world.BLOCK_COUNT)
+	}
+	level, err := world.NewLevelFromBlocks("round", 20, 10, 30, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	level.SetSpawn(world.Position{X: 3.5, Y: 4 + world.PLAYER_HEIGHT, Z: 5.5, Yaw: 10, Pitch: 20})
+	metadata := softwareMetadata(level, LVL_METADATA)
+	metadata["VisitAccess"] = int8(2)
+	metadata["BuildAccess"] = int8(3)
+
+	var buffer bytes.Buffer
+	if err := WriteLvl(level, &buffer); err != nil {
+		t.Fatal(err)
+	}
+	read, report := readLvlBytes(t, buffer.Bytes())
+	if warnings := report.Warnings(); len(warnings) != 0 {
+		t.Fatalf("Unexpected warnings: %v", warnings)
+	}
+	if width, height, length := read.Size(); width != 20 || height != 10 || length != 30 {
+		t.Fatalf("Expected size 20x10x30, got %dx%dx%d", width, height, length)
+	}
+	if !bytes.Equal(read.Blocks(), blocks) {
+		t.Fatal("Blocks changed after a round trip")
+	}
+	if read.Spawn() != level.Spawn() {
+		t.Fatalf("Expected spawn %+v, got %+v", level.Spawn(), read.Spawn())
+	}
+	access := existingMetadata(read, LVL_METADATA)
+	if visit, _ := nbt.Get[int8](access, "VisitAccess"); visit != 2 {
+		t.Fatalf("Expected visit access 2, got %d", visit)
+	}
+	if build, _ := nbt.Get[int8](access, "BuildAccess"); build != 3 {
+		t.Fatalf("Expected build access 3, got %d", build)
+	}
+}
+
+func TestLvlCustomBlocks(t *testing.T) {
+	blocks := make([]byte, 4*4*4)
+	blocks[0] = world.BLOCK_GOLD
+	blocks[1] = 55 // CPE block, which the main array can hold
+	blocks[2] = LVL_PHYSICS_BLOCKS_START + 4
+	blocks[3] = LVL_CUSTOM_BLOCK_2
+	blocks[4] = LVL_CUSTOM_BLOCK
+	blocks[5] = LVL_CUSTOM_BLOCK
+	blocks[6] = LVL_CUSTOM_BLOCK
+	// The second row of the level starts 16 blocks into the chunk
+	custom := firstChunkSection(map[int]byte{16: world.BLOCK_GLASS, 17: 60, 18: 200})
+	header := lvlHeader{Width: 4, Height: 4, Length: 4}
+	level, report := readLvlBytes(t, buildLvl(t, header, blocks, custom))
+
+	read := level.Blocks()
+	expected := []byte{world.BLOCK_GOLD, world.BLOCK_LIGHT_PINK_CLOTH, FALLBACK_BLOCK, FALLBACK_BLOCK, world.BLOCK_GLASS, world.BLOCK_ICE, FALLBACK_BLOCK}
+	if !bytes.Equal(read[:len(expected)], expected) {
+		t.Fatalf("Expected blocks %v, got %v", expected, read[:len(expected)])
+	}
+	for _, block := range read {
+		if !world.ValidBlock(block) {
+			t.Fatalf("Level kept unknown block %d", block)
+		}
+	}
+	for _, reason := range []string{"1 unknown blocks", "1 MCGalaxy physics blocks", "1 custom blocks with ids above 255"} {
+		if !hasWarning(report, reason) {
+			t.Errorf("Missing %q in %v", reason, report.Warnings())
+		}
+	}
+}
+
+// Physics has a count to skip by, so sections after it are still read
+func TestLvlSkipsPhysics(t *testing.T) {
+	blocks := make([]byte, 4*4*4)
+	blocks[0] = LVL_CUSTOM_BLOCK
+	custom := firstChunkSection(map[int]byte{0: world.BLOCK_BRICK})
+	header := lvlHeader{Width: 4, Height: 4, Length: 4}
+	level, report := readLvlBytes(t, buildLvl(t, header, blocks, physicsSection(3), custom))
+	if block, _ := level.GetBlock(0, 0, 0); block != world.BLOCK_BRICK {
+		t.Fatalf("Custom block section after physics wasn't read, got block %d", block)
+	}
+	if !hasWarning(report, "Physics state") {
+		t.Fatalf("Skipping physics wasn't reported: %v", report.Warnings())
+	}
+
+	_, report = readLvlBytes(t, buildLvl(t, header, blocks, physicsSection(3)[:10]))
+	if !hasWarning(report, "Physics section ends early") {
+		t.Fatalf("Truncated physics wasn't reported: %v", report.Warnings())
+	}
+}
+
+func TestLvlStopsAtBlockDefinitions(t *testing.T) {
+	blocks := make([]byte, 4*4*4)
+	header := lvlHeader{Width: 4, Height: 4, Length: 4}
+	_, report := readLvlBytes(t, buildLvl(t, header, blocks, []byte{LVL_SECTION_BLOCK_DEFS, 1, 2, 3}))
+	if !hasWarning(report, "block definitions") {
+		t.Fatalf("Skipped block definitions weren't reported: %v", report.Warnings())
+	}
+}
+
+// Files from before permissions were stored start with the width
+func TestLvlUnversioned(t *testing.T) {
+	var buffer bytes.Buffer
+	compressed := gzip.NewWriter(&buffer)
+	for _, value := range []uint16{2, 3, 4, 1, 2, 3} {
+		binary.Write(compressed, binary.LittleEndian, value)
+	}
+	compressed.Write([]byte{64, 0, 0, 0})
+	blocks := make([]byte, 2*3*4)
+	blocks[len(blocks)-1] = world.BLOCK_SAND
+	compressed.Write(blocks)
+	compressed.Close()
+
+	level, _ := readLvlBytes(t, buffer.Bytes())
+	if width, height, length := level.Size(); width != 2 || height != 4 || length != 3 {
+		t.Fatalf("Expected size 2x4x3, got %dx%dx%d", width, height, length)
+	}
+	if !bytes.Equal(level.Blocks(), blocks) {
+		t.Fatal("Blocks differ")
+	}
+	if spawn := level.Spawn(); spawn.X != 1.5 || spawn.Z != 2.5 || spawn.Yaw != 64 {
+		t.Fatalf("Wrong spawn %+v", spawn)
+	}
+	if existingMetadata(level, LVL_METADATA) != nil {
+		t.Fatal("Unversioned file stored permissions")
+	}
+}
+
+func TestLvlRejectsTruncated(t *testing.T) {
+	header := lvlHeader{Width: 4, Height: 4, Length: 4}
+	data := buildLvl(t, header, make([]byte, 4*4*4-1))
+	if _, _, err := ReadLvl("test", bytes.NewReader(data)); err == nil {
+		t.Fatal("Read a level with too few blocks")
+	}
+}
+
+func TestLvlRejectsOversized(t *testing.T) {
+	// Would need 32 GiB if the header were trusted
+	header := lvlHeader{Width: 32767, Height: 32767, Length: 32}
+	data := buildLvl(t, header, nil)
+	_, _, err := ReadLvl("test", bytes.NewReader(data))
+	var coded cerror.CodedError
+	if !errors.As(err, &coded) || coded.Code != LVL_INVALID_SIZE {
+		t.Fatalf("Expected an invalid size error, got %v", err)
+	}
+}
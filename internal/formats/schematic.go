@@ -0,0 +1,279 @@
+package formats
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/nbt"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+const SCHEMATIC_EXTENSION = ".schematic"
+
+const (
+	SCHEMATIC_MATERIALS_ALPHA   = "Alpha"
+	SCHEMATIC_MATERIALS_CLASSIC = "Classic"
+)
+
+const (
+	SCHEMATIC_MISSING_TAG = iota
+	SCHEMATIC_INVALID_SIZE
+	SCHEMATIC_BLOCK_COUNT_MISMATCH
+	SCHEMATIC_INVALID_PALETTE
+)
+
+// Maps block ids, optionally with a data value as "id:data", onto Classic blocks
+type Palette struct {
+	Blocks map[string]byte `json:"blocks"`
+}
+
+// The data specific mapping is preferred over the one for any data value
+func (palette *Palette) Lookup(id int, data byte) (byte, bool) {
+	if block, ok := palette.Blocks[fmt.Sprintf("%d:%d", id, data)]; ok {
+		return block, true
+	}
+	block, ok := palette.Blocks[strconv.Itoa(id)]
+	return block, ok
+}
+
+func (palette *Palette) Save(path string) error {
+	data, err := json.MarshalIndent(palette, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func LoadPalette(path string) (*Palette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	palette := &Palette{}
+	if err := json.Unmarshal(data, palette); err != nil {
+		return nil, err
+	}
+	// Checked up front, as pasted blocks aren't checked again before reaching the level
+	for id, block := range palette.Blocks {
+		if !world.ValidBlock(block) {
+			return nil, cerror.NewErrorf(SCHEMATIC_INVALID_PALETTE, "Palette maps %s to unknown block %d", id, block)
+		}
+	}
+	return palette, nil
+}
+
+// Classic blocks keep their ids, and later blocks map onto the closest Classic or CustomBlocks look
+func NewPalette() *Palette {
+	palette := &Palette{Blocks: make(map[string]byte)}
+	for id := world.BLOCK_AIR; id <= world.BLOCK_GLASS; id++ {
+		palette.Blocks[strconv.Itoa(id)] = byte(id)
+	}
+	for id := world.BLOCK_DANDELION; id < world.CLASSIC_BLOCK_COUNT; id++ {
+		palette.Blocks[strconv.Itoa(id)] = byte(id)
+	}
+	// Wool by data value
+	wool := map[byte]byte{
+		0: world.BLOCK_WHITE_CLOTH, 1: world.BLOCK_ORANGE_CLOTH, 2: world.BLOCK_MAGENTA_CLOTH, 3: world.BLOCK_CAPRI_CLOTH,
+		4: world.BLOCK_YELLOW_CLOTH, 5: world.BLOCK_CHARTREUSE_CLOTH, 6: world.BLOCK_ROSE_CLOTH, 7: world.BLOCK_DARK_GRAY_CLOTH,
+		8: world.BLOCK_LIGHT_GRAY_CLOTH, 9: world.BLOCK_CYAN_CLOTH, 10: world.BLOCK_PURPLE_CLOTH, 11: world.BLOCK_ULTRAMARINE_CLOTH,
+		12: world.BLOCK_BROWN_CLOTH, 13: world.BLOCK_GREEN_CLOTH, 14: world.BLOCK_RED_CLOTH, 15: world.BLOCK_DARK_GRAY_CLOTH,
+	}
+	for data, block := range wool {
+		palette.Blocks[fmt.Sprintf("35:%d", data)] = block
+	}
+	for id, block := range map[int]byte{
+		24: world.BLOCK_SANDSTONE,   // Sandstone
+		31: world.BLOCK_AIR,         // Tall grass
+		32: world.BLOCK_AIR,         // Dead bush
+		53: world.BLOCK_PLANKS,      // Oak stairs
+		60: world.BLOCK_DIRT,        // Farmland
+		67: world.BLOCK_COBBLESTONE, // Cobblestone stairs
+		78: world.BLOCK_AIR,         // Snow layer
+		79: world.BLOCK_ICE,         // Ice
+		80: world.BLOCK_SNOW,        // Snow
+		85: world.BLOCK_PLANKS,      // Fence
+		98: world.BLOCK_STONE_BRICK, // Stone bricks
+	} {
+		palette.Blocks[strconv.Itoa(id)] = block
+	}
+	return palette
+}
+
+// Structure to paste into levels, with its blocks already mapped onto Classic blocks
+type Schematic struct {
+	width, height, length int16
+	// Ordered by Y, then Z, then X
+	blocks []byte
+}
+
+func (schematic *Schematic) Size() (width, height, length int16) {
+	return schematic.width, schematic.height, schematic.length
+}
+
+func (schematic *Schematic) index(x, y, z int16) int {
+	return (int(y)*int(schematic.length)+int(z))*int(schematic.width) + int(x)
+}
+
+// Quarter turns are clockwise looking down, and mirroring happens before rotating
+type PasteOptions struct {
+	Rotation int
+	MirrorX  bool
+	MirrorZ  bool
+	// Leaves existing blocks where the schematic has air
+	SkipAir bool
+}
+
+// Copy of the schematic mirrored and rotated around the vertical axis
+func (schematic *Schematic) Transform(options PasteOptions) *Schematic {
+	rotation := ((options.Rotation % 4) + 4) % 4
+	transformed := &Schematic{width: schematic.width, height: schematic.height, length: schematic.length}
+	if rotation%2 == 1 {
+		transformed.width, transformed.length = schematic.length, schematic.width
+	}
+	transformed.blocks = make([]byte, len(schematic.blocks))
+	for y := range schematic.height {
+		for z := range schematic.length {
+			for x := range schematic.width {
+				mirroredX, mirroredZ := x, z
+				if options.MirrorX {
+					mirroredX = schematic.width - 1 - x
+				}
+				if options.MirrorZ {
+					mirroredZ = schematic.length - 1 - z
+				}
+				newX, newZ := mirroredX, mirroredZ
+				switch rotation {
+				case 1:
+					newX, newZ = schematic.length-1-mirroredZ, mirroredX
+				case 2:
+					newX, newZ = schematic.width-1-mirroredX, schematic.length-1-mirroredZ
+				case 3:
+					newX, newZ = mirroredZ, schematic.width-1-mirroredX
+				}
+				transformed.blocks[transformed.index(newX, y, newZ)] = schematic.blocks[schematic.index(x, y, z)]
+			}
+		}
+	}
+	return transformed
+}
+
+// Sets each block through set with the transformed schematic's minimum corner at origin, skipping any outside the level.
+// Returns how many blocks set accepted
+func (schematic *Schematic) Paste(level *world.Level, origin world.BlockPos, options PasteOptions, set func(x, y, z int16, block byte) error) int {
+	transformed := schematic.Transform(options)
+	width, height, length := level.Size()
+	pasted := 0
+	for y := range transformed.height {
+		for z := range transformed.length {
+			for x := range transformed.width {
+				block := transformed.blocks[transformed.index(x, y, z)]
+				if options.SkipAir && block == world.BLOCK_AIR {
+					continue
+				}
+				levelX, levelY, levelZ := int(origin.X)+int(x), int(origin.Y)+int(y), int(origin.Z)+int(z)
+				if levelX < 0 || levelY < 0 || levelZ < 0 || levelX >= int(width) || levelY >= int(height) || levelZ >= int(length) {
+					continue
+				}
+				if set(int16(levelX), int16(levelY), int16(levelZ), block) == nil {
+					pasted++
+				}
+			}
+		}
+	}
+	return pasted
+}
+
+func schematicTag[T any](root nbt.Compound, name string) (T, error) {
+	value, ok := nbt.Get[T](root, name)
+	if !ok {
+		return value, cerror.NewErrorf(SCHEMATIC_MISSING_TAG, "Schematic is missing %s", name)
+	}
+	return value, nil
+}
+
+// Reads a gzip compressed MCEdit schematic, mapping its blocks through the palette unless they're already Classic blocks
+func ReadSchematic(reader io.Reader, palette *Palette) (*Schematic, *Report, error) {
+	report := &Report{}
+	decompressed, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer decompressed.Close()
+	_, root, err := nbt.Read(decompressed)
+	if err != nil {
+		return nil, nil, err
+	}
+	schematic := &Schematic{}
+	for name, size := range map[string]*int16{"Width": &schematic.width, "Height": &schematic.height, "Length": &schematic.length} {
+		if *size, err = schematicTag[int16](root, name); err != nil {
+			return nil, nil, err
+		}
+		if *size <= 0 {
+			return nil, nil, cerror.NewErrorf(SCHEMATIC_INVALID_SIZE, "Invalid schematic %s %d", name, *size)
+		}
+	}
+	blocks, err := schematicTag[[]byte](root, "Blocks")
+	if err != nil {
+		return nil, nil, err
+	}
+	volume := int(schematic.width) * int(schematic.height) * int(schematic.length)
+	if len(blocks) != volume {
+		return nil, nil, cerror.NewErrorf(SCHEMATIC_BLOCK_COUNT_MISMATCH, "Expected %d blocks, got %d", volume, len(blocks))
+	}
+	data, _ := nbt.Get[[]byte](root, "Data")
+	if len(data) != volume {
+		data = make([]byte, volume)
+	}
+	// Upper four bits of block ids above 255, two to a byte
+	add, _ := nbt.Get[[]byte](root, "AddBlocks")
+	materials, _ := nbt.Get[string](root, "Materials")
+	if materials != SCHEMATIC_MATERIALS_ALPHA && materials != SCHEMATIC_MATERIALS_CLASSIC {
+		report.Warn("Unknown materials %q, treating block ids as %s", materials, SCHEMATIC_MATERIALS_ALPHA)
+	}
+	for _, name := range []string{"Entities", "TileEntities"} {
+		if list, ok := nbt.Get[nbt.List](root, name); ok && len(list.Values) > 0 {
+			report.Warn("%d %s were skipped", len(list.Values), name)
+		}
+	}
+
+	schematic.blocks = make([]byte, volume)
+	for i, raw := range blocks {
+		if materials == SCHEMATIC_MATERIALS_CLASSIC {
+			schematic.blocks[i] = raw
+			if raw >= world.CLASSIC_BLOCK_COUNT {
+				schematic.blocks[i] = FALLBACK_BLOCK
+				report.Replaced("unknown blocks")
+			}
+			continue
+		}
+		id := int(raw)
+		if i/2 < len(add) {
+			if i%2 == 0 {
+				id |= int(add[i/2]&0x0F) << 8
+			} else {
+				id |= int(add[i/2]&0xF0) << 4
+			}
+		}
+		block, ok := palette.Lookup(id, data[i]&0x0F)
+		if !ok {
+			report.Replaced(fmt.Sprintf("blocks of unmapped id %d:%d", id, data[i]&0x0F))
+			block = FALLBACK_BLOCK
+		}
+		schematic.blocks[i] = block
+	}
+	return schematic, report, nil
+}
+
+func LoadSchematic(path string, palette *Palette) (*Schematic, *Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	return ReadSchematic(file, palette)
+}
@@ -0,0 +1,241 @@
+package formats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/nbt"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+func TestLoadPalette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "palette.json")
+	if err := NewPalette().Save(path); err != nil {
+		t.Fatal(err)
+	}
+	palette, err := LoadPalette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block, ok := palette.Lookup(35, 14); !ok || block != world.BLOCK_RED_CLOTH {
+		t.Fatalf("Expected red wool to map to red cloth, got %d", block)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"blocks": {"1": 1, "95": 200}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPalette(path); err == nil {
+		t.Fatal("Loaded a palette mapping onto an unknown block")
+	}
+}
+
+// Gzip compressed schematic NBT with the given size and blocks, plus any other tags
+func buildSchematic(t *testing.T, width, height, length int16, blocks []byte, tags nbt.Compound) []byte {
+	t.Helper()
+	root := nbt.Compound{"Width": width, "Height": height, "Length": length, "Blocks": blocks}
+	for name, value := range tags {
+		root[name] = value
+	}
+	var buffer bytes.Buffer
+	compressed := gzip.NewWriter(&buffer)
+	if err := nbt.Write(compressed, "Schematic", root); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func readSchematicBytes(t *testing.T, data []byte, palette *Palette) (*Schematic, *Report) {
+	t.Helper()
+	schematic, report, err := ReadSchematic(bytes.NewReader(data), palette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schematic, report
+}
+
+func TestSchematicAlphaMaterials(t *testing.T) {
+	palette := &Palette{Blocks: map[string]byte{
+		"257":   world.BLOCK_STONE,
+		"514":   world.BLOCK_GOLD,
+		"35":    world.BLOCK_WHITE_CLOTH,
+		"35:14": world.BLOCK_RED_CLOTH,
+	}}
+	// Even blocks take the low nibble of their AddBlocks byte and odd blocks the high one
+	blocks := []byte{0x01, 0x02, 0x03, 35, 35, 95}
+	data := buildSchematic(t, 6, 1, 1, blocks, nbt.Compound{
+		"Materials": SCHEMATIC_MATERIALS_ALPHA,
+		"AddBlocks": []byte{0x21, 0x03, 0x00},
+		"Data":      []byte{0, 0, 0, 14, 2, 3},
+	})
+	schematic, report := readSchematicBytes(t, data, palette)
+	expected := []byte{world.BLOCK_STONE, world.BLOCK_GOLD, FALLBACK_BLOCK, world.BLOCK_RED_CLOTH, world.BLOCK_WHITE_CLOTH, FALLBACK_BLOCK}
+	if !bytes.Equal(schematic.blocks, expected) {
+		t.Fatalf("Expected blocks %v, got %v", expected, schematic.blocks)
+	}
+	for _, reason := range []string{"1 blocks of unmapped id 771:0", "1 blocks of unmapped id 95:3"} {
+		if !hasWarning(report, reason) {
+			t.Errorf("Missing %q in %v", reason, report.Warnings())
+		}
+	}
+	if len(report.Warnings()) != 2 {
+		t.Errorf("Expected only the unmapped ids, got %v", report.Warnings())
+	}
+}
+
+func TestSchematicClassicMaterials(t *testing.T) {
+	blocks := []byte{world.BLOCK_STONE, world.BLOCK_OBSIDIAN, world.BLOCK_ICE, 200}
+	data := buildSchematic(t, 2, 2, 1, blocks, nbt.Compound{"Materials": SCHEMATIC_MATERIALS_CLASSIC})
+	// Classic schematics keep their ids rather than going through the palette
+	schematic, report := readSchematicBytes(t, data, &Palette{Blocks: map[string]byte{"1": world.BLOCK_GOLD}})
+	expected := []byte{world.BLOCK_STONE, world.BLOCK_OBSIDIAN, FALLBACK_BLOCK, FALLBACK_BLOCK}
+	if !bytes.Equal(schematic.blocks, expected) {
+		t.Fatalf("Expected blocks %v, got %v", expected, schematic.blocks)
+	}
+	if !hasWarning(report, "2 unknown blocks") {
+		t.Fatalf("Missing replacement in %v", report.Warnings())
+	}
+}
+
+func TestSchematicUnknownMaterials(t *testing.T) {
+	data := buildSchematic(t, 1, 1, 1, []byte{1}, nbt.Compound{"Materials": "Pocket"})
+	schematic, report := readSchematicBytes(t, data, NewPalette())
+	if schematic.blocks[0] != world.BLOCK_STONE {
+		t.Fatalf("Expected stone, got %d", schematic.blocks[0])
+	}
+	if !hasWarning(report, `Unknown materials "Pocket"`) {
+		t.Fatalf("Missing materials warning in %v", report.Warnings())
+	}
+}
+
+// Three wide and two long, so every rotation and mirror gives a different layout. The upper layer is the lower plus 6
+func asymmetricSchematic() *Schematic {
+	return &Schematic{
+		width: 3, height: 2, length: 2,
+		blocks: []byte{
+			1, 2, 3,
+			4, 5, 6,
+			7, 8, 9,
+			10, 11, 12,
+		},
+	}
+}
+
+func TestSchematicTransform(t *testing.T) {
+	// Rows of the lower layer by Z, as seen looking down with X to the right
+	tests := []struct {
+		name    string
+		options PasteOptions
+		rows    [][]byte
+	}{
+		{"None", PasteOptions{}, [][]byte{{1, 2, 3}, {4, 5, 6}}},
+		{"Rotate1", PasteOptions{Rotation: 1}, [][]byte{{4, 1}, {5, 2}, {6, 3}}},
+		{"Rotate2", PasteOptions{Rotation: 2}, [][]byte{{6, 5, 4}, {3, 2, 1}}},
+		{"Rotate3", PasteOptions{Rotation: 3}, [][]byte{{3, 6}, {2, 5}, {1, 4}}},
+		{"MirrorX", PasteOptions{MirrorX: true}, [][]byte{{3, 2, 1}, {6, 5, 4}}},
+		{"MirrorXRotate1", PasteOptions{MirrorX: true, Rotation: 1}, [][]byte{{6, 3}, {5, 2}, {4, 1}}},
+		{"MirrorXRotate2", PasteOptions{MirrorX: true, Rotation: 2}, [][]byte{{4, 5, 6}, {1, 2, 3}}},
+		{"MirrorXRotate3", PasteOptions{MirrorX: true, Rotation: 3}, [][]byte{{1, 4}, {2, 5}, {3, 6}}},
+		{"MirrorZ", PasteOptions{MirrorZ: true}, [][]byte{{4, 5, 6}, {1, 2, 3}}},
+		{"MirrorZRotate1", PasteOptions{MirrorZ: true, Rotation: 1}, [][]byte{{1, 4}, {2, 5}, {3, 6}}},
+		{"MirrorZRotate2", PasteOptions{MirrorZ: true, Rotation: 2}, [][]byte{{3, 2, 1}, {6, 5, 4}}},
+		{"MirrorZRotate3", PasteOptions{MirrorZ: true, Rotation: 3}, [][]byte{{6, 3}, {5, 2}, {4, 1}}},
+		{"MirrorBoth", PasteOptions{MirrorX: true, MirrorZ: true}, [][]byte{{6, 5, 4}, {3, 2, 1}}},
+		{"MirrorBothRotate1", PasteOptions{MirrorX: true, MirrorZ: true, Rotation: 1}, [][]byte{{3, 6}, {2, 5}, {1, 4}}},
+		{"MirrorBothRotate2", PasteOptions{MirrorX: true, MirrorZ: true, Rotation: 2}, [][]byte{{1, 2, 3}, {4, 5, 6}}},
+		{"MirrorBothRotate3", PasteOptions{MirrorX: true, MirrorZ: true, Rotation: 3}, [][]byte{{4, 1}, {5, 2}, {6, 3}}},
+		{"RotateNegative", PasteOptions{Rotation: -1}, [][]byte{{3, 6}, {2, 5}, {1, 4}}},
+		{"RotateWraps", PasteOptions{Rotation: 5}, [][]byte{{4, 1}, {5, 2}, {6, 3}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transformed := asymmetricSchematic().Transform(test.options)
+			width, height, length := transformed.Size()
+			if int(width) != len(test.rows[0]) || height != 2 || int(length) != len(test.rows) {
+				t.Fatalf("Expected size %dx2x%d, got %dx%dx%d", len(test.rows[0]), len(test.rows), width, height, length)
+			}
+			var expected []byte
+			for _, layer := range []byte{0, 6} {
+				for _, row := range test.rows {
+					for _, block := range row {
+						expected = append(expected, block+layer)
+					}
+				}
+			}
+			if !bytes.Equal(transformed.blocks, expected) {
+				t.Fatalf("Expected blocks %v, got %v", expected, transformed.blocks)
+			}
+		})
+	}
+}
+
+func TestSchematicPaste(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  world.BlockPos
+		options PasteOptions
+		// Pasted blocks by level position
+		pasted map[world.BlockPos]byte
+	}{
+		{"Inside", world.BlockPos{X: 1, Y: 0, Z: 1}, PasteOptions{}, map[world.BlockPos]byte{
+			{X: 1, Y: 0, Z: 1}: 1, {X: 2, Y: 0, Z: 1}: 2, {X: 3, Y: 0, Z: 1}: 3,
+			{X: 1, Y: 0, Z: 2}: 4, {X: 2, Y: 0, Z: 2}: 5, {X: 3, Y: 0, Z: 2}: 6,
+			{X: 1, Y: 1, Z: 1}: 7, {X: 2, Y: 1, Z: 1}: 8, {X: 3, Y: 1, Z: 1}: 9,
+			{X: 1, Y: 1, Z: 2}: 10, {X: 2, Y: 1, Z: 2}: 11, {X: 3, Y: 1, Z: 2}: 12,
+		}},
+		{"ClippedHigh", world.BlockPos{X: 2, Y: 2, Z: 3}, PasteOptions{}, map[world.BlockPos]byte{
+			{X: 2, Y: 2, Z: 3}: 1, {X: 3, Y: 2, Z: 3}: 2,
+		}},
+		{"ClippedLow", world.BlockPos{X: -2, Y: -1, Z: -1}, PasteOptions{}, map[world.BlockPos]byte{
+			{X: 0, Y: 0, Z: 0}: 12,
+		}},
+		{"ClippedRotated", world.BlockPos{X: 3, Y: 0, Z: 2}, PasteOptions{Rotation: 1}, map[world.BlockPos]byte{
+			{X: 3, Y: 0, Z: 2}: 4, {X: 3, Y: 0, Z: 3}: 5, {X: 3, Y: 1, Z: 2}: 10, {X: 3, Y: 1, Z: 3}: 11,
+		}},
+		{"Outside", world.BlockPos{X: 4, Y: 0, Z: 0}, PasteOptions{}, map[world.BlockPos]byte{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			level, err := world.NewLevel("paste", 4, 3, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pasted := make(map[world.BlockPos]byte)
+			count := asymmetricSchematic().Paste(level, test.origin, test.options, func(x, y, z int16, block byte) error {
+				pasted[world.BlockPos{X: x, Y: y, Z: z}] = block
+				return level.SetBlock(x, y, z, block)
+			})
+			if count != len(test.pasted) {
+				t.Fatalf("Expected %d blocks pasted, got %d", len(test.pasted), count)
+			}
+			if !reflect.DeepEqual(pasted, test.pasted) {
+				t.Fatalf("Expected %v, got %v", test.pasted, pasted)
+			}
+		})
+	}
+}
+
+func TestSchematicPasteSkipsAirAndRejections(t *testing.T) {
+	schematic := asymmetricSchematic()
+	schematic.blocks[0] = world.BLOCK_AIR
+	level, err := world.NewLevel("paste", 4, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := schematic.Paste(level, world.BlockPos{}, PasteOptions{SkipAir: true}, func(x, y, z int16, block byte) error {
+		if block == 12 {
+			return errors.New("rejected")
+		}
+		return nil
+	})
+	if count != 10 {
+		t.Fatalf("Expected 10 blocks pasted, got %d", count)
+	}
+}
@@ -0,0 +1,184 @@
+package heartbeat
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+)
+
+const (
+	DEFAULT_INTERVAL        = 45 * time.Second
+	DEFAULT_ATTEMPTS        = 3
+	DEFAULT_RETRY_DELAY     = 2 * time.Second
+	DEFAULT_MAX_RETRY_DELAY = 15 * time.Second
+	REQUEST_TIMEOUT         = 10 * time.Second
+)
+
+// Server lists only ever send back a URL or a short error
+const MAX_RESPONSE_SIZE = 4096
+
+const (
+	HEARTBEAT_BAD_STATUS = iota
+	HEARTBEAT_REJECTED
+)
+
+// What's advertised to server lists
+type Info struct {
+	Name     string
+	Port     uint16
+	Users    int
+	Max      int
+	Public   bool
+	Version  int
+	Salt     string
+	Software string
+}
+
+func (info Info) query() url.Values {
+	// Lists expect Python style booleans
+	public := "False"
+	if info.Public {
+		public = "True"
+	}
+	return url.Values{
+		"name":     {info.Name},
+		"port":     {strconv.Itoa(int(info.Port))},
+		"users":    {strconv.Itoa(info.Users)},
+		"max":      {strconv.Itoa(info.Max)},
+		"public":   {public},
+		"version":  {strconv.Itoa(info.Version)},
+		"salt":     {info.Salt},
+		"software": {info.Software},
+	}
+}
+
+type Heartbeat struct {
+	client *http.Client
+	urls   []string
+	info   func() Info
+
+	Attempts      int
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	beating  atomic.Bool
+	mutex    sync.RWMutex
+	playURLs map[string]string
+}
+
+// Play URL returned by the list at the heartbeat URL, if it has returned one yet
+func (heartbeat *Heartbeat) PlayURL(listURL string) (string, bool) {
+	heartbeat.mutex.RLock()
+	defer heartbeat.mutex.RUnlock()
+	playURL, ok := heartbeat.playURLs[listURL]
+	return playURL, ok
+}
+
+// Lists reply with the play URL on success, or an error message otherwise
+func parseResponse(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	parsed, err := url.Parse(body)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", cerror.NewErrorf(HEARTBEAT_REJECTED, "Heartbeat rejected: %s", body)
+	}
+	return body, nil
+}
+
+// Makes a single attempt, returning the play URL
+func (heartbeat *Heartbeat) Send(ctx context.Context, listURL string, info Info) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, REQUEST_TIMEOUT)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL, nil)
+	if err != nil {
+		return "", err
+	}
+	request.URL.RawQuery = info.query().Encode()
+	response, err := heartbeat.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, MAX_RESPONSE_SIZE))
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return "", cerror.NewErrorf(HEARTBEAT_BAD_STATUS, "Heartbeat failed with status %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return parseResponse(string(body))
+}
+
+// Retries with exponential backoff until an attempt succeeds or they run out
+func (heartbeat *Heartbeat) beat(ctx context.Context, listURL string, info Info) {
+	delay := heartbeat.RetryDelay
+	for attempt := 1; ; attempt++ {
+		playURL, err := heartbeat.Send(ctx, listURL, info)
+		if err == nil {
+			heartbeat.mutex.Lock()
+			previous := heartbeat.playURLs[listURL]
+			heartbeat.playURLs[listURL] = playURL
+			heartbeat.mutex.Unlock()
+			if playURL != previous {
+				log.Printf("Play at %s", playURL)
+			}
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if attempt >= heartbeat.Attempts {
+			log.Printf("Heartbeat to %s failed after %d attempts: %v", listURL, attempt, err)
+			return
+		}
+		log.Printf("Heartbeat to %s failed, retrying in %s: %v", listURL, delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, heartbeat.MaxRetryDelay)
+	}
+}
+
+// Sends to every list at once and waits for them. Skipped if the previous beat is still going
+func (heartbeat *Heartbeat) Beat(ctx context.Context) {
+	if !heartbeat.beating.CompareAndSwap(false, true) {
+		return
+	}
+	defer heartbeat.beating.Store(false)
+	info := heartbeat.info()
+	var wg sync.WaitGroup
+	for _, listURL := range heartbeat.urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			heartbeat.beat(ctx, listURL, info)
+		}()
+	}
+	wg.Wait()
+}
+
+// Info is called at the start of every beat. Uses http.DefaultClient if client is nil
+func NewHeartbeat(client *http.Client, urls []string, info func() Info) *Heartbeat {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Heartbeat{
+		client:        client,
+		urls:          urls,
+		info:          info,
+		Attempts:      DEFAULT_ATTEMPTS,
+		RetryDelay:    DEFAULT_RETRY_DELAY,
+		MaxRetryDelay: DEFAULT_MAX_RETRY_DELAY,
+		playURLs:      make(map[string]string),
+	}
+}
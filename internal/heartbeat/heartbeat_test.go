@@ -0,0 +1,178 @@
+package heartbeat
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testInfo = Info{
+	Name:     "Test Server",
+	Port:     25565,
+	Users:    3,
+	Max:      20,
+	Public:   true,
+	Version:  7,
+	Salt:     "abcdef0123456789",
+	Software: "Burrowing Classic",
+}
+
+func newTestHeartbeat(urls ...string) *Heartbeat {
+	heartbeat := NewHeartbeat(nil, urls, func() Info { return testInfo })
+	heartbeat.RetryDelay = time.Millisecond
+	heartbeat.MaxRetryDelay = 4 * time.Millisecond
+	return heartbeat
+}
+
+func TestSendsInfo(t *testing.T) {
+	var query url.Values
+	list := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		query = request.URL.Query()
+		writer.Write([]byte("https://example.com/play/abc\n"))
+	}))
+	defer list.Close()
+
+	heartbeat := newTestHeartbeat(list.URL)
+	heartbeat.Beat(context.Background())
+	expected := map[string]string{
+		"name":     "Test Server",
+		"port":     "25565",
+		"users":    "3",
+		"max":      "20",
+		"public":   "True",
+		"version":  "7",
+		"salt":     "abcdef0123456789",
+		"software": "Burrowing Classic",
+	}
+	for key, value := range expected {
+		if query.Get(key) != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, query.Get(key))
+		}
+	}
+	playURL, ok := heartbeat.PlayURL(list.URL)
+	if !ok || playURL != "https://example.com/play/abc" {
+		t.Fatalf("Expected the play URL to be stored, got %q", playURL)
+	}
+}
+
+func TestRetriesWithBackoff(t *testing.T) {
+	var requests atomic.Int32
+	list := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if requests.Add(1) < 3 {
+			http.Error(writer, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		writer.Write([]byte("http://example.com/play/retried"))
+	}))
+	defer list.Close()
+
+	heartbeat := newTestHeartbeat(list.URL)
+	heartbeat.Beat(context.Background())
+	if requests.Load() != 3 {
+		t.Fatalf("Expected 3 requests, got %d", requests.Load())
+	}
+	if playURL, _ := heartbeat.PlayURL(list.URL); playURL != "http://example.com/play/retried" {
+		t.Fatalf("Expected the play URL from the retry, got %q", playURL)
+	}
+}
+
+func TestGivesUpAfterAttempts(t *testing.T) {
+	var requests atomic.Int32
+	list := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+		writer.Write([]byte("Invalid salt"))
+	}))
+	defer list.Close()
+
+	heartbeat := newTestHeartbeat(list.URL)
+	heartbeat.Beat(context.Background())
+	if requests.Load() != DEFAULT_ATTEMPTS {
+		t.Fatalf("Expected %d requests, got %d", DEFAULT_ATTEMPTS, requests.Load())
+	}
+	if _, ok := heartbeat.PlayURL(list.URL); ok {
+		t.Fatal("Stored a play URL from a rejected heartbeat")
+	}
+}
+
+func TestSendRejections(t *testing.T) {
+	list := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/status":
+			http.Error(writer, "nope", http.StatusForbidden)
+		case "/message":
+			writer.Write([]byte("Server name is too long"))
+		case "/relative":
+			writer.Write([]byte("/play/abc"))
+		}
+	}))
+	defer list.Close()
+
+	heartbeat := newTestHeartbeat()
+	for _, path := range []string{"/status", "/message", "/relative"} {
+		if playURL, err := heartbeat.Send(context.Background(), list.URL+path, testInfo); err == nil {
+			t.Errorf("%s was accepted with play URL %q", path, playURL)
+		}
+	}
+}
+
+func TestBeatsEveryList(t *testing.T) {
+	var mutex sync.Mutex
+	hits := make(map[string]int)
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			mutex.Lock()
+			hits[name]++
+			mutex.Unlock()
+			writer.Write([]byte("https://" + name + ".example.com/play"))
+		})
+	}
+	first := httptest.NewServer(handler("first"))
+	defer first.Close()
+	second := httptest.NewServer(handler("second"))
+	defer second.Close()
+
+	heartbeat := newTestHeartbeat(first.URL, second.URL)
+	heartbeat.Beat(context.Background())
+	if hits["first"] != 1 || hits["second"] != 1 {
+		t.Fatalf("Expected one request to each list, got %v", hits)
+	}
+	if playURL, _ := heartbeat.PlayURL(second.URL); playURL != "https://second.example.com/play" {
+		t.Fatalf("Wrong play URL for the second list: %q", playURL)
+	}
+}
+
+func TestCancelStopsRetrying(t *testing.T) {
+	var requests atomic.Int32
+	list := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+		http.Error(writer, "down", http.StatusBadGateway)
+	}))
+	defer list.Close()
+
+	heartbeat := newTestHeartbeat(list.URL)
+	heartbeat.RetryDelay = time.Hour
+	heartbeat.MaxRetryDelay = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		heartbeat.Beat(ctx)
+		close(done)
+	}()
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Beat kept waiting to retry after being cancelled")
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("Expected 1 request, got %d", requests.Load())
+	}
+}
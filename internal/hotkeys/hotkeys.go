@@ -0,0 +1,42 @@
+package hotkeys
+
+import (
+	"encoding/json"
+	"os"
+)
+
+const (
+	MOD_CTRL = 1 << iota
+	MOD_SHIFT
+	MOD_ALT
+)
+
+type HotKey struct {
+	Label  string `json:"label"`
+	Action string `json:"action"`
+	// LWJGL key code
+	KeyCode int32 `json:"key_code"`
+	KeyMods byte  `json:"key_mods"`
+	// Sends the action straight away rather than placing it in the chat input
+	Immediate bool `json:"immediate"`
+}
+
+// Action as sent to the client
+func (hotkey *HotKey) ClientAction() string {
+	if hotkey.Immediate {
+		return hotkey.Action + "\n"
+	}
+	return hotkey.Action
+}
+
+func Load(path string) ([]HotKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var hotkeys []HotKey
+	if err := json.Unmarshal(data, &hotkeys); err != nil {
+		return nil, err
+	}
+	return hotkeys, nil
+}
@@ -0,0 +1,371 @@
+package nbt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+)
+
+const (
+	TAG_END = iota
+	TAG_BYTE
+	TAG_SHORT
+	TAG_INT
+	TAG_LONG
+	TAG_FLOAT
+	TAG_DOUBLE
+	TAG_BYTE_ARRAY
+	TAG_STRING
+	TAG_LIST
+	TAG_COMPOUND
+	TAG_INT_ARRAY
+	TAG_LONG_ARRAY
+)
+
+const (
+	NBT_INVALID_TAG = iota
+	NBT_NOT_COMPOUND
+	NBT_TOO_DEEP
+	NBT_INVALID_LENGTH
+	NBT_UNSUPPORTED_TYPE
+)
+
+// Deeper nesting than any real file uses, to stop malicious ones exhausting the stack
+const MAX_DEPTH = 512
+
+// Tags are represented as int8, int16, int32, int64, float32, float64, []byte, string, List, Compound, []int32 and []int64
+type Compound map[string]any
+
+// Every value has the same tag type
+type List struct {
+	Type   byte
+	Values []any
+}
+
+// Typed lookup, which fails if the tag is missing or of another type
+func Get[T any](compound Compound, name string) (T, bool) {
+	value, ok := compound[name].(T)
+	return value, ok
+}
+
+// Deep copy, so the original can be changed without affecting it
+func (compound Compound) Clone() Compound {
+	clone := make(Compound, len(compound))
+	for name, value := range compound {
+		clone[name] = cloneValue(value)
+	}
+	return clone
+}
+
+func cloneValue(value any) any {
+	switch value := value.(type) {
+	case Compound:
+		return value.Clone()
+	case List:
+		values := make([]any, len(value.Values))
+		for i, element := range value.Values {
+			values[i] = cloneValue(element)
+		}
+		return List{Type: value.Type, Values: values}
+	case []byte:
+		return slices.Clone(value)
+	case []int32:
+		return slices.Clone(value)
+	case []int64:
+		return slices.Clone(value)
+	default:
+		return value
+	}
+}
+
+func TagType(value any) (byte, error) {
+	switch value.(type) {
+	case int8:
+		return TAG_BYTE, nil
+	case int16:
+		return TAG_SHORT, nil
+	case int32:
+		return TAG_INT, nil
+	case int64:
+		return TAG_LONG, nil
+	case float32:
+		return TAG_FLOAT, nil
+	case float64:
+		return TAG_DOUBLE, nil
+	case []byte:
+		return TAG_BYTE_ARRAY, nil
+	case string:
+		return TAG_STRING, nil
+	case List:
+		return TAG_LIST, nil
+	case Compound:
+		return TAG_COMPOUND, nil
+	case []int32:
+		return TAG_INT_ARRAY, nil
+	case []int64:
+		return TAG_LONG_ARRAY, nil
+	default:
+		return 0, cerror.NewErrorf(NBT_UNSUPPORTED_TYPE, "%T can't be stored as NBT", value)
+	}
+}
+
+type reader struct {
+	reader io.Reader
+}
+
+func (reader *reader) read(value any) error {
+	return binary.Read(reader.reader, binary.BigEndian, value)
+}
+
+func (reader *reader) length() (int, error) {
+	var length int32
+	if err := reader.read(&length); err != nil {
+		return 0, err
+	}
+	if length < 0 {
+		return 0, cerror.NewErrorf(NBT_INVALID_LENGTH, "Negative length %d", length)
+	}
+	return int(length), nil
+}
+
+// Grows as data arrives rather than trusting the length up front
+func (reader *reader) bytes(length int) ([]byte, error) {
+	var buffer bytes.Buffer
+	if _, err := io.CopyN(&buffer, reader.reader, int64(length)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (reader *reader) string() (string, error) {
+	var length uint16
+	if err := reader.read(&length); err != nil {
+		return "", err
+	}
+	data, err := reader.bytes(int(length))
+	return string(data), err
+}
+
+func readNumbers[T int32 | int64](reader *reader, size int) ([]T, error) {
+	length, err := reader.length()
+	if err != nil {
+		return nil, err
+	}
+	data, err := reader.bytes(length * size)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]T, length)
+	return values, binary.Read(bytes.NewReader(data), binary.BigEndian, values)
+}
+
+func readNumber[T int8 | int16 | int32 | int64 | float32 | float64](reader *reader) (T, error) {
+	var value T
+	err := reader.read(&value)
+	return value, err
+}
+
+func (reader *reader) payload(tagType byte, depth int) (any, error) {
+	if depth > MAX_DEPTH {
+		return nil, cerror.NewErrorf(NBT_TOO_DEEP, "Tags nested more than %d deep", MAX_DEPTH)
+	}
+	switch tagType {
+	case TAG_BYTE:
+		return readNumber[int8](reader)
+	case TAG_SHORT:
+		return readNumber[int16](reader)
+	case TAG_INT:
+		return readNumber[int32](reader)
+	case TAG_LONG:
+		return readNumber[int64](reader)
+	case TAG_FLOAT:
+		return readNumber[float32](reader)
+	case TAG_DOUBLE:
+		return readNumber[float64](reader)
+	case TAG_BYTE_ARRAY:
+		length, err := reader.length()
+		if err != nil {
+			return nil, err
+		}
+		return reader.bytes(length)
+	case TAG_STRING:
+		return reader.string()
+	case TAG_LIST:
+		var elementType byte
+		if err := reader.read(&elementType); err != nil {
+			return nil, err
+		}
+		length, err := reader.length()
+		if err != nil {
+			return nil, err
+		}
+		if elementType == TAG_END && length > 0 {
+			return nil, cerror.NewErrorf(NBT_INVALID_TAG, "List of %d end tags", length)
+		}
+		list := List{Type: elementType, Values: make([]any, 0, min(length, 1024))}
+		for range length {
+			value, err := reader.payload(elementType, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list.Values = append(list.Values, value)
+		}
+		return list, nil
+	case TAG_COMPOUND:
+		compound := make(Compound)
+		for {
+			var childType byte
+			if err := reader.read(&childType); err != nil {
+				return nil, err
+			}
+			if childType == TAG_END {
+				return compound, nil
+			}
+			name, err := reader.string()
+			if err != nil {
+				return nil, err
+			}
+			value, err := reader.payload(childType, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			compound[name] = value
+		}
+	case TAG_INT_ARRAY:
+		return readNumbers[int32](reader, 4)
+	case TAG_LONG_ARRAY:
+		return readNumbers[int64](reader, 8)
+	default:
+		return nil, cerror.NewErrorf(NBT_INVALID_TAG, "Unknown tag type %d", tagType)
+	}
+}
+
+// Reads an uncompressed root compound and its name
+func Read(input io.Reader) (string, Compound, error) {
+	reader := &reader{reader: bufio.NewReader(input)}
+	var tagType byte
+	if err := reader.read(&tagType); err != nil {
+		return "", nil, err
+	}
+	if tagType != TAG_COMPOUND {
+		return "", nil, cerror.NewErrorf(NBT_NOT_COMPOUND, "Root tag is type %d rather than a compound", tagType)
+	}
+	name, err := reader.string()
+	if err != nil {
+		return "", nil, err
+	}
+	root, err := reader.payload(TAG_COMPOUND, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, root.(Compound), nil
+}
+
+type writer struct {
+	writer io.Writer
+}
+
+func (writer *writer) write(value any) error {
+	return binary.Write(writer.writer, binary.BigEndian, value)
+}
+
+func (writer *writer) string(value string) error {
+	if len(value) > math.MaxUint16 {
+		return cerror.NewErrorf(NBT_INVALID_LENGTH, "String of %d bytes is too long", len(value))
+	}
+	if err := writer.write(uint16(len(value))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer.writer, value)
+	return err
+}
+
+func (writer *writer) length(length int) error {
+	if length > math.MaxInt32 {
+		return cerror.NewErrorf(NBT_INVALID_LENGTH, "Length %d is too long", length)
+	}
+	return writer.write(int32(length))
+}
+
+func (writer *writer) payload(value any) error {
+	switch value := value.(type) {
+	case []byte:
+		if err := writer.length(len(value)); err != nil {
+			return err
+		}
+		_, err := writer.writer.Write(value)
+		return err
+	case string:
+		return writer.string(value)
+	case List:
+		if err := writer.write(value.Type); err != nil {
+			return err
+		}
+		if err := writer.length(len(value.Values)); err != nil {
+			return err
+		}
+		for _, element := range value.Values {
+			if tagType, err := TagType(element); err != nil || tagType != value.Type {
+				return cerror.NewErrorf(NBT_UNSUPPORTED_TYPE, "%T in a list of tag type %d", element, value.Type)
+			}
+			if err := writer.payload(element); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Compound:
+		// Sorted so saving the same data always gives the same file
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			if err := writer.tag(name, value[name]); err != nil {
+				return err
+			}
+		}
+		return writer.write(byte(TAG_END))
+	case []int32:
+		if err := writer.length(len(value)); err != nil {
+			return err
+		}
+		return writer.write(value)
+	case []int64:
+		if err := writer.length(len(value)); err != nil {
+			return err
+		}
+		return writer.write(value)
+	default:
+		return writer.write(value)
+	}
+}
+
+func (writer *writer) tag(name string, value any) error {
+	tagType, err := TagType(value)
+	if err != nil {
+		return err
+	}
+	if err := writer.write(tagType); err != nil {
+		return err
+	}
+	if err := writer.string(name); err != nil {
+		return err
+	}
+	return writer.payload(value)
+}
+
+// Writes an uncompressed root compound
+func Write(output io.Writer, name string, root Compound) error {
+	buffered := bufio.NewWriter(output)
+	writer := &writer{writer: buffered}
+	if err := writer.tag(name, root); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
@@ -0,0 +1,154 @@
+package nbt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// One of every tag type, nested in lists and compounds
+func everyTag() Compound {
+	return Compound{
+		"Byte":      int8(-12),
+		"Short":     int16(-1234),
+		"Int":       int32(123456789),
+		"Long":      int64(-1234567890123),
+		"Float":     float32(1.5),
+		"Double":    float64(-2.25),
+		"ByteArray": []byte{0, 1, 2, 255},
+		"String":    "Hello, world",
+		"Empty":     "",
+		"IntArray":  []int32{-1, 0, 1 << 30},
+		"LongArray": []int64{-1, 0, 1 << 60},
+		"List":      List{Type: TAG_SHORT, Values: []any{int16(1), int16(2), int16(3)}},
+		"EmptyList": List{Type: TAG_END, Values: []any{}},
+		"Compounds": List{Type: TAG_COMPOUND, Values: []any{
+			Compound{"Name": "first"},
+			Compound{"Name": "second", "Nested": Compound{"Deeper": int8(1)}},
+		}},
+		"Compound": Compound{
+			"Lists": List{Type: TAG_LIST, Values: []any{
+				List{Type: TAG_STRING, Values: []any{"a", "b"}},
+				List{Type: TAG_BYTE, Values: []any{int8(1)}},
+			}},
+		},
+	}
+}
+
+func encode(t *testing.T, name string, root Compound) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := Write(&buffer, name, root); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	root := everyTag()
+	name, read, err := Read(bytes.NewReader(encode(t, "Root", root)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Root" {
+		t.Fatalf("Expected root name Root, got %q", name)
+	}
+	if !reflect.DeepEqual(read, root) {
+		t.Fatalf("Tags changed after a round trip:\n%#v\n%#v", root, read)
+	}
+}
+
+func TestWriteIsDeterministic(t *testing.T) {
+	first := encode(t, "Root", everyTag())
+	for range 10 {
+		if !bytes.Equal(encode(t, "Root", everyTag()), first) {
+			t.Fatal("Writing the same tags gave different bytes")
+		}
+	}
+}
+
+// The example from the NBT specification, written by hand
+func TestKnownEncoding(t *testing.T) {
+	expected := []byte{
+		TAG_COMPOUND, 0, 11, 'h', 'e', 'l', 'l', 'o', ' ', 'w', 'o', 'r', 'l', 'd',
+		TAG_STRING, 0, 4, 'n', 'a', 'm', 'e', 0, 9, 'B', 'a', 'n', 'a', 'n', 'r', 'a', 'm', 'a',
+		TAG_END,
+	}
+	if encoded := encode(t, "hello world", Compound{"name": "Bananrama"}); !bytes.Equal(encoded, expected) {
+		t.Fatalf("Expected %v, got %v", expected, encoded)
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	encoded := encode(t, "Root", everyTag())
+	for length := range encoded {
+		if _, _, err := Read(bytes.NewReader(encoded[:length])); err == nil {
+			t.Fatalf("Read %d of %d bytes without an error", length, len(encoded))
+		}
+	}
+}
+
+func TestReadRejectsMalformed(t *testing.T) {
+	tests := map[string][]byte{
+		"not a compound":    {TAG_STRING, 0, 0, 0, 0},
+		"unknown tag":       {TAG_COMPOUND, 0, 0, 13, 0, 0},
+		"negative length":   {TAG_COMPOUND, 0, 0, TAG_BYTE_ARRAY, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, TAG_END},
+		"list of end tags":  {TAG_COMPOUND, 0, 0, TAG_LIST, 0, 0, TAG_END, 0, 0, 0, 1, TAG_END},
+		"oversized length":  {TAG_COMPOUND, 0, 0, TAG_INT_ARRAY, 0, 0, 0x7F, 0xFF, 0xFF, 0xFF, TAG_END},
+		"unterminated root": {TAG_COMPOUND, 0, 0, TAG_BYTE, 0, 1, 'a', 1},
+	}
+	for name, data := range tests {
+		if _, _, err := Read(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s was read without an error", name)
+		}
+	}
+}
+
+func TestReadRejectsDeepNesting(t *testing.T) {
+	data := []byte{TAG_COMPOUND, 0, 0}
+	for range MAX_DEPTH + 1 {
+		data = append(data, TAG_COMPOUND, 0, 1, 'a')
+	}
+	if _, _, err := Read(bytes.NewReader(data)); err == nil {
+		t.Fatal("Read tags nested deeper than the limit")
+	}
+}
+
+func TestWriteRejectsUnsupported(t *testing.T) {
+	tests := map[string]Compound{
+		"unsupported type":   {"Int": 1},
+		"mismatched list":    {"List": List{Type: TAG_BYTE, Values: []any{int16(1)}}},
+		"nested unsupported": {"Compound": Compound{"Bool": true}},
+		"oversized string":   {"String": string(make([]byte, 1<<16))},
+	}
+	for name, root := range tests {
+		var buffer bytes.Buffer
+		if err := Write(&buffer, "Root", root); err == nil {
+			t.Errorf("%s was written without an error", name)
+		}
+	}
+}
+
+func TestCloneIsDeep(t *testing.T) {
+	original := everyTag()
+	clone := original.Clone()
+	clone["ByteArray"].([]byte)[0] = 42
+	clone["Compound"].(Compound)["Lists"].(List).Values[0].(List).Values[0] = "changed"
+	clone["Compounds"].(List).Values[0].(Compound)["Name"] = "changed"
+	if !reflect.DeepEqual(original, everyTag()) {
+		t.Fatal("Changing a clone changed the original")
+	}
+}
+
+func TestGet(t *testing.T) {
+	root := everyTag()
+	if value, ok := Get[int16](root, "Short"); !ok || value != -1234 {
+		t.Fatalf("Expected -1234, got %d", value)
+	}
+	if _, ok := Get[int32](root, "Short"); ok {
+		t.Fatal("Got a short as an int")
+	}
+	if _, ok := Get[int8](root, "Missing"); ok {
+		t.Fatal("Got a missing tag")
+	}
+}
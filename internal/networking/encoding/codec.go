@@ -110,3 +110,23 @@ func (r *PacketReader) Bytes(length int) ([]byte, error) {
 	_, err := io.ReadFull(r.r, buf)
 	return buf, err
 }
+
+func (w *PacketWriter) Int(v int32) error {
+	return binary.Write(w.w, binary.BigEndian, v)
+}
+
+func (r *PacketReader) Int() (int32, error) {
+	var v int32
+	err := binary.Read(r.r, binary.BigEndian, &v)
+	return v, err
+}
+
+func (w *PacketWriter) UShort(v uint16) error {
+	return binary.Write(w.w, binary.BigEndian, v)
+}
+
+func (r *PacketReader) UShort() (uint16, error) {
+	var v uint16
+	err := binary.Read(r.r, binary.BigEndian, &v)
+	return v, err
+}
@@ -0,0 +1,128 @@
+package encoding
+
+type ExtInfoData struct {
+	AppName        string
+	ExtensionCount int16
+}
+
+type ExtEntryData struct {
+	ExtName string
+	Version int32
+}
+
+type SetClickDistanceData struct {
+	Distance float32
+}
+
+type CustomBlockSupportLevelData struct {
+	SupportLevel byte
+}
+
+type SetSpawnpointData struct {
+	X     float32
+	Y     float32
+	Z     float32
+	Yaw   byte
+	Pitch byte
+}
+
+type VelocityControlData struct {
+	X     int32
+	Y     int32
+	Z     int32
+	XMode byte
+	YMode byte
+	ZMode byte
+}
+
+type DefineEffectData struct {
+	EffectID          byte
+	U1                byte
+	V1                byte
+	U2                byte
+	V2                byte
+	RedTint           byte
+	GreenTint         byte
+	BlueTint          byte
+	FrameCount        byte
+	ParticleCount     byte
+	ParticleSize      byte
+	SizeVariation     int32
+	Spread            uint16
+	Speed             int32
+	Gravity           int32
+	BaseLifetime      int32
+	LifetimeVariation int32
+	CollideFlags      byte
+	FullBright        byte
+}
+
+type SpawnEffectData struct {
+	EffectID byte
+	X        int32
+	Y        int32
+	Z        int32
+	OriginX  int32
+	OriginY  int32
+	OriginZ  int32
+}
+
+type SetTextHotKeyData struct {
+	Label   string
+	Action  string
+	KeyCode int32
+	KeyMods byte
+}
+
+type TwoWayPingData struct {
+	Direction byte
+	Data      int16
+}
+
+type PluginMessageData struct {
+	Channel byte
+	Data    [64]byte
+}
+
+type SetTextColorData struct {
+	Red   byte
+	Green byte
+	Blue  byte
+	Alpha byte
+	Code  byte
+}
+
+type EnvSetColorData struct {
+	Variable byte
+	Red      int16
+	Green    int16
+	Blue     int16
+}
+
+type EnvSetMapAppearanceData struct {
+	TextureURL string
+	SideBlock  byte
+	EdgeBlock  byte
+	SideLevel  int16
+}
+
+type LightingModeData struct {
+	Mode   byte
+	Locked byte
+}
+
+type SetInventoryOrderData struct {
+	BlockType byte
+	Order     byte
+}
+
+type ToggleBlockListData struct {
+	Open byte
+}
+
+// Indices are into the level's block array, and only the first Count+1 entries are used
+type BulkBlockUpdateData struct {
+	Count   byte
+	Indices [256]int32
+	Blocks  [256]byte
+}
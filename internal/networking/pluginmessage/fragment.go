@@ -0,0 +1,88 @@
+// Splits messages larger than a single PluginMessage packet across several.
+// Every fragment starts with a 3 byte header: message id, fragment index, and payload length with FLAG_FINAL set on the last fragment
+package pluginmessage
+
+import "github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+
+const PACKET_SIZE = 64
+const HEADER_SIZE = 3
+const PAYLOAD_SIZE = PACKET_SIZE - HEADER_SIZE
+const MAX_FRAGMENTS = 256
+const MAX_MESSAGE_SIZE = PAYLOAD_SIZE * MAX_FRAGMENTS
+
+const FLAG_FINAL = 0x80
+
+const (
+	PLUGINMESSAGE_TOO_LARGE = iota
+	PLUGINMESSAGE_INVALID_LENGTH
+	PLUGINMESSAGE_OUT_OF_ORDER
+)
+
+func Fragment(messageID byte, message []byte) ([][PACKET_SIZE]byte, error) {
+	if len(message) > MAX_MESSAGE_SIZE {
+		return nil, cerror.NewErrorf(PLUGINMESSAGE_TOO_LARGE, "Message of %d bytes exceeds maximum of %d", len(message), MAX_MESSAGE_SIZE)
+	}
+	count := (len(message) + PAYLOAD_SIZE - 1) / PAYLOAD_SIZE
+	if count == 0 {
+		count = 1
+	}
+	fragments := make([][PACKET_SIZE]byte, count)
+	for i := range fragments {
+		payload := message[min(i*PAYLOAD_SIZE, len(message)):min((i+1)*PAYLOAD_SIZE, len(message))]
+		fragments[i][0] = messageID
+		fragments[i][1] = byte(i)
+		fragments[i][2] = byte(len(payload))
+		if i == count-1 {
+			fragments[i][2] |= FLAG_FINAL
+		}
+		copy(fragments[i][HEADER_SIZE:], payload)
+	}
+	return fragments, nil
+}
+
+type partial struct {
+	next byte
+	data []byte
+}
+
+// Reassembles fragments from a single sender. Fragments of one message must arrive in order, but messages may be interleaved
+type Reassembler struct {
+	partials map[byte]*partial
+}
+
+// Returns the full message once the final fragment has been added
+func (reassembler *Reassembler) Add(fragment [PACKET_SIZE]byte) ([]byte, bool, error) {
+	messageID, index := fragment[0], fragment[1]
+	length := int(fragment[2] &^ FLAG_FINAL)
+	final := fragment[2]&FLAG_FINAL != 0
+	if length > PAYLOAD_SIZE {
+		delete(reassembler.partials, messageID)
+		return nil, false, cerror.NewErrorf(PLUGINMESSAGE_INVALID_LENGTH, "Fragment payload length %d exceeds %d", length, PAYLOAD_SIZE)
+	}
+
+	current, ok := reassembler.partials[messageID]
+	if !ok {
+		current = &partial{}
+		reassembler.partials[messageID] = current
+	}
+	if index != current.next {
+		delete(reassembler.partials, messageID)
+		return nil, false, cerror.NewErrorf(PLUGINMESSAGE_OUT_OF_ORDER, "Expected fragment %d of message %d, got %d", current.next, messageID, index)
+	}
+	current.data = append(current.data, fragment[HEADER_SIZE:HEADER_SIZE+length]...)
+	current.next++
+
+	if !final {
+		if index == MAX_FRAGMENTS-1 {
+			delete(reassembler.partials, messageID)
+			return nil, false, cerror.NewErrorf(PLUGINMESSAGE_TOO_LARGE, "Message %d exceeds %d fragments", messageID, MAX_FRAGMENTS)
+		}
+		return nil, false, nil
+	}
+	delete(reassembler.partials, messageID)
+	return current.data, true, nil
+}
+
+func NewReassembler() *Reassembler {
+	return &Reassembler{partials: make(map[byte]*partial)}
+}
@@ -0,0 +1,38 @@
+package protocol
+
+// UserType sent in Identification by clients supporting the Classic Protocol Extension
+const CPE_MAGIC = 0x42
+
+const (
+	EXT_CLICK_DISTANCE     = "ClickDistance"
+	EXT_CUSTOM_BLOCKS      = "CustomBlocks"
+	EXT_SET_SPAWNPOINT     = "SetSpawnpoint"
+	EXT_VELOCITY_CONTROL   = "VelocityControl"
+	EXT_CUSTOM_PARTICLES   = "CustomParticles"
+	EXT_TEXT_HOT_KEY       = "TextHotKey"
+	EXT_TWO_WAY_PING       = "TwoWayPing"
+	EXT_PLUGIN_MESSAGES    = "PluginMessages"
+	EXT_TEXT_COLORS        = "TextColors"
+	EXT_LIGHTING_MODE      = "LightingMode"
+	EXT_INVENTORY_ORDER    = "InventoryOrder"
+	EXT_TOGGLE_BLOCK_LIST  = "ToggleBlockList"
+	EXT_BULK_BLOCK_UPDATE  = "BulkBlockUpdate"
+	EXT_ENV_COLORS         = "EnvColors"
+	EXT_ENV_MAP_APPEARANCE = "EnvMapAppearance"
+)
+
+type Extension struct {
+	Name    string
+	Version int32
+}
+
+// Highest CustomBlocks support level the server understands
+const CUSTOM_BLOCKS_SUPPORT_LEVEL = 1
+
+// Most block changes a single BulkBlockUpdate can carry
+const BULK_BLOCK_UPDATE_SIZE = 256
+
+const (
+	PING_DIRECTION_CLIENT = iota // Client to server and back
+	PING_DIRECTION_SERVER        // Server to client and back
+)
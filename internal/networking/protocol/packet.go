@@ -27,6 +27,60 @@ const (
 	PacketID_UpdateUserType
 )
 
+const (
+	USER_TYPE_NORMAL = 0x00
+	// Lets vanilla clients break bedrock
+	USER_TYPE_OP = 0x64
+)
+
+// Classic Protocol Extension packets
+const (
+	PacketID_ExtInfo = 0x10 + iota
+	PacketID_ExtEntry
+	PacketID_SetClickDistance
+	PacketID_CustomBlockSupportLevel
+	PacketID_HoldThis
+	PacketID_SetTextHotKey
+	PacketID_ExtAddPlayerName
+	PacketID_ExtAddEntity
+	PacketID_ExtRemovePlayerName
+	PacketID_EnvSetColor
+	PacketID_MakeSelection
+	PacketID_RemoveSelection
+	PacketID_SetBlockPermission
+	PacketID_ChangeModel
+	PacketID_EnvSetMapAppearance
+	PacketID_EnvSetWeatherType
+	PacketID_HackControl
+	PacketID_ExtAddEntity2
+	PacketID_PlayerClicked
+	PacketID_DefineBlock
+	PacketID_RemoveBlockDefinition
+	PacketID_DefineBlockExt
+	PacketID_BulkBlockUpdate
+	PacketID_SetTextColor
+	PacketID_SetMapEnvUrl
+	PacketID_SetMapEnvProperty
+	PacketID_SetEntityProperty
+	PacketID_TwoWayPing
+	PacketID_SetInventoryOrder
+	PacketID_SetHotbar
+	PacketID_SetSpawnpoint
+	PacketID_VelocityControl
+	PacketID_DefineEffect
+	PacketID_SpawnEffect
+	PacketID_DefineModel
+	PacketID_DefineModelPart
+	PacketID_UndefineModel
+	PacketID_PluginMessage
+	PacketID_ExtEntityTeleport
+	PacketID_LightingMode
+	PacketID_CinematicGui
+	PacketID_NotifyAction
+	PacketID_NotifyPositionAction
+	PacketID_ToggleBlockList
+)
+
 type Packet interface {
 	ID() PacketID
 	EncodeToWriter(writer *encoding.PacketWriter) error
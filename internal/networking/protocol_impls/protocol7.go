@@ -69,6 +69,9 @@ func (p *Protocol7) CreatePacketBuilder(id protocol.PacketID) (protocol.PacketBu
 	case protocol.PacketID_UpdateUserType:
 		return &updateUserTypeBuilder7{}, nil
 	default:
+		if builder, ok := p.createExtensionPacketBuilder(id); ok {
+			return builder, nil
+		}
 		return nil, cerror.NewErrorf(protocol.PROTOCOL_PACKET_NOT_FOUND, "Packet %d not found", id)
 	}
 }
@@ -699,7 +702,7 @@ func (p *PositionAndOrientationUpdatePacket7) ID() protocol.PacketID {
 }
 
 func (p *PositionAndOrientationUpdatePacket7) Size() int {
-	return 8
+	return 7
 }
 
 func (p *PositionAndOrientationUpdatePacket7) Data() any {
@@ -721,7 +724,7 @@ func (p *PositionAndOrientationUpdatePacket7) EncodeToWriter(writer *encoding.Pa
 type positionAndOrientationUpdateBuilder7 struct{}
 
 func (b *positionAndOrientationUpdateBuilder7) GetSize() int {
-	return 7
+	return 6
 }
 
 func (b *positionAndOrientationUpdateBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
@@ -993,7 +996,7 @@ func (p *MessagePacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
 type messageBuilder7 struct{}
 
 func (b *messageBuilder7) GetSize() int {
-	return 64
+	return 65
 }
 
 func (b *messageBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
@@ -1035,7 +1038,7 @@ func (p *DisconnectPlayerPacket7) ID() protocol.PacketID {
 }
 
 func (p *DisconnectPlayerPacket7) Size() int {
-	return 66
+	return 65
 }
 
 func (p *DisconnectPlayerPacket7) Data() any {
@@ -1052,7 +1055,7 @@ func (p *DisconnectPlayerPacket7) EncodeToWriter(writer *encoding.PacketWriter)
 type disconnectPlayerBuilder7 struct{}
 
 func (b *disconnectPlayerBuilder7) GetSize() int {
-	return 65
+	return 64
 }
 
 func (b *disconnectPlayerBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
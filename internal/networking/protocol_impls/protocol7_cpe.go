@@ -0,0 +1,1352 @@
+package protocol_impls
+
+import (
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
+)
+
+func (p *Protocol7) createExtensionPacketBuilder(id protocol.PacketID) (protocol.PacketBuilder, bool) {
+	switch id {
+	case protocol.PacketID_ExtInfo:
+		return &extInfoBuilder7{}, true
+	case protocol.PacketID_ExtEntry:
+		return &extEntryBuilder7{}, true
+	case protocol.PacketID_SetClickDistance:
+		return &setClickDistanceBuilder7{}, true
+	case protocol.PacketID_CustomBlockSupportLevel:
+		return &customBlockSupportLevelBuilder7{}, true
+	case protocol.PacketID_SetSpawnpoint:
+		return &setSpawnpointBuilder7{}, true
+	case protocol.PacketID_VelocityControl:
+		return &velocityControlBuilder7{}, true
+	case protocol.PacketID_DefineEffect:
+		return &defineEffectBuilder7{}, true
+	case protocol.PacketID_SpawnEffect:
+		return &spawnEffectBuilder7{}, true
+	case protocol.PacketID_SetTextHotKey:
+		return &setTextHotKeyBuilder7{}, true
+	case protocol.PacketID_TwoWayPing:
+		return &twoWayPingBuilder7{}, true
+	case protocol.PacketID_PluginMessage:
+		return &pluginMessageBuilder7{}, true
+	case protocol.PacketID_SetTextColor:
+		return &setTextColorBuilder7{}, true
+	case protocol.PacketID_EnvSetColor:
+		return &envSetColorBuilder7{}, true
+	case protocol.PacketID_EnvSetMapAppearance:
+		return &envSetMapAppearanceBuilder7{}, true
+	case protocol.PacketID_LightingMode:
+		return &lightingModeBuilder7{}, true
+	case protocol.PacketID_SetInventoryOrder:
+		return &setInventoryOrderBuilder7{}, true
+	case protocol.PacketID_ToggleBlockList:
+		return &toggleBlockListBuilder7{}, true
+	case protocol.PacketID_BulkBlockUpdate:
+		return &bulkBlockUpdateBuilder7{}, true
+	default:
+		return nil, false
+	}
+}
+
+type ExtInfoPacket7 struct {
+	id   protocol.PacketID
+	data encoding.ExtInfoData
+}
+
+func (p *ExtInfoPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *ExtInfoPacket7) Size() int {
+	return 67
+}
+
+func (p *ExtInfoPacket7) Data() any {
+	return p.data
+}
+
+func (p *ExtInfoPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.String64(p.data.AppName),
+		writer.Short(p.data.ExtensionCount),
+	)
+}
+
+type extInfoBuilder7 struct{}
+
+func (b *extInfoBuilder7) GetSize() int {
+	return 66
+}
+
+func (b *extInfoBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.ExtInfoData
+	var err error
+
+	data.AppName, err = reader.String64()
+	if err != nil {
+		return nil, err
+	}
+
+	data.ExtensionCount, err = reader.Short()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtInfoPacket7{
+		id:   protocol.PacketID_ExtInfo,
+		data: data,
+	}, nil
+}
+
+func (b *extInfoBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.ExtInfoData](data, func(d encoding.ExtInfoData) protocol.Packet {
+		return &ExtInfoPacket7{
+			id:   protocol.PacketID_ExtInfo,
+			data: d,
+		}
+	})
+}
+
+type ExtEntryPacket7 struct {
+	id   protocol.PacketID
+	data encoding.ExtEntryData
+}
+
+func (p *ExtEntryPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *ExtEntryPacket7) Size() int {
+	return 69
+}
+
+func (p *ExtEntryPacket7) Data() any {
+	return p.data
+}
+
+func (p *ExtEntryPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.String64(p.data.ExtName),
+		writer.Int(p.data.Version),
+	)
+}
+
+type extEntryBuilder7 struct{}
+
+func (b *extEntryBuilder7) GetSize() int {
+	return 68
+}
+
+func (b *extEntryBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.ExtEntryData
+	var err error
+
+	data.ExtName, err = reader.String64()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Version, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtEntryPacket7{
+		id:   protocol.PacketID_ExtEntry,
+		data: data,
+	}, nil
+}
+
+func (b *extEntryBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.ExtEntryData](data, func(d encoding.ExtEntryData) protocol.Packet {
+		return &ExtEntryPacket7{
+			id:   protocol.PacketID_ExtEntry,
+			data: d,
+		}
+	})
+}
+
+type SetClickDistancePacket7 struct {
+	id   protocol.PacketID
+	data encoding.SetClickDistanceData
+}
+
+func (p *SetClickDistancePacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *SetClickDistancePacket7) Size() int {
+	return 3
+}
+
+func (p *SetClickDistancePacket7) Data() any {
+	return p.data
+}
+
+func (p *SetClickDistancePacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.FShort(p.data.Distance),
+	)
+}
+
+type setClickDistanceBuilder7 struct{}
+
+func (b *setClickDistanceBuilder7) GetSize() int {
+	return 2
+}
+
+func (b *setClickDistanceBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.SetClickDistanceData
+	var err error
+
+	data.Distance, err = reader.FShort()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetClickDistancePacket7{
+		id:   protocol.PacketID_SetClickDistance,
+		data: data,
+	}, nil
+}
+
+func (b *setClickDistanceBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.SetClickDistanceData](data, func(d encoding.SetClickDistanceData) protocol.Packet {
+		return &SetClickDistancePacket7{
+			id:   protocol.PacketID_SetClickDistance,
+			data: d,
+		}
+	})
+}
+
+type CustomBlockSupportLevelPacket7 struct {
+	id   protocol.PacketID
+	data encoding.CustomBlockSupportLevelData
+}
+
+func (p *CustomBlockSupportLevelPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *CustomBlockSupportLevelPacket7) Size() int {
+	return 2
+}
+
+func (p *CustomBlockSupportLevelPacket7) Data() any {
+	return p.data
+}
+
+func (p *CustomBlockSupportLevelPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.Byte(p.data.SupportLevel),
+	)
+}
+
+type customBlockSupportLevelBuilder7 struct{}
+
+func (b *customBlockSupportLevelBuilder7) GetSize() int {
+	return 1
+}
+
+func (b *customBlockSupportLevelBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.CustomBlockSupportLevelData
+	var err error
+
+	data.SupportLevel, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CustomBlockSupportLevelPacket7{
+		id:   protocol.PacketID_CustomBlockSupportLevel,
+		data: data,
+	}, nil
+}
+
+func (b *customBlockSupportLevelBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.CustomBlockSupportLevelData](data, func(d encoding.CustomBlockSupportLevelData) protocol.Packet {
+		return &CustomBlockSupportLevelPacket7{
+			id:   protocol.PacketID_CustomBlockSupportLevel,
+			data: d,
+		}
+	})
+}
+
+type SetSpawnpointPacket7 struct {
+	id   protocol.PacketID
+	data encoding.SetSpawnpointData
+}
+
+func (p *SetSpawnpointPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *SetSpawnpointPacket7) Size() int {
+	return 9
+}
+
+func (p *SetSpawnpointPacket7) Data() any {
+	return p.data
+}
+
+func (p *SetSpawnpointPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.FShort(p.data.X),
+		writer.FShort(p.data.Y),
+		writer.FShort(p.data.Z),
+		writer.Byte(p.data.Yaw),
+		writer.Byte(p.data.Pitch),
+	)
+}
+
+type setSpawnpointBuilder7 struct{}
+
+func (b *setSpawnpointBuilder7) GetSize() int {
+	return 8
+}
+
+func (b *setSpawnpointBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.SetSpawnpointData
+	var err error
+
+	data.X, err = reader.FShort()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Y, err = reader.FShort()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Z, err = reader.FShort()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Yaw, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Pitch, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetSpawnpointPacket7{
+		id:   protocol.PacketID_SetSpawnpoint,
+		data: data,
+	}, nil
+}
+
+func (b *setSpawnpointBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.SetSpawnpointData](data, func(d encoding.SetSpawnpointData) protocol.Packet {
+		return &SetSpawnpointPacket7{
+			id:   protocol.PacketID_SetSpawnpoint,
+			data: d,
+		}
+	})
+}
+
+type VelocityControlPacket7 struct {
+	id   protocol.PacketID
+	data encoding.VelocityControlData
+}
+
+func (p *VelocityControlPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *VelocityControlPacket7) Size() int {
+	return 16
+}
+
+func (p *VelocityControlPacket7) Data() any {
+	return p.data
+}
+
+func (p *VelocityControlPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.Int(p.data.X),
+		writer.Int(p.data.Y),
+		writer.Int(p.data.Z),
+		writer.Byte(p.data.XMode),
+		writer.Byte(p.data.YMode),
+		writer.Byte(p.data.ZMode),
+	)
+}
+
+type velocityControlBuilder7 struct{}
+
+func (b *velocityControlBuilder7) GetSize() int {
+	return 15
+}
+
+func (b *velocityControlBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.VelocityControlData
+	var err error
+
+	data.X, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Y, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Z, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.XMode, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.YMode, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.ZMode, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	return &VelocityControlPacket7{
+		id:   protocol.PacketID_VelocityControl,
+		data: data,
+	}, nil
+}
+
+func (b *velocityControlBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.VelocityControlData](data, func(d encoding.VelocityControlData) protocol.Packet {
+		return &VelocityControlPacket7{
+			id:   protocol.PacketID_VelocityControl,
+			data: d,
+		}
+	})
+}
+
+type DefineEffectPacket7 struct {
+	id   protocol.PacketID
+	data encoding.DefineEffectData
+}
+
+func (p *DefineEffectPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *DefineEffectPacket7) Size() int {
+	return 36
+}
+
+func (p *DefineEffectPacket7) Data() any {
+	return p.data
+}
+
+func (p *DefineEffectPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.Byte(p.data.EffectID),
+		writer.Byte(p.data.U1),
+		writer.Byte(p.data.V1),
+		writer.Byte(p.data.U2),
+		writer.Byte(p.data.V2),
+		writer.Byte(p.data.RedTint),
+		writer.Byte(p.data.GreenTint),
+		writer.Byte(p.data.BlueTint),
+		writer.Byte(p.data.FrameCount),
+		writer.Byte(p.data.ParticleCount),
+		writer.Byte(p.data.ParticleSize),
+		writer.Int(p.data.SizeVariation),
+		writer.UShort(p.data.Spread),
+		writer.Int(p.data.Speed),
+		writer.Int(p.data.Gravity),
+		writer.Int(p.data.BaseLifetime),
+		writer.Int(p.data.LifetimeVariation),
+		writer.Byte(p.data.CollideFlags),
+		writer.Byte(p.data.FullBright),
+	)
+}
+
+type defineEffectBuilder7 struct{}
+
+func (b *defineEffectBuilder7) GetSize() int {
+	return 35
+}
+
+func (b *defineEffectBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.DefineEffectData
+	var err error
+
+	data.EffectID, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.U1, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.V1, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.U2, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.V2, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.RedTint, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.GreenTint, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.BlueTint, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.FrameCount, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.ParticleCount, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.ParticleSize, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.SizeVariation, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Spread, err = reader.UShort()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Speed, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Gravity, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.BaseLifetime, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.LifetimeVariation, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.CollideFlags, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.FullBright, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DefineEffectPacket7{
+		id:   protocol.PacketID_DefineEffect,
+		data: data,
+	}, nil
+}
+
+func (b *defineEffectBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.DefineEffectData](data, func(d encoding.DefineEffectData) protocol.Packet {
+		return &DefineEffectPacket7{
+			id:   protocol.PacketID_DefineEffect,
+			data: d,
+		}
+	})
+}
+
+type SpawnEffectPacket7 struct {
+	id   protocol.PacketID
+	data encoding.SpawnEffectData
+}
+
+func (p *SpawnEffectPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *SpawnEffectPacket7) Size() int {
+	return 26
+}
+
+func (p *SpawnEffectPacket7) Data() any {
+	return p.data
+}
+
+func (p *SpawnEffectPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.Byte(p.data.EffectID),
+		writer.Int(p.data.X),
+		writer.Int(p.data.Y),
+		writer.Int(p.data.Z),
+		writer.Int(p.data.OriginX),
+		writer.Int(p.data.OriginY),
+		writer.Int(p.data.OriginZ),
+	)
+}
+
+type spawnEffectBuilder7 struct{}
+
+func (b *spawnEffectBuilder7) GetSize() int {
+	return 25
+}
+
+func (b *spawnEffectBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.SpawnEffectData
+	var err error
+
+	data.EffectID, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.X, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Y, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Z, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.OriginX, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.OriginY, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.OriginZ, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SpawnEffectPacket7{
+		id:   protocol.PacketID_SpawnEffect,
+		data: data,
+	}, nil
+}
+
+func (b *spawnEffectBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.SpawnEffectData](data, func(d encoding.SpawnEffectData) protocol.Packet {
+		return &SpawnEffectPacket7{
+			id:   protocol.PacketID_SpawnEffect,
+			data: d,
+		}
+	})
+}
+
+type SetTextHotKeyPacket7 struct {
+	id   protocol.PacketID
+	data encoding.SetTextHotKeyData
+}
+
+func (p *SetTextHotKeyPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *SetTextHotKeyPacket7) Size() int {
+	return 134
+}
+
+func (p *SetTextHotKeyPacket7) Data() any {
+	return p.data
+}
+
+func (p *SetTextHotKeyPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.String64(p.data.Label),
+		writer.String64(p.data.Action),
+		writer.Int(p.data.KeyCode),
+		writer.Byte(p.data.KeyMods),
+	)
+}
+
+type setTextHotKeyBuilder7 struct{}
+
+func (b *setTextHotKeyBuilder7) GetSize() int {
+	return 133
+}
+
+func (b *setTextHotKeyBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.SetTextHotKeyData
+	var err error
+
+	data.Label, err = reader.String64()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Action, err = reader.String64()
+	if err != nil {
+		return nil, err
+	}
+
+	data.KeyCode, err = reader.Int()
+	if err != nil {
+		return nil, err
+	}
+
+	data.KeyMods, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetTextHotKeyPacket7{
+		id:   protocol.PacketID_SetTextHotKey,
+		data: data,
+	}, nil
+}
+
+func (b *setTextHotKeyBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.SetTextHotKeyData](data, func(d encoding.SetTextHotKeyData) protocol.Packet {
+		return &SetTextHotKeyPacket7{
+			id:   protocol.PacketID_SetTextHotKey,
+			data: d,
+		}
+	})
+}
+
+type TwoWayPingPacket7 struct {
+	id   protocol.PacketID
+	data encoding.TwoWayPingData
+}
+
+func (p *TwoWayPingPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *TwoWayPingPacket7) Size() int {
+	return 4
+}
+
+func (p *TwoWayPingPacket7) Data() any {
+	return p.data
+}
+
+func (p *TwoWayPingPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.Byte(p.data.Direction),
+		writer.Short(p.data.Data),
+	)
+}
+
+type twoWayPingBuilder7 struct{}
+
+func (b *twoWayPingBuilder7) GetSize() int {
+	return 3
+}
+
+func (b *twoWayPingBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.TwoWayPingData
+	var err error
+
+	data.Direction, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Data, err = reader.Short()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoWayPingPacket7{
+		id:   protocol.PacketID_TwoWayPing,
+		data: data,
+	}, nil
+}
+
+func (b *twoWayPingBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.TwoWayPingData](data, func(d encoding.TwoWayPingData) protocol.Packet {
+		return &TwoWayPingPacket7{
+			id:   protocol.PacketID_TwoWayPing,
+			data: d,
+		}
+	})
+}
+
+type PluginMessagePacket7 struct {
+	id   protocol.PacketID
+	data encoding.PluginMessageData
+}
+
+func (p *PluginMessagePacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *PluginMessagePacket7) Size() int {
+	return 66
+}
+
+func (p *PluginMessagePacket7) Data() any {
+	return p.data
+}
+
+func (p *PluginMessagePacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.Byte(p.data.Channel),
+		writer.Bytes(p.data.Data[:]),
+	)
+}
+
+type pluginMessageBuilder7 struct{}
+
+func (b *pluginMessageBuilder7) GetSize() int {
+	return 65
+}
+
+func (b *pluginMessageBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.PluginMessageData
+	var err error
+
+	data.Channel, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	messageBytes, err := reader.Bytes(64)
+	if err != nil {
+		return nil, err
+	}
+	copy(data.Data[:], messageBytes)
+
+	return &PluginMessagePacket7{
+		id:   protocol.PacketID_PluginMessage,
+		data: data,
+	}, nil
+}
+
+func (b *pluginMessageBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.PluginMessageData](data, func(d encoding.PluginMessageData) protocol.Packet {
+		return &PluginMessagePacket7{
+			id:   protocol.PacketID_PluginMessage,
+			data: d,
+		}
+	})
+}
+
+type SetTextColorPacket7 struct {
+	id   protocol.PacketID
+	data encoding.SetTextColorData
+}
+
+func (p *SetTextColorPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *SetTextColorPacket7) Size() int {
+	return 6
+}
+
+func (p *SetTextColorPacket7) Data() any {
+	return p.data
+}
+
+func (p *SetTextColorPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.Byte(p.data.Red),
+		writer.Byte(p.data.Green),
+		writer.Byte(p.data.Blue),
+		writer.Byte(p.data.Alpha),
+		writer.Byte(p.data.Code),
+	)
+}
+
+type setTextColorBuilder7 struct{}
+
+func (b *setTextColorBuilder7) GetSize() int {
+	return 5
+}
+
+func (b *setTextColorBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.SetTextColorData
+	var err error
+
+	data.Red, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Green, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Blue, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Alpha, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Code, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetTextColorPacket7{
+		id:   protocol.PacketID_SetTextColor,
+		data: data,
+	}, nil
+}
+
+func (b *setTextColorBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.SetTextColorData](data, func(d encoding.SetTextColorData) protocol.Packet {
+		return &SetTextColorPacket7{
+			id:   protocol.PacketID_SetTextColor,
+			data: d,
+		}
+	})
+}
+
+type EnvSetColorPacket7 struct {
+	id   protocol.PacketID
+	data encoding.EnvSetColorData
+}
+
+func (p *EnvSetColorPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *EnvSetColorPacket7) Size() int {
+	return 8
+}
+
+func (p *EnvSetColorPacket7) Data() any {
+	return p.data
+}
+
+func (p *EnvSetColorPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.Byte(p.data.Variable),
+		writer.Short(p.data.Red),
+		writer.Short(p.data.Green),
+		writer.Short(p.data.Blue),
+	)
+}
+
+type envSetColorBuilder7 struct{}
+
+func (b *envSetColorBuilder7) GetSize() int {
+	return 7
+}
+
+func (b *envSetColorBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.EnvSetColorData
+	var err error
+
+	data.Variable, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Red, err = reader.Short()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Green, err = reader.Short()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Blue, err = reader.Short()
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnvSetColorPacket7{
+		id:   protocol.PacketID_EnvSetColor,
+		data: data,
+	}, nil
+}
+
+func (b *envSetColorBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.EnvSetColorData](data, func(d encoding.EnvSetColorData) protocol.Packet {
+		return &EnvSetColorPacket7{
+			id:   protocol.PacketID_EnvSetColor,
+			data: d,
+		}
+	})
+}
+
+type EnvSetMapAppearancePacket7 struct {
+	id   protocol.PacketID
+	data encoding.EnvSetMapAppearanceData
+}
+
+func (p *EnvSetMapAppearancePacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *EnvSetMapAppearancePacket7) Size() int {
+	return 69
+}
+
+func (p *EnvSetMapAppearancePacket7) Data() any {
+	return p.data
+}
+
+func (p *EnvSetMapAppearancePacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.String64(p.data.TextureURL),
+		writer.Byte(p.data.SideBlock),
+		writer.Byte(p.data.EdgeBlock),
+		writer.Short(p.data.SideLevel),
+	)
+}
+
+type envSetMapAppearanceBuilder7 struct{}
+
+func (b *envSetMapAppearanceBuilder7) GetSize() int {
+	return 68
+}
+
+func (b *envSetMapAppearanceBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.EnvSetMapAppearanceData
+	var err error
+
+	data.TextureURL, err = reader.String64()
+	if err != nil {
+		return nil, err
+	}
+
+	data.SideBlock, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.EdgeBlock, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.SideLevel, err = reader.Short()
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnvSetMapAppearancePacket7{
+		id:   protocol.PacketID_EnvSetMapAppearance,
+		data: data,
+	}, nil
+}
+
+func (b *envSetMapAppearanceBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.EnvSetMapAppearanceData](data, func(d encoding.EnvSetMapAppearanceData) protocol.Packet {
+		return &EnvSetMapAppearancePacket7{
+			id:   protocol.PacketID_EnvSetMapAppearance,
+			data: d,
+		}
+	})
+}
+
+type LightingModePacket7 struct {
+	id   protocol.PacketID
+	data encoding.LightingModeData
+}
+
+func (p *LightingModePacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *LightingModePacket7) Size() int {
+	return 3
+}
+
+func (p *LightingModePacket7) Data() any {
+	return p.data
+}
+
+func (p *LightingModePacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.Byte(p.data.Mode),
+		writer.Byte(p.data.Locked),
+	)
+}
+
+type lightingModeBuilder7 struct{}
+
+func (b *lightingModeBuilder7) GetSize() int {
+	return 2
+}
+
+func (b *lightingModeBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.LightingModeData
+	var err error
+
+	data.Mode, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Locked, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	return &LightingModePacket7{
+		id:   protocol.PacketID_LightingMode,
+		data: data,
+	}, nil
+}
+
+func (b *lightingModeBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.LightingModeData](data, func(d encoding.LightingModeData) protocol.Packet {
+		return &LightingModePacket7{
+			id:   protocol.PacketID_LightingMode,
+			data: d,
+		}
+	})
+}
+
+type SetInventoryOrderPacket7 struct {
+	id   protocol.PacketID
+	data encoding.SetInventoryOrderData
+}
+
+func (p *SetInventoryOrderPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *SetInventoryOrderPacket7) Size() int {
+	return 3
+}
+
+func (p *SetInventoryOrderPacket7) Data() any {
+	return p.data
+}
+
+func (p *SetInventoryOrderPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.Byte(p.data.BlockType),
+		writer.Byte(p.data.Order),
+	)
+}
+
+type setInventoryOrderBuilder7 struct{}
+
+func (b *setInventoryOrderBuilder7) GetSize() int {
+	return 2
+}
+
+func (b *setInventoryOrderBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.SetInventoryOrderData
+	var err error
+
+	data.BlockType, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	data.Order, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SetInventoryOrderPacket7{
+		id:   protocol.PacketID_SetInventoryOrder,
+		data: data,
+	}, nil
+}
+
+func (b *setInventoryOrderBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.SetInventoryOrderData](data, func(d encoding.SetInventoryOrderData) protocol.Packet {
+		return &SetInventoryOrderPacket7{
+			id:   protocol.PacketID_SetInventoryOrder,
+			data: d,
+		}
+	})
+}
+
+type ToggleBlockListPacket7 struct {
+	id   protocol.PacketID
+	data encoding.ToggleBlockListData
+}
+
+func (p *ToggleBlockListPacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *ToggleBlockListPacket7) Size() int {
+	return 2
+}
+
+func (p *ToggleBlockListPacket7) Data() any {
+	return p.data
+}
+
+func (p *ToggleBlockListPacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	return writeError(
+		writer.Byte(byte(p.ID())),
+		writer.Byte(p.data.Open),
+	)
+}
+
+type toggleBlockListBuilder7 struct{}
+
+func (b *toggleBlockListBuilder7) GetSize() int {
+	return 1
+}
+
+func (b *toggleBlockListBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.ToggleBlockListData
+	var err error
+
+	data.Open, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ToggleBlockListPacket7{
+		id:   protocol.PacketID_ToggleBlockList,
+		data: data,
+	}, nil
+}
+
+func (b *toggleBlockListBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.ToggleBlockListData](data, func(d encoding.ToggleBlockListData) protocol.Packet {
+		return &ToggleBlockListPacket7{
+			id:   protocol.PacketID_ToggleBlockList,
+			data: d,
+		}
+	})
+}
+
+type BulkBlockUpdatePacket7 struct {
+	id   protocol.PacketID
+	data encoding.BulkBlockUpdateData
+}
+
+func (p *BulkBlockUpdatePacket7) ID() protocol.PacketID {
+	return p.id
+}
+
+func (p *BulkBlockUpdatePacket7) Size() int {
+	return 1282
+}
+
+func (p *BulkBlockUpdatePacket7) Data() any {
+	return p.data
+}
+
+func (p *BulkBlockUpdatePacket7) EncodeToWriter(writer *encoding.PacketWriter) error {
+	errs := []error{
+		writer.Byte(byte(p.ID())),
+		writer.Byte(p.data.Count),
+	}
+	for _, index := range p.data.Indices {
+		errs = append(errs, writer.Int(index))
+	}
+	errs = append(errs, writer.Bytes(p.data.Blocks[:]))
+	return writeError(errs...)
+}
+
+type bulkBlockUpdateBuilder7 struct{}
+
+func (b *bulkBlockUpdateBuilder7) GetSize() int {
+	return 1281
+}
+
+func (b *bulkBlockUpdateBuilder7) BuildFromReader(reader *encoding.PacketReader) (protocol.Packet, error) {
+	var data encoding.BulkBlockUpdateData
+	var err error
+
+	data.Count, err = reader.Byte()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range data.Indices {
+		data.Indices[i], err = reader.Int()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	blocks, err := reader.Bytes(len(data.Blocks))
+	if err != nil {
+		return nil, err
+	}
+	copy(data.Blocks[:], blocks)
+
+	return &BulkBlockUpdatePacket7{
+		id:   protocol.PacketID_BulkBlockUpdate,
+		data: data,
+	}, nil
+}
+
+func (b *bulkBlockUpdateBuilder7) Build(data any) (protocol.Packet, error) {
+	return buildPacket[encoding.BulkBlockUpdateData](data, func(d encoding.BulkBlockUpdateData) protocol.Packet {
+		return &BulkBlockUpdatePacket7{
+			id:   protocol.PacketID_BulkBlockUpdate,
+			data: d,
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package protocol_impls
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
+)
+
+// Every packet ID Protocol7 can build, classic and extension
+func protocol7Builders(t testing.TB) map[protocol.PacketID]protocol.PacketBuilder {
+	t.Helper()
+	proto := &Protocol7{}
+	builders := make(map[protocol.PacketID]protocol.PacketBuilder)
+	for id := range 256 {
+		if builder, err := proto.CreatePacketBuilder(protocol.PacketID(id)); err == nil {
+			builders[protocol.PacketID(id)] = builder
+		}
+	}
+	if len(builders) == 0 {
+		t.Fatal("Protocol7 has no packet builders")
+	}
+	return builders
+}
+
+// Decoded packets must encode back to something which decodes the same
+func checkRoundTrip(t *testing.T, builder protocol.PacketBuilder, packet protocol.Packet) {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := packet.EncodeToWriter(encoding.NewPacketWriter(&buffer)); err != nil {
+		t.Fatalf("Packet %d decoded but failed to encode: %v", packet.ID(), err)
+	}
+	encoded := buffer.Bytes()
+	if len(encoded) != packet.Size() || encoded[0] != byte(packet.ID()) {
+		t.Fatalf("Packet %d encoded to %d bytes starting %d, expected %d", packet.ID(), len(encoded), encoded[0], packet.Size())
+	}
+	decoded, _, err := protocol.DecodePacketFromBytes(builder, encoded[1:])
+	if err != nil {
+		t.Fatalf("Packet %d failed to decode after encoding: %v", packet.ID(), err)
+	}
+	if !reflect.DeepEqual(decoded.Data(), packet.Data()) {
+		t.Fatalf("Packet %d changed after encoding:\n%+v\n%+v", packet.ID(), packet.Data(), decoded.Data())
+	}
+}
+
+// Truncated packets are errors rather than panics or partial packets
+func TestBuildFromReaderTruncated(t *testing.T) {
+	for id, builder := range protocol7Builders(t) {
+		data := make([]byte, builder.GetSize())
+		for length := range data {
+			if packet, _, err := protocol.DecodePacketFromBytes(builder, data[:length]); err == nil {
+				t.Errorf("Packet %d decoded from %d of %d bytes: %+v", id, length, len(data), packet.Data())
+			}
+		}
+	}
+}
+
+func TestBuildFromReaderRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for id, builder := range protocol7Builders(t) {
+		for range 64 {
+			data := make([]byte, builder.GetSize())
+			random.Read(data)
+			packet, _, err := protocol.DecodePacketFromBytes(builder, data)
+			if err != nil {
+				continue
+			}
+			if packet.ID() != id {
+				t.Fatalf("Builder for packet %d built packet %d", id, packet.ID())
+			}
+			checkRoundTrip(t, builder, packet)
+		}
+	}
+}
+
+func FuzzBuildFromReader(f *testing.F) {
+	for id, builder := range protocol7Builders(f) {
+		f.Add(byte(id), make([]byte, builder.GetSize()))
+		f.Add(byte(id), bytes.Repeat([]byte{0xFF}, builder.GetSize()))
+	}
+	proto := &Protocol7{}
+	f.Fuzz(func(t *testing.T, id byte, data []byte) {
+		builder, err := proto.CreatePacketBuilder(protocol.PacketID(id))
+		if err != nil {
+			return
+		}
+		packet, _, err := protocol.DecodePacketFromBytes(builder, data)
+		if err != nil {
+			return
+		}
+		if len(data) < builder.GetSize() {
+			t.Fatalf("Packet %d decoded from %d bytes, less than its size of %d", id, len(data), builder.GetSize())
+		}
+		checkRoundTrip(t, builder, packet)
+	})
+}
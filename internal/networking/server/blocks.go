@@ -0,0 +1,290 @@
+package server
+
+import (
+	"log"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+const (
+	BLOCK_MODE_DESTROY = iota
+	BLOCK_MODE_PLACE
+)
+
+// Fastest a player can reasonably move, used to allow for positions being out of date
+const MAX_PLAYER_SPEED = 10
+
+// Leeway between the player's eye and the block's nearest face
+const REACH_LEEWAY = 1.5
+
+const (
+	BLOCKCHANGE_NOT_IN_LEVEL = iota
+	BLOCKCHANGE_OUT_OF_BOUNDS
+	BLOCKCHANGE_OUT_OF_REACH
+	BLOCKCHANGE_INVALID_BLOCK
+	BLOCKCHANGE_INVALID_MODE
+	BLOCKCHANGE_RESTRICTED_BLOCK
+	BLOCKCHANGE_ZONE_PROTECTED
+	BLOCKCHANGE_NO_PERMISSION
+)
+
+// Needed to change restricted blocks, followed by the block's name
+const BLOCK_PERMISSION_PREFIX = "block."
+
+const (
+	WORLD_PERMISSION_VISIT = "visit"
+	WORLD_PERMISSION_BUILD = "build"
+)
+
+// Node for an action in a level, such as world.main.build
+func WorldPermission(level *world.Level, action string) string {
+	return WorldNamePermission(level.Name(), action)
+}
+
+// By name, so permission can be checked before the world is loaded
+func WorldNamePermission(name string, action string) string {
+	return "world." + strings.ToLower(name) + "." + action
+}
+
+type BlockChange struct {
+	Player  *Player
+	Level   *world.Level
+	X, Y, Z int16
+	Mode    byte
+	// Block the client is holding. Not what's placed when destroying
+	Held     byte
+	Previous byte
+	// Made by a command rather than a click, so reach doesn't apply
+	Remote bool
+}
+
+// Block which the change results in
+func (change *BlockChange) Result() byte {
+	if change.Mode == BLOCK_MODE_DESTROY {
+		return world.BLOCK_AIR
+	}
+	return change.Held
+}
+
+// Returns an error to reject the change
+type BlockChangeValidator func(change *BlockChange) error
+
+// Run in order for every block change, after the position has been found to be within the level
+var BlockChangeValidators = []BlockChangeValidator{
+	validateBlockMode,
+	validateBlockReach,
+	validateBlockType,
+	validateBlockZone,
+	validateBlockPermission,
+}
+
+// Runs every validator, returning the first rejection
+func validateBlockChange(change *BlockChange) error {
+	for _, validate := range BlockChangeValidators {
+		if err := validate(change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateBlockMode(change *BlockChange) error {
+	if change.Mode != BLOCK_MODE_DESTROY && change.Mode != BLOCK_MODE_PLACE {
+		return cerror.NewErrorf(BLOCKCHANGE_INVALID_MODE, "Invalid block change mode %d", change.Mode)
+	}
+	return nil
+}
+
+func validateBlockReach(change *BlockChange) error {
+	if change.Remote {
+		return nil
+	}
+	position := change.Player.Position()
+	connection := change.Player.Connection()
+	dx := float64(position.X) - (float64(change.X) + 0.5)
+	dy := float64(position.Y) - (float64(change.Y) + 0.5)
+	dz := float64(position.Z) - (float64(change.Z) + 0.5)
+	distance := math.Sqrt(dx*dx + dy*dy + dz*dz)
+	allowed := float64(connection.ClickDistance()) + REACH_LEEWAY + MAX_PLAYER_SPEED*connection.LatencyTolerance().Seconds()
+	if distance > allowed {
+		return cerror.NewErrorf(BLOCKCHANGE_OUT_OF_REACH, "Block %d,%d,%d is %.1f blocks away, more than %.1f", change.X, change.Y, change.Z, distance, allowed)
+	}
+	return nil
+}
+
+func validateBlockType(change *BlockChange) error {
+	if !world.ValidBlock(change.Held) || (change.Mode == BLOCK_MODE_PLACE && change.Held == world.BLOCK_AIR) {
+		return cerror.NewErrorf(BLOCKCHANGE_INVALID_BLOCK, "Block type %d can't be placed", change.Held)
+	}
+	return nil
+}
+
+func validateBlockZone(change *BlockChange) error {
+	for _, zone := range change.Level.Behaviors().ZonesAt(change.X, change.Y, change.Z) {
+		if !zone.CanBuild(change.Player.Name()) {
+			return cerror.NewErrorf(BLOCKCHANGE_ZONE_PROTECTED, "Zone %s is protected", zone.Name)
+		}
+	}
+	return nil
+}
+
+func validateBlockPermission(change *BlockChange) error {
+	player := change.Player
+	if !player.CanBuild(change.Level) {
+		return cerror.NewErrorf(BLOCKCHANGE_NO_PERMISSION, "Not allowed to build in %s", change.Level.Name())
+	}
+	for _, block := range []byte{change.Previous, change.Result()} {
+		if slices.Contains(world.RESTRICTED_BLOCKS, block) && !player.HasPermission(BLOCK_PERMISSION_PREFIX+world.BLOCK_NAMES[block]) {
+			return cerror.NewErrorf(BLOCKCHANGE_RESTRICTED_BLOCK, "Not allowed to change block %d to %d", change.Previous, change.Result())
+		}
+	}
+	return nil
+}
+
+func (connection *Connection) SendBlock(x, y, z int16, block byte) error {
+	return connection.SendPacket(protocol.PacketID_SetBlockClientbound, encoding.SetBlockClientboundData{
+		X:         x,
+		Y:         y,
+		Z:         z,
+		BlockType: connection.clientBlock(block),
+	})
+}
+
+// Block already set in a level, to be sent along with others
+type BlockUpdate struct {
+	world.BlockPos
+	Block byte
+}
+
+// Batched into BulkBlockUpdates for clients supporting them, and sent one at a time otherwise
+func (connection *Connection) SendBlocks(level *world.Level, updates []BlockUpdate) error {
+	if !connection.SupportsExtension(protocol.EXT_BULK_BLOCK_UPDATE, 1) {
+		for _, update := range updates {
+			if err := connection.SendBlock(update.X, update.Y, update.Z, update.Block); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	width, _, length := level.Size()
+	for start := 0; start < len(updates); start += protocol.BULK_BLOCK_UPDATE_SIZE {
+		batch := updates[start:min(start+protocol.BULK_BLOCK_UPDATE_SIZE, len(updates))]
+		data := encoding.BulkBlockUpdateData{Count: byte(len(batch) - 1)}
+		for i, update := range batch {
+			data.Indices[i] = int32((int(update.Y)*int(length)+int(update.Z))*int(width) + int(update.X))
+			data.Blocks[i] = connection.clientBlock(update.Block)
+		}
+		if err := connection.SendPacket(protocol.PacketID_BulkBlockUpdate, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sends changes already made to the level to everyone in it
+func (server *Server) BroadcastBlocks(level *world.Level, updates []BlockUpdate) {
+	if len(updates) == 0 {
+		return
+	}
+	for _, player := range server.PlayersInLevel(level) {
+		if err := player.connection.SendBlocks(level, updates); err != nil {
+			log.Printf("Failed to send block changes to %s: %v", player.name, err)
+		}
+	}
+}
+
+// Applies the change and sends it to everyone in the level
+func (server *Server) SetBlock(level *world.Level, x, y, z int16, block byte) error {
+	if err := level.SetBlock(x, y, z, block); err != nil {
+		return err
+	}
+	for _, player := range server.PlayersInLevel(level) {
+		if err := player.connection.SendBlock(x, y, z, block); err != nil {
+			log.Printf("Failed to send block change to %s: %v", player.name, err)
+		}
+	}
+	return nil
+}
+
+// Rejected changes are reverted for the client rather than disconnecting them
+func (connection *Connection) handleSetBlock(data encoding.SetBlockServerboundData) error {
+	player := connection.Player()
+	if player == nil || player.Level() == nil {
+		return cerror.NewError(BLOCKCHANGE_NOT_IN_LEVEL, "Block change sent before joining a level")
+	}
+	level := player.Level()
+	// Clients can't click outside the level or hold unknown blocks, so these only come from broken or malicious ones
+	if !level.InBounds(data.X, data.Y, data.Z) {
+		return cerror.NewErrorf(BLOCKCHANGE_OUT_OF_BOUNDS, "Block change at %d,%d,%d is outside the level", data.X, data.Y, data.Z)
+	}
+	if !connection.knowsBlock(data.BlockType) {
+		return cerror.NewErrorf(BLOCKCHANGE_INVALID_BLOCK, "Unknown block type %d", data.BlockType)
+	}
+	if data.Mode != BLOCK_MODE_DESTROY && data.Mode != BLOCK_MODE_PLACE {
+		return cerror.NewErrorf(BLOCKCHANGE_INVALID_MODE, "Invalid block change mode %d", data.Mode)
+	}
+	previous, err := level.GetBlock(data.X, data.Y, data.Z)
+	if err != nil {
+		return err
+	}
+	change := &BlockChange{
+		Player:   player,
+		Level:    level,
+		X:        data.X,
+		Y:        data.Y,
+		Z:        data.Z,
+		Mode:     data.Mode,
+		Held:     data.BlockType,
+		Previous: previous,
+	}
+	if err := validateBlockChange(change); err != nil {
+		return connection.SendBlock(data.X, data.Y, data.Z, previous)
+	}
+	return connection.Server().SetBlock(level, data.X, data.Y, data.Z, change.Result())
+}
+
+// Fills in the previous block of a change made by a command, then validates it
+func validateCommandChange(change *BlockChange) error {
+	if !change.Level.InBounds(change.X, change.Y, change.Z) {
+		return cerror.NewErrorf(BLOCKCHANGE_OUT_OF_BOUNDS, "Block %d,%d,%d is outside the level", change.X, change.Y, change.Z)
+	}
+	previous, err := change.Level.GetBlock(change.X, change.Y, change.Z)
+	if err != nil {
+		return err
+	}
+	change.Previous = previous
+	return validateBlockChange(change)
+}
+
+// Validates a change made by a command, then applies it. Returns why it was rejected
+func (server *Server) ChangeBlock(change *BlockChange) error {
+	if err := validateCommandChange(change); err != nil {
+		return err
+	}
+	return server.SetBlock(change.Level, change.X, change.Y, change.Z, change.Result())
+}
+
+// Validates and applies each change, which must all be in the same level, then sends the accepted ones together.
+// Returns how many were accepted
+func (server *Server) ChangeBlocks(changes []*BlockChange) int {
+	var updates []BlockUpdate
+	for _, change := range changes {
+		if validateCommandChange(change) != nil {
+			continue
+		}
+		if err := change.Level.SetBlock(change.X, change.Y, change.Z, change.Result()); err != nil {
+			continue
+		}
+		updates = append(updates, BlockUpdate{BlockPos: world.BlockPos{X: change.X, Y: change.Y, Z: change.Z}, Block: change.Result()})
+	}
+	if len(changes) > 0 {
+		server.BroadcastBlocks(changes[0].Level, updates)
+	}
+	return len(updates)
+}
@@ -0,0 +1,423 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/chat"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/formats"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/particles"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/servercontext"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+func senderPlayer(sender CommandSender) (*Player, error) {
+	player, ok := sender.(*Player)
+	if !ok {
+		return nil, cerror.NewError(COMMAND_PLAYER_ONLY, "Only players can use this command")
+	}
+	return player, nil
+}
+
+// Standing on top of the block
+func blockPosition(position world.BlockPos) world.Position {
+	return world.Position{
+		X: float32(position.X) + 0.5,
+		Y: float32(position.Y) + world.PLAYER_HEIGHT,
+		Z: float32(position.Z) + 0.5,
+	}
+}
+
+// Takes a name so worlds can be checked before they're loaded
+func checkVisit(player *Player, name string) error {
+	if level := player.Level(); level != nil && strings.EqualFold(level.Name(), name) {
+		return nil
+	}
+	if !player.HasPermission(WorldNamePermission(name, WORLD_PERMISSION_VISIT)) {
+		return cerror.NewErrorf(COMMAND_NO_PERMISSION, "You don't have permission to visit %s", name)
+	}
+	return nil
+}
+
+// Moves the player to the position, switching levels first if needed
+func (player *Player) TeleportTo(level *world.Level, position world.Position) error {
+	if player.Level() != level {
+		if err := player.SwitchLevel(level); err != nil {
+			return err
+		}
+	}
+	return player.connection.Teleport(position)
+}
+
+// Largest schematic /paste accepts, so clients without BulkBlockUpdate aren't sent more changes than they can keep up with
+const MAX_PASTE_VOLUME = 1 << 20
+
+// Schematics are loaded by name from the schematics directory
+func (server *Server) loadSchematic(name string) (*formats.Schematic, *formats.Report, error) {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, nil, cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "Invalid schematic name %s", name)
+	}
+	path := filepath.Join(servercontext.SCHEMATICS_DIRECTORY, name+formats.SCHEMATIC_EXTENSION)
+	schematic, report, err := formats.LoadSchematic(path, server.context.Palette())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "Schematic %s does not exist", name)
+	}
+	return schematic, report, err
+}
+
+var BUILTIN_COMMANDS = []*Command{
+	{
+		CommandName: "help",
+		Aliases:     []string{"commands"},
+		Description: "Lists commands, or describes one",
+		Arguments:   []Argument{{Name: "command", Optional: true}},
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			if arguments.Has("command") {
+				command, ok := server.commands.Get(arguments.String("command"))
+				if !ok || !command.Allowed(sender) {
+					return cerror.NewErrorf(COMMAND_NOT_FOUND, "Unknown command %s", arguments.String("command"))
+				}
+				sender.SendMessage(chat.COLOR_YELLOW + command.Usage())
+				sender.SendMessage(command.Description)
+				if len(command.Aliases) > 0 {
+					sender.SendMessage(chat.COLOR_GRAY + "Aliases: " + strings.Join(command.Aliases, ", "))
+				}
+				return nil
+			}
+			for _, command := range server.commands.All() {
+				if command.Allowed(sender) {
+					sender.SendMessage(chat.COLOR_YELLOW + command.Usage() + chat.COLOR_WHITE + " - " + command.Description)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		CommandName: "ping",
+		Description: "Shows your latency, or another player's",
+		Arguments:   []Argument{{Name: "player", Type: ARGUMENT_PLAYER, Optional: true}},
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			player := arguments.Player("player")
+			if player == nil {
+				var err error
+				if player, err = senderPlayer(sender); err != nil {
+					return err
+				}
+			}
+			latency, estimated := player.connection.Latency()
+			note := ""
+			if estimated {
+				note = chat.COLOR_GRAY + " (estimated)"
+			}
+			return sender.SendMessage(fmt.Sprintf("%s's ping is %dms%s", player.name, latency.Milliseconds(), note))
+		},
+	},
+	{
+		CommandName: "players",
+		Aliases:     []string{"who", "list"},
+		Description: "Lists online players",
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			var names []string
+			for _, player := range server.Players() {
+				if player.connection.loggedIn.Load() {
+					names = append(names, player.Rank().Color+player.name+chat.COLOR_WHITE)
+				}
+			}
+			return sender.SendMessage(fmt.Sprintf("%d online: %s", len(names), strings.Join(names, ", ")))
+		},
+	},
+	{
+		CommandName: "tp",
+		Aliases:     []string{"teleport"},
+		Description: "Teleports you to another player",
+		Arguments:   []Argument{{Name: "player", Type: ARGUMENT_PLAYER}},
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			player, err := senderPlayer(sender)
+			if err != nil {
+				return err
+			}
+			target := arguments.Player("player")
+			level := target.Level()
+			if err := checkVisit(player, level.Name()); err != nil {
+				return err
+			}
+			return player.TeleportTo(level, target.Position())
+		},
+	},
+	{
+		CommandName: "tppos",
+		Description: "Teleports you to a block in your world",
+		Arguments:   []Argument{{Name: "position", Type: ARGUMENT_COORDINATES}},
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			player, err := senderPlayer(sender)
+			if err != nil {
+				return err
+			}
+			block := arguments.Coordinates("position")
+			position := blockPosition(block)
+			// Clients are kicked for moving that far out
+			if level := player.Level(); !inMovementBounds(level, position) {
+				return cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "%d %d %d is too far outside of %s", block.X, block.Y, block.Z, level.Name())
+			}
+			current := player.Position()
+			position.Yaw, position.Pitch = current.Yaw, current.Pitch
+			return player.connection.Teleport(position)
+		},
+	},
+	{
+		CommandName: "goto",
+		Aliases:     []string{"g"},
+		Description: "Takes you to another world",
+		Arguments:   []Argument{{Name: "world", Type: ARGUMENT_WORLD}},
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			player, err := senderPlayer(sender)
+			if err != nil {
+				return err
+			}
+			level := arguments.World("world")
+			// Visit permission was checked before the world was loaded
+			if player.Level() == level {
+				return sender.SendMessage("You are already in " + level.Name())
+			}
+			return player.SwitchLevel(level)
+		},
+	},
+	{
+		CommandName: "worlds",
+		Description: "Lists worlds, with those loaded in green",
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			names, err := server.context.Worlds.Available()
+			if err != nil {
+				return err
+			}
+			for i, name := range names {
+				color := chat.COLOR_GRAY
+				if server.context.Worlds.IsLoaded(name) {
+					color = chat.COLOR_GREEN
+				}
+				names[i] = color + name + chat.COLOR_WHITE
+			}
+			return sender.SendMessage(fmt.Sprintf("%d worlds: %s", len(names), strings.Join(names, ", ")))
+		},
+	},
+	{
+		CommandName: "setspawn",
+		Description: "Makes where you stand the spawn of your world",
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			player, err := senderPlayer(sender)
+			if err != nil {
+				return err
+			}
+			level := player.Level()
+			position := player.Position()
+			level.SetSpawn(position)
+			if err := server.context.Worlds.Save(level); err != nil {
+				return err
+			}
+			return sender.SendMessage(fmt.Sprintf("Spawn of %s set to %.1f,%.1f,%.1f", level.Name(), position.X, position.Y, position.Z))
+		},
+	},
+	{
+		CommandName: "place",
+		Description: "Places a block in your world",
+		Arguments: []Argument{
+			{Name: "position", Type: ARGUMENT_COORDINATES},
+			{Name: "block", Type: ARGUMENT_BLOCK},
+		},
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			player, err := senderPlayer(sender)
+			if err != nil {
+				return err
+			}
+			position := arguments.Coordinates("position")
+			return server.ChangeBlock(&BlockChange{
+				Player: player,
+				Level:  player.Level(),
+				X:      position.X,
+				Y:      position.Y,
+				Z:      position.Z,
+				Mode:   BLOCK_MODE_PLACE,
+				Held:   arguments.Block("block"),
+				Remote: true,
+			})
+		},
+	},
+	{
+		CommandName: "paste",
+		Description: "Pastes a schematic at your feet, rotated clockwise by degrees and mirrored along x, z or xz",
+		Arguments: []Argument{
+			{Name: "schematic"},
+			{Name: "rotation", Type: ARGUMENT_INTEGER, Optional: true},
+			{Name: "mirror", Optional: true},
+		},
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			player, err := senderPlayer(sender)
+			if err != nil {
+				return err
+			}
+			level := player.Level()
+			if !player.CanBuild(level) {
+				return cerror.NewErrorf(COMMAND_NO_PERMISSION, "You don't have permission to build in %s", level.Name())
+			}
+			options := formats.PasteOptions{SkipAir: true}
+			if arguments.Has("rotation") {
+				rotation := arguments.Int("rotation")
+				if rotation%90 != 0 {
+					return cerror.NewError(COMMAND_INVALID_ARGUMENT, "Rotation must be a multiple of 90 degrees")
+				}
+				options.Rotation = rotation / 90
+			}
+			if arguments.Has("mirror") {
+				mirror := strings.ToLower(arguments.String("mirror"))
+				if strings.Trim(mirror, "xz") != "" {
+					return cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "Can't mirror along %s, use x, z or xz", mirror)
+				}
+				options.MirrorX = strings.Contains(mirror, "x")
+				options.MirrorZ = strings.Contains(mirror, "z")
+			}
+			schematic, report, err := server.loadSchematic(arguments.String("schematic"))
+			if err != nil {
+				return err
+			}
+			width, height, length := schematic.Size()
+			if volume := int(width) * int(height) * int(length); volume > MAX_PASTE_VOLUME {
+				return cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "Schematic has %d blocks, more than the limit of %d", volume, MAX_PASTE_VOLUME)
+			}
+			for _, warning := range report.Warnings() {
+				sender.SendMessage(chat.COLOR_YELLOW + warning)
+			}
+			position := player.Position()
+			origin := world.BlockPos{
+				X: blockCoordinate(position.X),
+				Y: blockCoordinate(position.Y - world.PLAYER_HEIGHT),
+				Z: blockCoordinate(position.Z),
+			}
+			// Every block goes through the usual validators, but blocks refused by them don't stop the paste
+			var changes []*BlockChange
+			schematic.Paste(level, origin, options, func(x, y, z int16, block byte) error {
+				changes = append(changes, &BlockChange{Player: player, Level: level, X: x, Y: y, Z: z, Mode: BLOCK_MODE_PLACE, Held: block, Remote: true})
+				return nil
+			})
+			pasted := server.ChangeBlocks(changes)
+			return sender.SendMessage(fmt.Sprintf("Pasted %d blocks", pasted))
+		},
+	},
+	{
+		CommandName: "say",
+		Aliases:     []string{"broadcast"},
+		Description: "Sends a message to everyone",
+		Arguments:   []Argument{{Name: "message", Type: ARGUMENT_TEXT}},
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			server.Broadcast(chat.Sanitize(arguments.String("message"), server.context.TextColors()))
+			return nil
+		},
+	},
+	{
+		CommandName: "kick",
+		Description: "Disconnects a player",
+		Arguments: []Argument{
+			{Name: "player", Type: ARGUMENT_PLAYER},
+			{Name: "reason", Type: ARGUMENT_TEXT, Optional: true},
+		},
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			player := arguments.Player("player")
+			reason := "Kicked by " + sender.Name()
+			if arguments.Has("reason") {
+				reason = arguments.String("reason")
+			}
+			player.connection.Kick(reason)
+			server.Broadcast(fmt.Sprintf("%s%s was kicked (%s)", chat.COLOR_YELLOW, player.name, reason))
+			return nil
+		},
+	},
+	{
+		CommandName: "rank",
+		Aliases:     []string{"setrank"},
+		Description: "Changes a player's rank",
+		Arguments: []Argument{
+			{Name: "player", Type: ARGUMENT_PLAYER},
+			{Name: "rank"},
+		},
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			player := arguments.Player("player")
+			all := server.context.Ranks()
+			rank, ok := all.Get(arguments.String("rank"))
+			if !ok {
+				var names []string
+				for _, rank := range all.All() {
+					names = append(names, rank.Name)
+				}
+				return cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "Unknown rank %s. Ranks: %s", arguments.String("rank"), strings.Join(names, ", "))
+			}
+			// Players can only manage those below them, and only up to their own rank
+			if promoter, ok := sender.(*Player); ok {
+				own := all.Index(promoter.Rank().Name)
+				if all.Index(rank.Name) > own || all.Index(player.Rank().Name) >= own {
+					return cerror.NewErrorf(COMMAND_NO_PERMISSION, "You can't change %s's rank to %s", player.name, rank.Name)
+				}
+			}
+			if err := player.SetRank(rank); err != nil {
+				return err
+			}
+			player.SendMessage(chat.COLOR_YELLOW + "Your rank is now " + rank.Color + rank.Name)
+			return sender.SendMessage(fmt.Sprintf("%s is now %s", player.name, rank.Name))
+		},
+	},
+	{
+		CommandName: "save",
+		Description: "Saves the world and settings to disk",
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			if err := server.context.SaveFiles(); err != nil {
+				return err
+			}
+			return sender.SendMessage("Saved")
+		},
+	},
+	{
+		CommandName: "reload",
+		Description: "Reloads settings from disk",
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			previous := server.context.Particles()
+			server.context.LoadFiles()
+			// Clients keep effects they've been given, so only new and changed ones are defined
+			effects := particles.Changed(previous, server.context.Particles())
+			server.forEachConnection("resend customizations", func(connection *Connection) error {
+				if err := connection.sendCustomizations(effects); err != nil {
+					return err
+				}
+				// Rank definitions may have changed
+				return connection.Player().sendUserType()
+			})
+			return sender.SendMessage("Reloaded")
+		},
+	},
+	{
+		CommandName: "stop",
+		Aliases:     []string{"shutdown"},
+		Description: "Saves and shuts down the server",
+		Arguments:   []Argument{{Name: "reason", Type: ARGUMENT_TEXT, Optional: true}},
+		Run: func(server *Server, sender CommandSender, arguments *Arguments) error {
+			// Saving is left to shutdown, once everyone has been kicked and nothing else can change
+			reason := "Server stopped"
+			if arguments.Has("reason") {
+				reason = arguments.String("reason")
+			}
+			server.Stop(reason)
+			return nil
+		},
+	},
+}
+
+func newBuiltinCommandRegistry() *CommandRegistry {
+	commands := NewCommandRegistry()
+	for _, command := range BUILTIN_COMMANDS {
+		if err := commands.Register(command); err != nil {
+			panic(err)
+		}
+	}
+	return commands
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"log"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/chat"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/servercontext"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+// Sanitizes and wraps the message, sending it over as many lines as needed
+func (connection *Connection) SendMessage(message string) error {
+	var custom *chat.TextColors
+	if connection.SupportsExtension(protocol.EXT_TEXT_COLORS, 1) {
+		custom = connection.Server().Context().TextColors()
+	}
+	for _, line := range chat.WordWrap(chat.Sanitize(message, custom)) {
+		if err := connection.SendPacket(protocol.PacketID_Message, encoding.MessageData{Message: line}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sendMessageTo(players []*Player, message string) {
+	for _, player := range players {
+		if err := player.connection.SendMessage(message); err != nil {
+			log.Printf("Failed to send message to %s: %v", player.name, err)
+		}
+	}
+}
+
+// Sends the message to every logged in player
+func (server *Server) Broadcast(message string) {
+	log.Print(chat.StripColors(message))
+	var players []*Player
+	for _, player := range server.Players() {
+		if player.connection.loggedIn.Load() {
+			players = append(players, player)
+		}
+	}
+	sendMessageTo(players, message)
+}
+
+func (server *Server) BroadcastToLevel(level *world.Level, message string) {
+	log.Printf("[%s] %s", level.Name(), chat.StripColors(message))
+	sendMessageTo(server.PlayersInLevel(level), message)
+}
+
+// Sends a message from the player to everyone who can hear them
+func (player *Player) Chat(message string) {
+	server := player.connection.Server()
+	message = chat.Sanitize(chat.StripUnprintable(message), server.Context().TextColors())
+	if message == "" {
+		return
+	}
+	rank := player.Rank()
+	formatted := chat.FormatMessage(rank.Color, rank.Prefix, player.name, message)
+	if server.Context().Config().ChatScope == servercontext.CHAT_SCOPE_LEVEL && player.Level() != nil {
+		server.BroadcastToLevel(player.Level(), formatted)
+		return
+	}
+	server.Broadcast(formatted)
+}
@@ -0,0 +1,372 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/chat"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/registry"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+const COMMAND_PREFIX = "/"
+
+// Followed by the command's name to form the permission node needed to use it
+const COMMAND_PERMISSION_PREFIX = "command."
+
+const (
+	COMMAND_NOT_FOUND = iota
+	COMMAND_EXISTS
+	COMMAND_NO_PERMISSION
+	COMMAND_MISSING_ARGUMENT
+	COMMAND_INVALID_ARGUMENT
+	COMMAND_TOO_MANY_ARGUMENTS
+	COMMAND_PLAYER_ONLY
+)
+
+const (
+	ARGUMENT_STRING = iota
+	// Consumes the rest of the line
+	ARGUMENT_TEXT
+	ARGUMENT_INTEGER
+	ARGUMENT_PLAYER
+	// Three values, each of which may be relative to the sender with ~
+	ARGUMENT_COORDINATES
+	ARGUMENT_BLOCK
+	ARGUMENT_WORLD
+	ARGUMENT_DURATION
+)
+
+type Argument struct {
+	Name string
+	Type int
+	// Optional arguments must come after all required ones
+	Optional bool
+}
+
+func (argument Argument) usage() string {
+	name := argument.Name
+	if argument.Type == ARGUMENT_COORDINATES {
+		name = "x y z"
+	}
+	if argument.Optional {
+		return "[" + name + "]"
+	}
+	return "<" + name + ">"
+}
+
+// Anything commands can be run by
+type CommandSender interface {
+	Name() string
+	HasPermission(node string) bool
+	SendMessage(message string) error
+}
+
+type ConsoleSender struct{}
+
+func (ConsoleSender) Name() string {
+	return "Console"
+}
+
+// The console can do anything
+func (ConsoleSender) HasPermission(node string) bool {
+	return true
+}
+
+func (ConsoleSender) SendMessage(message string) error {
+	log.Print(chat.StripColors(message))
+	return nil
+}
+
+type Command struct {
+	CommandName string
+	Aliases     []string
+	Description string
+	Arguments   []Argument
+	Run         func(server *Server, sender CommandSender, arguments *Arguments) error
+}
+
+func (command *Command) Name() string {
+	return command.CommandName
+}
+
+func (command *Command) Usage() string {
+	parts := []string{COMMAND_PREFIX + command.CommandName}
+	for _, argument := range command.Arguments {
+		parts = append(parts, argument.usage())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (command *Command) Permission() string {
+	return COMMAND_PERMISSION_PREFIX + command.CommandName
+}
+
+func (command *Command) Allowed(sender CommandSender) bool {
+	return sender.HasPermission(command.Permission())
+}
+
+// Parsed arguments, looked up by name
+type Arguments struct {
+	values map[string]any
+}
+
+func (arguments *Arguments) Has(name string) bool {
+	_, ok := arguments.values[name]
+	return ok
+}
+
+func argument[T any](arguments *Arguments, name string) T {
+	value, _ := arguments.values[name].(T)
+	return value
+}
+
+func (arguments *Arguments) String(name string) string {
+	return argument[string](arguments, name)
+}
+
+func (arguments *Arguments) Int(name string) int {
+	return argument[int](arguments, name)
+}
+
+func (arguments *Arguments) Player(name string) *Player {
+	return argument[*Player](arguments, name)
+}
+
+func (arguments *Arguments) Coordinates(name string) world.BlockPos {
+	return argument[world.BlockPos](arguments, name)
+}
+
+func (arguments *Arguments) Block(name string) byte {
+	return argument[byte](arguments, name)
+}
+
+func (arguments *Arguments) World(name string) *world.Level {
+	return argument[*world.Level](arguments, name)
+}
+
+func (arguments *Arguments) Duration(name string) time.Duration {
+	return argument[time.Duration](arguments, name)
+}
+
+// Commands indexed by both name and alias
+type CommandRegistry struct {
+	mutex    sync.RWMutex
+	commands *registry.NamedRegistry[string, *Command]
+	aliases  map[string]*Command
+}
+
+func (commands *CommandRegistry) Register(command *Command) error {
+	commands.mutex.Lock()
+	defer commands.mutex.Unlock()
+	names := append([]string{command.CommandName}, command.Aliases...)
+	for _, name := range names {
+		if _, ok := commands.lookup(name); ok {
+			return cerror.NewErrorf(COMMAND_EXISTS, "Command %s already exists", name)
+		}
+	}
+	if err := commands.commands.Register(command); err != nil {
+		return err
+	}
+	for _, alias := range command.Aliases {
+		commands.aliases[strings.ToLower(alias)] = command
+	}
+	return nil
+}
+
+func (commands *CommandRegistry) Unregister(name string) error {
+	commands.mutex.Lock()
+	defer commands.mutex.Unlock()
+	command, ok := commands.commands.Get(strings.ToLower(name))
+	if !ok {
+		return cerror.NewErrorf(COMMAND_NOT_FOUND, "Command %s does not exist", name)
+	}
+	for _, alias := range command.Aliases {
+		delete(commands.aliases, strings.ToLower(alias))
+	}
+	return commands.commands.Unregister(command.CommandName)
+}
+
+func (commands *CommandRegistry) lookup(name string) (*Command, bool) {
+	name = strings.ToLower(name)
+	if command, ok := commands.commands.Get(name); ok {
+		return command, true
+	}
+	command, ok := commands.aliases[name]
+	return command, ok
+}
+
+// Accepts aliases as well as names
+func (commands *CommandRegistry) Get(name string) (*Command, bool) {
+	commands.mutex.RLock()
+	defer commands.mutex.RUnlock()
+	return commands.lookup(name)
+}
+
+// Sorted by name
+func (commands *CommandRegistry) All() []*Command {
+	commands.mutex.RLock()
+	defer commands.mutex.RUnlock()
+	all := commands.commands.Entries()
+	slices.SortFunc(all, func(a, b *Command) int {
+		return strings.Compare(a.CommandName, b.CommandName)
+	})
+	return all
+}
+
+// Command names are expected to be lowercase
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		commands: registry.NewNamedRegistry[string, *Command](),
+		aliases:  make(map[string]*Command),
+	}
+}
+
+func parseCoordinate(value string, relative int16) (int16, error) {
+	base := 0
+	if strings.HasPrefix(value, "~") {
+		base = int(relative)
+		value = strings.TrimPrefix(value, "~")
+		if value == "" {
+			return int16(base), nil
+		}
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || base+offset < math.MinInt16 || base+offset > math.MaxInt16 {
+		return 0, cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "%s is not a valid coordinate", value)
+	}
+	return int16(base + offset), nil
+}
+
+func (server *Server) parseArgument(sender CommandSender, argument Argument, values []string) (any, int, error) {
+	value := values[0]
+	switch argument.Type {
+	case ARGUMENT_TEXT:
+		return strings.Join(values, " "), len(values), nil
+	case ARGUMENT_INTEGER:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, 0, cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "%s is not a number", value)
+		}
+		return number, 1, nil
+	case ARGUMENT_PLAYER:
+		// Players still joining have no level yet, so aren't offered to commands
+		player, ok := server.Player(value)
+		if !ok || !player.connection.loggedIn.Load() {
+			return nil, 0, cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "Player %s is not online", value)
+		}
+		return player, 1, nil
+	case ARGUMENT_COORDINATES:
+		if len(values) < 3 {
+			return nil, 0, cerror.NewErrorf(COMMAND_MISSING_ARGUMENT, "Missing coordinates for %s", argument.Name)
+		}
+		var origin world.Position
+		if player, ok := sender.(*Player); ok {
+			origin = player.Position()
+			origin.Y -= world.PLAYER_HEIGHT
+		}
+		relative := [3]int16{blockCoordinate(origin.X), blockCoordinate(origin.Y), blockCoordinate(origin.Z)}
+		var coordinates [3]int16
+		for i := range coordinates {
+			coordinate, err := parseCoordinate(values[i], relative[i])
+			if err != nil {
+				return nil, 0, err
+			}
+			coordinates[i] = coordinate
+		}
+		return world.BlockPos{X: coordinates[0], Y: coordinates[1], Z: coordinates[2]}, 3, nil
+	case ARGUMENT_BLOCK:
+		block, ok := world.BlockByName(value)
+		if !ok {
+			return nil, 0, cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "Unknown block %s", value)
+		}
+		return block, 1, nil
+	case ARGUMENT_WORLD:
+		// Checked first so players can't load worlds they aren't allowed in
+		if player, ok := sender.(*Player); ok {
+			if err := checkVisit(player, value); err != nil {
+				return nil, 0, err
+			}
+		}
+		level, ok := server.Level(value)
+		if !ok {
+			return nil, 0, cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "World %s does not exist", value)
+		}
+		return level, 1, nil
+	case ARGUMENT_DURATION:
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration < 0 {
+			return nil, 0, cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "%s is not a valid duration, e.g. 1h30m", value)
+		}
+		return duration, 1, nil
+	default:
+		return value, 1, nil
+	}
+}
+
+func (server *Server) parseArguments(sender CommandSender, command *Command, values []string) (*Arguments, error) {
+	arguments := &Arguments{values: make(map[string]any)}
+	for _, argument := range command.Arguments {
+		if len(values) == 0 {
+			if argument.Optional {
+				break
+			}
+			return nil, cerror.NewErrorf(COMMAND_MISSING_ARGUMENT, "Missing %s", argument.Name)
+		}
+		value, consumed, err := server.parseArgument(sender, argument, values)
+		if err != nil {
+			return nil, err
+		}
+		arguments.values[argument.Name] = value
+		values = values[consumed:]
+	}
+	if len(values) > 0 {
+		return nil, cerror.NewError(COMMAND_TOO_MANY_ARGUMENTS, "Too many arguments")
+	}
+	return arguments, nil
+}
+
+func (server *Server) runCommand(sender CommandSender, name string, values []string) error {
+	command, ok := server.commands.Get(name)
+	if !ok {
+		return cerror.NewErrorf(COMMAND_NOT_FOUND, "Unknown command %s. See %shelp", name, COMMAND_PREFIX)
+	}
+	if !command.Allowed(sender) {
+		return cerror.NewErrorf(COMMAND_NO_PERMISSION, "You don't have permission to use %s%s", COMMAND_PREFIX, command.CommandName)
+	}
+	arguments, err := server.parseArguments(sender, command, values)
+	// Usage won't help with arguments the sender isn't allowed to use
+	var coded cerror.CodedError
+	if errors.As(err, &coded) && coded.Code == COMMAND_NO_PERMISSION {
+		return err
+	}
+	if err != nil {
+		return cerror.NewErrorf(COMMAND_INVALID_ARGUMENT, "%v. Usage: %s", err, command.Usage())
+	}
+	return command.Run(server, sender, arguments)
+}
+
+// Runs a line of input, with or without the leading slash. Failures are reported to the sender
+func (server *Server) ExecuteCommand(sender CommandSender, line string) {
+	fields := strings.Fields(strings.TrimPrefix(line, COMMAND_PREFIX))
+	if len(fields) == 0 {
+		return
+	}
+	if _, console := sender.(ConsoleSender); !console {
+		log.Printf("%s used %s%s", sender.Name(), COMMAND_PREFIX, strings.Join(fields, " "))
+	}
+	if err := server.runCommand(sender, fields[0], fields[1:]); err != nil {
+		sender.SendMessage(chat.COLOR_RED + err.Error())
+	}
+}
+
+func (server *Server) Commands() *CommandRegistry {
+	return server.commands
+}
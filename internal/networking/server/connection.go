@@ -7,15 +7,27 @@ import (
 	"io"
 	"log"
 	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
 	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/pluginmessage"
 	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
 	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol_impls"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
 )
 
 const BUFFER_SIZE = 8096
 
+// How long a client can take to accept written data before it's disconnected
+const WRITE_TIMEOUT = 10 * time.Second
+
+// Unsent data a client can fall behind by before it's disconnected, enough for a large level
+const MAX_PENDING_WRITE = 32 << 20
+
 const (
 	CON_READ_ERROR = iota
 	CON_PROTOCOL_NOT_FOUND
@@ -23,17 +35,48 @@ const (
 	CON_INVALID_PACKET_ID
 )
 
+// Errors only ever reach the client as this, so internal details aren't leaked
+const KICK_INVALID_PACKET = "Sent an invalid packet"
+
 var PROTOCOLS = map[byte]protocol.Protocol{
 	0x07: &protocol_impls.Protocol7{},
 }
 
 type Connection struct {
-	conn     net.Conn
-	closed   bool
-	buffer   []byte
-	protocol protocol.Protocol
-	writer   *encoding.PacketWriter
-	id       uint
+	conn        net.Conn
+	closed      atomic.Bool
+	buffer      []byte
+	protocol    protocol.Protocol
+	writer      *encoding.PacketWriter
+	writeMutex  sync.Mutex
+	id          uint
+	server      *Server
+	pingTracker pingTracker
+
+	loggedIn          atomic.Bool
+	negotiating       bool
+	pendingExtensions int16
+	extensions        map[string]int32
+	// Login waits for the client's CustomBlocks support level once its extensions are known
+	awaitingBlockSupport bool
+	// Set during login, before the connection is shared with other goroutines
+	customBlocks   bool
+	identification encoding.IdentificationData
+	spawnpoint     *world.Position
+	player         *Player
+
+	pluginReassemblers map[byte]*pluginmessage.Reassembler
+
+	// Guards what the client has been told about its level, which other goroutines change through level settings and reloads
+	stateMutex    sync.Mutex
+	clickDistance float32
+	// Blocks the client has been told to hide, so switching levels can show them again
+	hiddenBlocks []byte
+
+	// Packets are encoded, queued in pending and sent by writeLoop, so a stalled client never blocks the sender
+	encoded     bytes.Buffer
+	pending     []byte
+	writeSignal chan struct{}
 }
 
 func (connection *Connection) Id() uint {
@@ -44,16 +87,28 @@ func (connection *Connection) Protocol() protocol.Protocol {
 	return connection.protocol
 }
 
+func (connection *Connection) Server() *Server {
+	return connection.server
+}
+
+// Nil until login has finished
+func (connection *Connection) Player() *Player {
+	return connection.player
+}
+
+// Returned by readData once the connection has been closed, so no stale data is handled
+var errConnectionClosed = errors.New("Connection closed")
+
 func readData(connection *Connection, buffer []byte, ctx context.Context) error {
 	_, err := io.ReadFull(connection.conn, buffer)
 	if err != nil {
 		select {
 		case <-ctx.Done():
-			return nil
+			return errConnectionClosed
 		default:
-			if errors.Is(err, io.EOF) || err.Error() == "unexpected EOF" { // Connection closed
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) { // Connection closed
 				connection.Close()
-				return nil
+				return errConnectionClosed
 			}
 			return cerror.NewErrorf(CON_READ_ERROR, "Error reading data: %v", err)
 		}
@@ -61,14 +116,21 @@ func readData(connection *Connection, buffer []byte, ctx context.Context) error
 	return nil
 }
 
-// TODO: More detailed errors
 func (connection *Connection) Start(ctx context.Context) error {
 	go func() {
 		<-ctx.Done()
 		connection.Close()
 	}()
+	if err := connection.readLoop(ctx); err != nil && !errors.Is(err, errConnectionClosed) {
+		return err
+	}
+	return nil
+}
+
+// TODO: More detailed errors
+func (connection *Connection) readLoop(ctx context.Context) error {
 	for {
-		if connection.closed {
+		if connection.closed.Load() {
 			return nil
 		}
 		buffer := connection.buffer
@@ -97,53 +159,140 @@ func (connection *Connection) Start(ctx context.Context) error {
 		}
 
 		length := builder.GetSize()
-		var packetSlice []byte
+		packetSlice := buffer[:length]
 		if setProtocol {
-			packetSlice = buffer[1:length]
-		} else {
-			packetSlice = buffer[:length]
-		}
-		if err := readData(connection, packetSlice, ctx); err != nil {
+			// The protocol version has already been read into buffer[1]
+			packetSlice = buffer[1 : length+1]
+			if err := readData(connection, packetSlice[1:], ctx); err != nil {
+				return err
+			}
+		} else if err := readData(connection, packetSlice, ctx); err != nil {
 			return err
 		}
 
-		reader := encoding.NewPacketReader(bytes.NewReader(buffer[:length]))
+		reader := encoding.NewPacketReader(bytes.NewReader(packetSlice))
 		packet, err := builder.BuildFromReader(reader)
 		if err != nil {
 			return err
 		}
 
 		if err := HandlePacket(connection, packet); err != nil {
+			// Rejected packets get a reason rather than a silent disconnect, unless the handler already gave one
+			var coded cerror.CodedError
+			if errors.As(err, &coded) {
+				connection.Kick(KICK_INVALID_PACKET)
+			}
 			return err
 		}
 	}
 }
 
+// Anything already written is still sent, within the write timeout, before the socket closes
 func (connection *Connection) Close() {
-	if connection.closed {
+	if connection.closed.Swap(true) {
 		return
 	}
-	connection.closed = true
+	connection.signalWrite()
+}
 
-	if err := connection.conn.Close(); err != nil {
-		log.Printf("Warning: Error in closing connection: %v\n", err) // TODO: Log better
+func (connection *Connection) signalWrite() {
+	select {
+	case connection.writeSignal <- struct{}{}:
+	default:
 	}
 }
 
 func (connection *Connection) Write(packet protocol.Packet) error {
+	connection.writeMutex.Lock()
+	defer connection.writeMutex.Unlock()
+	if connection.closed.Load() {
+		return errConnectionClosed
+	}
+	connection.encoded.Reset()
 	if err := packet.EncodeToWriter(connection.writer); err != nil {
 		return err
 	}
-
+	if len(connection.pending)+connection.encoded.Len() > MAX_PENDING_WRITE {
+		log.Printf("Connection %d fell too far behind and was closed", connection.Id())
+		connection.pending = nil
+		connection.Close()
+		return errConnectionClosed
+	}
+	connection.pending = append(connection.pending, connection.encoded.Bytes()...)
+	connection.signalWrite()
 	return nil
 }
 
-func NewConnection(conn net.Conn) *Connection {
+// Sends pending data until the connection is closed, then closes the socket
+func (connection *Connection) writeLoop() {
+	defer func() {
+		if err := connection.conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("Warning: Error in closing connection: %v\n", err) // TODO: Log better
+		}
+	}()
+	var spare []byte
+	for range connection.writeSignal {
+		connection.writeMutex.Lock()
+		data := connection.pending
+		connection.pending = spare[:0]
+		closed := connection.closed.Load()
+		connection.writeMutex.Unlock()
+		if len(data) > 0 {
+			connection.conn.SetWriteDeadline(time.Now().Add(WRITE_TIMEOUT))
+			if _, err := connection.conn.Write(data); err != nil {
+				if !connection.closed.Swap(true) {
+					log.Printf("Failed to write to connection %d: %v", connection.Id(), err)
+				}
+				return
+			}
+		}
+		spare = data
+		// Writes are refused once closed, so nothing can be left behind
+		if closed {
+			return
+		}
+	}
+}
+
+// Sends the reason to the client before closing the connection
+func (connection *Connection) Kick(reason string) {
+	if connection.closed.Load() {
+		return
+	}
+	if connection.protocol == nil {
+		connection.Close()
+		return
+	}
+	if err := connection.SendPacket(protocol.PacketID_DisconnectPlayer, encoding.DisconnectPlayerData{DisconnectReason: reason}); err != nil {
+		log.Printf("Failed to send disconnect to connection %d: %v", connection.Id(), err)
+	}
+	connection.Close()
+}
+
+func (connection *Connection) SendPacket(id protocol.PacketID, data any) error {
+	builder, err := connection.Protocol().CreatePacketBuilder(id)
+	if err != nil {
+		return err
+	}
+	packet, err := builder.Build(data)
+	if err != nil {
+		return err
+	}
+	return connection.Write(packet)
+}
+
+func NewConnection(conn net.Conn, server *Server) *Connection {
 	connection := &Connection{
-		conn:   conn,
-		closed: false,
-		buffer: make([]byte, BUFFER_SIZE),
-		writer: encoding.NewPacketWriter(conn),
+		conn:        conn,
+		buffer:      make([]byte, BUFFER_SIZE),
+		server:      server,
+		extensions:  make(map[string]int32),
+		writeSignal: make(chan struct{}, 1),
+
+		pluginReassemblers: make(map[byte]*pluginmessage.Reassembler),
+		clickDistance:      DEFAULT_CLICK_DISTANCE,
 	}
+	connection.writer = encoding.NewPacketWriter(&connection.encoded)
+	go connection.writeLoop()
 	return connection
 }
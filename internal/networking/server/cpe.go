@@ -0,0 +1,400 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/chat"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/hotkeys"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/particles"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+// Reach of vanilla clients, in blocks
+const DEFAULT_CLICK_DISTANCE = 5
+
+// Velocities and particle properties are sent to the client multiplied by this
+const VELOCITY_SCALE = 10000
+
+// Fixed point scale of most coordinates
+const COORDINATE_SCALE = 32
+
+var EXTENSIONS = []protocol.Extension{
+	{Name: protocol.EXT_CLICK_DISTANCE, Version: 1},
+	{Name: protocol.EXT_CUSTOM_BLOCKS, Version: 1},
+	{Name: protocol.EXT_SET_SPAWNPOINT, Version: 1},
+	{Name: protocol.EXT_VELOCITY_CONTROL, Version: 1},
+	{Name: protocol.EXT_CUSTOM_PARTICLES, Version: 1},
+	{Name: protocol.EXT_TEXT_HOT_KEY, Version: 1},
+	{Name: protocol.EXT_TWO_WAY_PING, Version: 1},
+	{Name: protocol.EXT_PLUGIN_MESSAGES, Version: 1},
+	{Name: protocol.EXT_TEXT_COLORS, Version: 1},
+	{Name: protocol.EXT_LIGHTING_MODE, Version: 1},
+	{Name: protocol.EXT_INVENTORY_ORDER, Version: 1},
+	{Name: protocol.EXT_TOGGLE_BLOCK_LIST, Version: 1},
+	{Name: protocol.EXT_BULK_BLOCK_UPDATE, Version: 1},
+	{Name: protocol.EXT_ENV_COLORS, Version: 1},
+	{Name: protocol.EXT_ENV_MAP_APPEARANCE, Version: 1},
+}
+
+func (connection *Connection) SupportsExtension(name string, version int32) bool {
+	supported, ok := connection.extensions[name]
+	return ok && supported >= version
+}
+
+// Block as the client knows it, which is its fallback for clients without CustomBlocks
+func (connection *Connection) clientBlock(block byte) byte {
+	if connection.customBlocks {
+		return block
+	}
+	return world.Fallback(block)
+}
+
+// Whether the client can hold and place the block
+func (connection *Connection) knowsBlock(block byte) bool {
+	if connection.customBlocks {
+		return world.ValidBlock(block)
+	}
+	return block < world.CLASSIC_BLOCK_COUNT
+}
+
+func (connection *Connection) ClickDistance() float32 {
+	connection.stateMutex.Lock()
+	defer connection.stateMutex.Unlock()
+	return connection.clickDistance
+}
+
+// Clients without ClickDistance keep their default reach, but the distance is still used to validate their actions
+func (connection *Connection) SetClickDistance(distance float32) error {
+	connection.stateMutex.Lock()
+	defer connection.stateMutex.Unlock()
+	return connection.setClickDistance(distance)
+}
+
+// The caller must hold stateMutex
+func (connection *Connection) setClickDistance(distance float32) error {
+	connection.clickDistance = distance
+	if !connection.SupportsExtension(protocol.EXT_CLICK_DISTANCE, 1) {
+		return nil
+	}
+	return connection.SendPacket(protocol.PacketID_SetClickDistance, encoding.SetClickDistanceData{Distance: distance})
+}
+
+// Nil when the level spawn should be used
+func (connection *Connection) Spawnpoint() *world.Position {
+	return connection.spawnpoint
+}
+
+// Clients without SetSpawnpoint only have it applied when respawned by the server
+func (connection *Connection) SetSpawnpoint(position world.Position) error {
+	connection.spawnpoint = &position
+	if !connection.SupportsExtension(protocol.EXT_SET_SPAWNPOINT, 1) {
+		return nil
+	}
+	return connection.SendPacket(protocol.PacketID_SetSpawnpoint, encoding.SetSpawnpointData{
+		X:     position.X,
+		Y:     position.Y,
+		Z:     position.Z,
+		Yaw:   position.Yaw,
+		Pitch: position.Pitch,
+	})
+}
+
+func (connection *Connection) Teleport(position world.Position) error {
+	if connection.player != nil {
+		connection.player.setPosition(position)
+	}
+	return connection.SendPacket(protocol.PacketID_SetPositionAndOrientation, encoding.SetPositionAndOrientationData{
+		PlayerID: -1,
+		X:        position.X,
+		Y:        position.Y,
+		Z:        position.Z,
+		Yaw:      position.Yaw,
+		Pitch:    position.Pitch,
+	})
+}
+
+// Moves the player to their checkpoint, or the level spawn without one
+func (connection *Connection) Respawn() error {
+	if connection.spawnpoint != nil {
+		return connection.Teleport(*connection.spawnpoint)
+	}
+	if connection.player == nil || connection.player.Level() == nil {
+		return nil
+	}
+	return connection.Teleport(connection.player.Level().Spawn())
+}
+
+func (connection *Connection) Launch(pad world.LaunchPad) error {
+	if !connection.SupportsExtension(protocol.EXT_VELOCITY_CONTROL, 1) {
+		if pad.Fallback == nil {
+			return nil
+		}
+		return connection.Teleport(*pad.Fallback)
+	}
+	// Launch pads may send the player further out than they could walk
+	if connection.player != nil {
+		connection.player.extendBoundsGrace()
+	}
+	return connection.SendPacket(protocol.PacketID_VelocityControl, encoding.VelocityControlData{
+		X:     int32(pad.X * VELOCITY_SCALE),
+		Y:     int32(pad.Y * VELOCITY_SCALE),
+		Z:     int32(pad.Z * VELOCITY_SCALE),
+		XMode: pad.XMode,
+		YMode: pad.YMode,
+		ZMode: pad.ZMode,
+	})
+}
+
+func (connection *Connection) ApplyBehavior(behavior world.Behavior) error {
+	if behavior.ClickDistance != nil && *behavior.ClickDistance != connection.ClickDistance() {
+		if err := connection.SetClickDistance(*behavior.ClickDistance); err != nil {
+			return err
+		}
+	}
+	if behavior.Checkpoint != nil && (connection.spawnpoint == nil || *connection.spawnpoint != *behavior.Checkpoint) {
+		if err := connection.SetSpawnpoint(*behavior.Checkpoint); err != nil {
+			return err
+		}
+	}
+	if behavior.LaunchPad != nil {
+		if err := connection.Launch(*behavior.LaunchPad); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (connection *Connection) DefineEffect(effect *particles.Effect) error {
+	if !connection.SupportsExtension(protocol.EXT_CUSTOM_PARTICLES, 1) {
+		return nil
+	}
+	var fullBright byte
+	if effect.FullBright {
+		fullBright = 1
+	}
+	return connection.SendPacket(protocol.PacketID_DefineEffect, encoding.DefineEffectData{
+		EffectID:          effect.ID,
+		U1:                effect.Texture.U1,
+		V1:                effect.Texture.V1,
+		U2:                effect.Texture.U2,
+		V2:                effect.Texture.V2,
+		RedTint:           effect.Tint.Red,
+		GreenTint:         effect.Tint.Green,
+		BlueTint:          effect.Tint.Blue,
+		FrameCount:        effect.FrameCount,
+		ParticleCount:     effect.Count,
+		ParticleSize:      byte(effect.Size * COORDINATE_SCALE),
+		SizeVariation:     int32(effect.SizeVariation * VELOCITY_SCALE),
+		Spread:            uint16(effect.Spread * COORDINATE_SCALE),
+		Speed:             int32(effect.Speed * VELOCITY_SCALE),
+		Gravity:           int32(effect.Gravity * VELOCITY_SCALE),
+		BaseLifetime:      int32(effect.Lifetime * VELOCITY_SCALE),
+		LifetimeVariation: int32(effect.LifetimeVariation * VELOCITY_SCALE),
+		CollideFlags:      effect.CollideFlags,
+		FullBright:        fullBright,
+	})
+}
+
+// Particles fly away from origin. The effect must have been defined for the connection first
+func (connection *Connection) SpawnEffect(effect *particles.Effect, x, y, z float32, origin world.Position) error {
+	if !connection.SupportsExtension(protocol.EXT_CUSTOM_PARTICLES, 1) {
+		return nil
+	}
+	return connection.SendPacket(protocol.PacketID_SpawnEffect, encoding.SpawnEffectData{
+		EffectID: effect.ID,
+		X:        int32(x * COORDINATE_SCALE),
+		Y:        int32(y * COORDINATE_SCALE),
+		Z:        int32(z * COORDINATE_SCALE),
+		OriginX:  int32(origin.X * COORDINATE_SCALE),
+		OriginY:  int32(origin.Y * COORDINATE_SCALE),
+		OriginZ:  int32(origin.Z * COORDINATE_SCALE),
+	})
+}
+
+func (connection *Connection) SetTextHotKey(hotkey hotkeys.HotKey) error {
+	if !connection.SupportsExtension(protocol.EXT_TEXT_HOT_KEY, 1) {
+		return nil
+	}
+	return connection.SendPacket(protocol.PacketID_SetTextHotKey, encoding.SetTextHotKeyData{
+		Label:   hotkey.Label,
+		Action:  hotkey.ClientAction(),
+		KeyCode: hotkey.KeyCode,
+		KeyMods: hotkey.KeyMods,
+	})
+}
+
+func (connection *Connection) SetTextColor(color chat.TextColor) error {
+	if !connection.SupportsExtension(protocol.EXT_TEXT_COLORS, 1) {
+		return nil
+	}
+	return connection.SendPacket(protocol.PacketID_SetTextColor, encoding.SetTextColorData{
+		Red:   color.Red,
+		Green: color.Green,
+		Blue:  color.Blue,
+		Alpha: color.Alpha,
+		Code:  color.Code,
+	})
+}
+
+func (connection *Connection) SetLightingMode(mode byte, locked bool) error {
+	if !connection.SupportsExtension(protocol.EXT_LIGHTING_MODE, 1) {
+		return nil
+	}
+	var lockedByte byte
+	if locked {
+		lockedByte = 1
+	}
+	return connection.SendPacket(protocol.PacketID_LightingMode, encoding.LightingModeData{Mode: mode, Locked: lockedByte})
+}
+
+// Variable is one of world.ENV_COLOR_*. Default colors reset the client's
+func (connection *Connection) SetEnvColor(variable byte, color world.EnvColor) error {
+	if !connection.SupportsExtension(protocol.EXT_ENV_COLORS, 1) {
+		return nil
+	}
+	if color.IsDefault() {
+		color = world.DEFAULT_ENV_COLOR
+	}
+	return connection.SendPacket(protocol.PacketID_EnvSetColor, encoding.EnvSetColorData{
+		Variable: variable,
+		Red:      color.Red,
+		Green:    color.Green,
+		Blue:     color.Blue,
+	})
+}
+
+func (connection *Connection) SetMapAppearance(appearance world.MapAppearance) error {
+	if !connection.SupportsExtension(protocol.EXT_ENV_MAP_APPEARANCE, 1) {
+		return nil
+	}
+	return connection.SendPacket(protocol.PacketID_EnvSetMapAppearance, encoding.EnvSetMapAppearanceData{
+		TextureURL: appearance.TextureURL,
+		SideBlock:  connection.clientBlock(appearance.SideBlock),
+		EdgeBlock:  connection.clientBlock(appearance.EdgeBlock),
+		SideLevel:  appearance.SideLevel,
+	})
+}
+
+// Colors and appearance of the level, or the defaults for levels without them
+func (connection *Connection) sendEnvironment(level *world.Level) error {
+	environment := level.Settings().Environment()
+	if environment == nil {
+		environment = world.NewEnvironment()
+	}
+	for variable, color := range environment.Colors {
+		if err := connection.SetEnvColor(byte(variable), color); err != nil {
+			return err
+		}
+	}
+	appearance := environment.Appearance
+	if appearance == nil {
+		_, height, _ := level.Size()
+		appearance = &world.MapAppearance{SideBlock: world.BLOCK_BEDROCK, EdgeBlock: world.BLOCK_STATIONARY_WATER, SideLevel: height / 2}
+	}
+	return connection.SetMapAppearance(*appearance)
+}
+
+// An order of 0 removes the block from the inventory. Blocks the client doesn't know aren't in it to begin with
+func (connection *Connection) SetInventoryOrder(block byte, order byte) error {
+	if !connection.SupportsExtension(protocol.EXT_INVENTORY_ORDER, 1) || !connection.knowsBlock(block) {
+		return nil
+	}
+	return connection.SendPacket(protocol.PacketID_SetInventoryOrder, encoding.SetInventoryOrderData{BlockType: block, Order: order})
+}
+
+func (connection *Connection) SetBlockHidden(block byte, hidden bool) error {
+	connection.stateMutex.Lock()
+	defer connection.stateMutex.Unlock()
+	return connection.setBlockHidden(block, hidden)
+}
+
+// The caller must hold stateMutex
+func (connection *Connection) setBlockHidden(block byte, hidden bool) error {
+	index := slices.Index(connection.hiddenBlocks, block)
+	if hidden && index == -1 {
+		connection.hiddenBlocks = append(connection.hiddenBlocks, block)
+	} else if !hidden && index != -1 {
+		connection.hiddenBlocks = slices.Delete(connection.hiddenBlocks, index, index+1)
+	}
+	if hidden {
+		return connection.SetInventoryOrder(block, 0)
+	}
+	// Clients place blocks by their id by default
+	return connection.SetInventoryOrder(block, block)
+}
+
+// Opens or closes the client's block list
+func (connection *Connection) ToggleBlockList(open bool) error {
+	if !connection.SupportsExtension(protocol.EXT_TOGGLE_BLOCK_LIST, 1) {
+		return nil
+	}
+	var openByte byte
+	if open {
+		openByte = 1
+	}
+	return connection.SendPacket(protocol.PacketID_ToggleBlockList, encoding.ToggleBlockListData{Open: openByte})
+}
+
+// Environment, lighting, hidden blocks and reach of the level, undoing what the previous level changed
+func (connection *Connection) sendLevelSettings(level *world.Level) error {
+	if err := connection.sendEnvironment(level); err != nil {
+		return err
+	}
+	mode, locked := level.Settings().Lighting()
+	if err := connection.SetLightingMode(mode, locked); err != nil {
+		return err
+	}
+	hidden := level.Settings().Hidden()
+	connection.stateMutex.Lock()
+	defer connection.stateMutex.Unlock()
+	for _, block := range connection.hiddenBlocks {
+		if !slices.Contains(hidden, block) {
+			if err := connection.setBlockHidden(block, false); err != nil {
+				return err
+			}
+		}
+	}
+	for _, block := range hidden {
+		if !slices.Contains(connection.hiddenBlocks, block) {
+			if err := connection.setBlockHidden(block, true); err != nil {
+				return err
+			}
+		}
+	}
+	distance := float32(DEFAULT_CLICK_DISTANCE)
+	if behaviors := level.Behaviors(); behaviors.ClickDistance != nil {
+		distance = *behaviors.ClickDistance
+	}
+	if distance != connection.clickDistance {
+		return connection.setClickDistance(distance)
+	}
+	return nil
+}
+
+// Sends the given particle effects, the configured hotkeys and colors, and the environment of the player's level
+func (connection *Connection) sendCustomizations(effects []*particles.Effect) error {
+	context := connection.Server().Context()
+	for _, color := range context.TextColors().All() {
+		if err := connection.SetTextColor(color); err != nil {
+			return err
+		}
+	}
+	if player := connection.Player(); player != nil && player.Level() != nil {
+		if err := connection.sendLevelSettings(player.Level()); err != nil {
+			return err
+		}
+	}
+	for _, effect := range effects {
+		if err := connection.DefineEffect(effect); err != nil {
+			return err
+		}
+	}
+	for _, hotkey := range context.HotKeys() {
+		if err := connection.SetTextHotKey(hotkey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
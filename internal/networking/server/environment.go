@@ -0,0 +1,64 @@
+package server
+
+import (
+	"log"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/chat"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/servercontext"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+func (server *Server) forEachConnection(action string, send func(connection *Connection) error) {
+	for _, connection := range server.Connections() {
+		if err := send(connection); err != nil {
+			log.Printf("Failed to %s for connection %d: %v", action, connection.Id(), err)
+		}
+	}
+}
+
+func (server *Server) SetTextColor(color chat.TextColor) error {
+	if err := server.context.TextColors().Set(color); err != nil {
+		return err
+	}
+	server.forEachConnection("set text color", func(connection *Connection) error {
+		return connection.SetTextColor(color)
+	})
+	return server.context.TextColors().Save(servercontext.TEXT_COLORS_FILE)
+}
+
+func (server *Server) RemoveTextColor(code byte) error {
+	if err := server.context.TextColors().Remove(code); err != nil {
+		return err
+	}
+	// A transparent color tells the client to drop it
+	server.forEachConnection("remove text color", func(connection *Connection) error {
+		return connection.SetTextColor(chat.TextColor{Code: code})
+	})
+	return server.context.TextColors().Save(servercontext.TEXT_COLORS_FILE)
+}
+
+func (server *Server) forEachConnectionInLevel(level *world.Level, action string, send func(connection *Connection) error) {
+	for _, player := range server.PlayersInLevel(level) {
+		if err := send(player.connection); err != nil {
+			log.Printf("Failed to %s for connection %d: %v", action, player.connection.Id(), err)
+		}
+	}
+}
+
+func (server *Server) SetLightingMode(level *world.Level, mode byte, locked bool) error {
+	level.Settings().SetLighting(mode, locked)
+	server.forEachConnectionInLevel(level, "set lighting mode", func(connection *Connection) error {
+		return connection.SetLightingMode(mode, locked)
+	})
+	return server.context.Worlds.SaveSettings(level)
+}
+
+func (server *Server) SetBlockHidden(level *world.Level, block byte, hidden bool) error {
+	if !level.Settings().SetHidden(block, hidden) {
+		return nil
+	}
+	server.forEachConnectionInLevel(level, "toggle block", func(connection *Connection) error {
+		return connection.SetBlockHidden(block, hidden)
+	})
+	return server.context.Worlds.SaveSettings(level)
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"github.com/Hedwig7s/Burrowing-Classic/internal/heartbeat"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/servercontext"
+)
+
+// Protocol version advertised to server lists
+const HEARTBEAT_PROTOCOL_VERSION = 7
+
+func (server *Server) HeartbeatInfo() heartbeat.Info {
+	context := server.context
+	config := context.Config()
+	users := 0
+	for _, player := range server.Players() {
+		if player.connection.loggedIn.Load() {
+			users++
+		}
+	}
+	return heartbeat.Info{
+		Name:     config.Name,
+		Port:     server.port,
+		Users:    users,
+		Max:      server.MaxPlayers(),
+		Public:   config.Public,
+		Version:  HEARTBEAT_PROTOCOL_VERSION,
+		Salt:     context.Salt,
+		Software: servercontext.SOFTWARE,
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"time"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+const LEVEL_CHUNK_SIZE = 1024
+
+// Streams the level to the client and moves them to its spawn
+func (connection *Connection) SendLevel(level *world.Level) error {
+	if err := connection.SendPacket(protocol.PacketID_LevelInitialize, encoding.LevelInitializeData{}); err != nil {
+		return err
+	}
+	compress := level.FallbackCompressed
+	if connection.customBlocks {
+		compress = level.Compressed
+	}
+	compressed, err := compress()
+	if err != nil {
+		return err
+	}
+	for offset := 0; offset < len(compressed); offset += LEVEL_CHUNK_SIZE {
+		end := min(offset+LEVEL_CHUNK_SIZE, len(compressed))
+		chunk := encoding.LevelDataChunkData{
+			ChunkLength:     int16(end - offset),
+			PercentComplete: byte(end * 100 / len(compressed)),
+		}
+		copy(chunk.ChunkData[:], compressed[offset:end])
+		if err := connection.SendPacket(protocol.PacketID_LevelDataChunk, chunk); err != nil {
+			return err
+		}
+	}
+	width, height, length := level.Size()
+	if err := connection.SendPacket(protocol.PacketID_LevelFinalize, encoding.LevelFinalizeData{
+		XSize: width,
+		YSize: height,
+		ZSize: length,
+	}); err != nil {
+		return err
+	}
+	// Checkpoints belong to the level they were reached in
+	connection.spawnpoint = nil
+	if connection.player != nil {
+		connection.player.setLevel(level)
+		connection.player.resetRelay(level.Spawn())
+		connection.server.context.Worlds.Touch(level)
+	}
+	if err := connection.sendLevelSettings(level); err != nil {
+		return err
+	}
+	return connection.Teleport(level.Spawn())
+}
+
+// Loads the world if it isn't already
+func (server *Server) Level(name string) (*world.Level, bool) {
+	level, err := server.context.Worlds.Get(name)
+	return level, err == nil
+}
+
+// Saves and unloads worlds nobody has been in for the configured time
+func (server *Server) UnloadIdleWorlds() {
+	if server.context.Config().IdleUnloadSeconds <= 0 {
+		return
+	}
+	timeout := time.Duration(server.context.Config().IdleUnloadSeconds) * time.Second
+	server.context.Worlds.UnloadIdle(timeout, func(level *world.Level) bool {
+		return len(server.PlayersInLevel(level)) > 0
+	})
+}
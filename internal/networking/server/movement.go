@@ -0,0 +1,217 @@
+package server
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+// Blocks a player can be past the sides or top of the level, which clients allow when walking on the edge or flying
+const BOUNDS_LEEWAY = 32
+
+// Movements sent before the client received a new level, or after being launched, can be outside of the level, so are ignored for this long
+const MOVEMENT_GRACE = 5 * time.Second
+
+const (
+	MOVEMENT_OUT_OF_LEVEL = iota
+	MOVEMENT_NOT_FINITE
+)
+
+// Movement as last relayed to other players, in the fixed point units they received it in
+type relayedPosition struct {
+	x, y, z    int32
+	yaw, pitch byte
+}
+
+func toFixed(v float32) int32 {
+	return int32(math.Round(float64(v) * COORDINATE_SCALE))
+}
+
+func fromFixed(v int32) float32 {
+	return float32(v) / COORDINATE_SCALE
+}
+
+func fitsFByte(v int32) bool {
+	return v >= math.MinInt8 && v <= math.MaxInt8
+}
+
+func newRelayedPosition(position world.Position) relayedPosition {
+	return relayedPosition{
+		x:     toFixed(position.X),
+		y:     toFixed(position.Y),
+		z:     toFixed(position.Z),
+		yaw:   position.Yaw,
+		pitch: position.Pitch,
+	}
+}
+
+func (relayed relayedPosition) position() world.Position {
+	return world.Position{
+		X:     fromFixed(relayed.x),
+		Y:     fromFixed(relayed.y),
+		Z:     fromFixed(relayed.z),
+		Yaw:   relayed.yaw,
+		Pitch: relayed.pitch,
+	}
+}
+
+// Position other players were last told about, which new viewers must be spawned at for later updates to line up
+func (player *Player) relayedPosition() world.Position {
+	player.mutex.RLock()
+	defer player.mutex.RUnlock()
+	return player.relayed.position()
+}
+
+func (player *Player) resetRelay(position world.Position) {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	player.relayed = newRelayedPosition(position)
+}
+
+// Returns the smallest packet describing the movement since the last relay, or false if the player hasn't moved
+func (player *Player) nextMovementPacket() (protocol.PacketID, any, bool) {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	current := newRelayedPosition(player.position)
+	previous := player.relayed
+	if current == previous {
+		return 0, nil, false
+	}
+	player.relayed = current
+
+	dx, dy, dz := current.x-previous.x, current.y-previous.y, current.z-previous.z
+	moved := dx != 0 || dy != 0 || dz != 0
+	turned := current.yaw != previous.yaw || current.pitch != previous.pitch
+	id := player.entityID
+	switch {
+	case !fitsFByte(dx) || !fitsFByte(dy) || !fitsFByte(dz):
+		position := current.position()
+		return protocol.PacketID_SetPositionAndOrientation, encoding.SetPositionAndOrientationData{
+			PlayerID: id,
+			X:        position.X,
+			Y:        position.Y,
+			Z:        position.Z,
+			Yaw:      position.Yaw,
+			Pitch:    position.Pitch,
+		}, true
+	case moved && turned:
+		return protocol.PacketID_PositionAndOrientationUpdate, encoding.PositionAndOrientationUpdateData{
+			PlayerID: id,
+			ChangeX:  fromFixed(dx),
+			ChangeY:  fromFixed(dy),
+			ChangeZ:  fromFixed(dz),
+			Yaw:      current.yaw,
+			Pitch:    current.pitch,
+		}, true
+	case moved:
+		return protocol.PacketID_PositionUpdate, encoding.PositionUpdateData{
+			PlayerID: id,
+			ChangeX:  fromFixed(dx),
+			ChangeY:  fromFixed(dy),
+			ChangeZ:  fromFixed(dz),
+		}, true
+	default:
+		return protocol.PacketID_OrientationUpdate, encoding.OrientationUpdateData{
+			PlayerID: id,
+			Yaw:      current.yaw,
+			Pitch:    current.pitch,
+		}, true
+	}
+}
+
+// Sends everyone's movement since the last tick to the other players in their level
+func (server *Server) RelayMovement() {
+	for _, player := range server.Players() {
+		if !player.connection.loggedIn.Load() {
+			continue
+		}
+		id, data, moved := player.nextMovementPacket()
+		if !moved {
+			continue
+		}
+		for _, other := range server.PlayersInLevel(player.Level()) {
+			if other == player {
+				continue
+			}
+			if err := other.connection.SendPacket(id, data); err != nil {
+				log.Printf("Failed to relay movement of %s to %s: %v", player.name, other.name, err)
+			}
+		}
+	}
+}
+
+func blockCoordinate(v float32) int16 {
+	return int16(math.Floor(float64(v)))
+}
+
+// Clients without SetSpawnpoint respawn themselves at the level spawn, so are sent on to their checkpoint
+func (player *Player) respawnedAtLevelSpawn(level *world.Level, position world.Position) bool {
+	connection := player.connection
+	if connection.spawnpoint == nil || connection.SupportsExtension(protocol.EXT_SET_SPAWNPOINT, 1) {
+		return false
+	}
+	spawn := newRelayedPosition(level.Spawn())
+	moved := newRelayedPosition(position)
+	return moved.x == spawn.x && moved.y == spawn.y && moved.z == spawn.z
+}
+
+func inMovementBounds(level *world.Level, position world.Position) bool {
+	width, height, length := level.Size()
+	return position.X >= -BOUNDS_LEEWAY && position.X <= float32(width)+BOUNDS_LEEWAY &&
+		position.Z >= -BOUNDS_LEEWAY && position.Z <= float32(length)+BOUNDS_LEEWAY &&
+		position.Y-world.PLAYER_HEIGHT <= float32(height)+BOUNDS_LEEWAY
+}
+
+func finite(position world.Position) bool {
+	for _, v := range []float32{position.X, position.Y, position.Z} {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// Stores the player's new position, triggering any behaviors when they enter a new block
+func (player *Player) handleMovement(position world.Position) error {
+	if !finite(position) {
+		return cerror.NewErrorf(MOVEMENT_NOT_FINITE, "Position %v isn't finite", position)
+	}
+	level := player.Level()
+	if level != nil && player.respawnedAtLevelSpawn(level, position) {
+		return player.connection.Respawn()
+	}
+	if level != nil && !inMovementBounds(level, position) {
+		if time.Now().Before(player.boundsGraceEnd()) {
+			return nil
+		}
+		return cerror.NewErrorf(MOVEMENT_OUT_OF_LEVEL, "Position %v is outside of level %s", position, level.Name())
+	}
+	previous := player.Position()
+	player.setPosition(position)
+	if level == nil {
+		return nil
+	}
+	// Fallen out of the bottom of the level
+	if position.Y-world.PLAYER_HEIGHT < 0 {
+		return player.connection.Respawn()
+	}
+	x, y, z := blockCoordinate(position.X), blockCoordinate(position.Y-world.PLAYER_HEIGHT), blockCoordinate(position.Z)
+	if x == blockCoordinate(previous.X) && y == blockCoordinate(previous.Y-world.PLAYER_HEIGHT) && z == blockCoordinate(previous.Z) {
+		return nil
+	}
+	var below byte
+	if level.InBounds(x, y-1, z) {
+		below, _ = level.GetBlock(x, y-1, z)
+	}
+	for _, behavior := range level.Behaviors().At(x, y, z, below) {
+		if err := player.connection.ApplyBehavior(behavior); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,93 +1,212 @@
 package server
 
 import (
+	"strings"
+
 	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/chat"
 	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
 	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/servercontext"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
 )
 
 const idMismatch = "Packet is not %s. Packet ID: %d"
 const dataMismatch = "Packet data is not %s. Got %v"
+const unexpectedPacket = "Unexpected %s packet"
 
 const (
 	PACKETHANDLER_ID_MISMATCH = iota
 	PACKETHANDLER_DATA_MISMATCH
+	PACKETHANDLER_UNEXPECTED_PACKET
+	PACKETHANDLER_INVALID_NAME
 )
 
 type PacketHandler func(connection *Connection, packet protocol.Packet) error
 
+func packetData[T any](packet protocol.Packet, id protocol.PacketID, name string) (T, error) {
+	var data T
+	if packet.ID() != id {
+		return data, cerror.NewErrorf(PACKETHANDLER_ID_MISMATCH, idMismatch, name, packet.ID())
+	}
+	data, ok := packet.Data().(T)
+	if !ok {
+		return data, cerror.NewErrorf(PACKETHANDLER_DATA_MISMATCH, dataMismatch, name+"Data", packet)
+	}
+	return data, nil
+}
+
+func finishLogin(connection *Connection) error {
+	connection.negotiating = false
+	context := connection.Server().Context()
+	connection.Server().replaceSession(connection.identification.Name)
+	player, err := connection.Server().addPlayer(connection, connection.identification.Name)
+	if err != nil {
+		connection.Kick(err.Error())
+		return nil
+	}
+	config := context.Config()
+	identification_data := encoding.IdentificationData{
+		ProtocolVersion: byte(connection.Protocol().Version()),
+		Name:            config.Name,
+		MotdOrKey:       config.Motd,
+		UserType:        userType(player.Rank()),
+	}
+	if err := connection.SendPacket(protocol.PacketID_Identification, identification_data); err != nil {
+		return err
+	}
+	if err := connection.sendCustomizations(context.Particles().Entries()); err != nil {
+		return err
+	}
+	if err := connection.SendLevel(context.Worlds.Main()); err != nil {
+		return err
+	}
+	if err := player.spawnToLevel(); err != nil {
+		return err
+	}
+	connection.loggedIn.Store(true)
+	return nil
+}
+
+// Asks clients supporting CustomBlocks for their support level before logging them in
+func finishNegotiation(connection *Connection) error {
+	if !connection.SupportsExtension(protocol.EXT_CUSTOM_BLOCKS, 1) {
+		return finishLogin(connection)
+	}
+	connection.awaitingBlockSupport = true
+	return connection.SendPacket(protocol.PacketID_CustomBlockSupportLevel, encoding.CustomBlockSupportLevelData{SupportLevel: protocol.CUSTOM_BLOCKS_SUPPORT_LEVEL})
+}
+
+func sendExtensions(connection *Connection) error {
+	info := encoding.ExtInfoData{
+		AppName:        servercontext.SOFTWARE,
+		ExtensionCount: int16(len(EXTENSIONS)),
+	}
+	if err := connection.SendPacket(protocol.PacketID_ExtInfo, info); err != nil {
+		return err
+	}
+	for _, extension := range EXTENSIONS {
+		entry := encoding.ExtEntryData{ExtName: extension.Name, Version: extension.Version}
+		if err := connection.SendPacket(protocol.PacketID_ExtEntry, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var PacketHandlers = map[protocol.PacketID]PacketHandler{
 	protocol.PacketID_Identification: func(connection *Connection, packet protocol.Packet) error {
-		if packet.ID() != protocol.PacketID_Identification {
-			return cerror.NewErrorf(PACKETHANDLER_ID_MISMATCH, idMismatch, "Identification", packet.ID())
+		data, err := packetData[encoding.IdentificationData](packet, protocol.PacketID_Identification, "Identification")
+		if err != nil {
+			return err
 		}
-		var ok bool
-		var data encoding.IdentificationData
-		if data, ok = packet.Data().(encoding.IdentificationData); !ok {
-			return cerror.NewErrorf(PACKETHANDLER_DATA_MISMATCH, dataMismatch, "IdentificationData", packet)
+		if connection.loggedIn.Load() || connection.negotiating {
+			return cerror.NewErrorf(PACKETHANDLER_UNEXPECTED_PACKET, unexpectedPacket, "Identification")
 		}
-
-		identification_data := encoding.IdentificationData{
-			ProtocolVersion: data.ProtocolVersion,
-			Name:            "Burrowing Classic",
-			MotdOrKey:       "Where we're going, we don't need a motd.", // TODO: Add config
-			UserType:        data.UserType,
+		if !ValidPlayerName(data.Name) {
+			connection.Kick(KICK_INVALID_NAME)
+			return cerror.NewErrorf(PACKETHANDLER_INVALID_NAME, "Invalid name %q", data.Name)
 		}
-		identification_builder, err := connection.Protocol().CreatePacketBuilder(protocol.PacketID_Identification)
-
+		connection.identification = data
+		if !connection.verifyName(data.Name, data.MotdOrKey) {
+			connection.Kick(KICK_UNVERIFIED)
+			return nil
+		}
+		if data.UserType != protocol.CPE_MAGIC {
+			return finishLogin(connection)
+		}
+		connection.negotiating = true
+		return sendExtensions(connection)
+	},
+	protocol.PacketID_ExtInfo: func(connection *Connection, packet protocol.Packet) error {
+		data, err := packetData[encoding.ExtInfoData](packet, protocol.PacketID_ExtInfo, "ExtInfo")
 		if err != nil {
 			return err
 		}
-
-		identification, err := identification_builder.Build(identification_data)
+		if !connection.negotiating {
+			return cerror.NewErrorf(PACKETHANDLER_UNEXPECTED_PACKET, unexpectedPacket, "ExtInfo")
+		}
+		connection.pendingExtensions = data.ExtensionCount
+		if connection.pendingExtensions <= 0 {
+			return finishLogin(connection)
+		}
+		return nil
+	},
+	protocol.PacketID_ExtEntry: func(connection *Connection, packet protocol.Packet) error {
+		data, err := packetData[encoding.ExtEntryData](packet, protocol.PacketID_ExtEntry, "ExtEntry")
 		if err != nil {
 			return err
 		}
-		connection.Write(identification)
-		b, err := connection.Protocol().CreatePacketBuilder(protocol.PacketID_LevelInitialize)
+		if !connection.negotiating || connection.pendingExtensions <= 0 {
+			return cerror.NewErrorf(PACKETHANDLER_UNEXPECTED_PACKET, unexpectedPacket, "ExtEntry")
+		}
+		connection.extensions[data.ExtName] = data.Version
+		connection.pendingExtensions--
+		if connection.pendingExtensions == 0 {
+			return finishNegotiation(connection)
+		}
+		return nil
+	},
+	protocol.PacketID_CustomBlockSupportLevel: func(connection *Connection, packet protocol.Packet) error {
+		data, err := packetData[encoding.CustomBlockSupportLevelData](packet, protocol.PacketID_CustomBlockSupportLevel, "CustomBlockSupportLevel")
 		if err != nil {
 			return err
 		}
-		level_initialize, err := b.Build(encoding.LevelInitializeData{})
+		if !connection.negotiating || !connection.awaitingBlockSupport {
+			return cerror.NewErrorf(PACKETHANDLER_UNEXPECTED_PACKET, unexpectedPacket, "CustomBlockSupportLevel")
+		}
+		connection.awaitingBlockSupport = false
+		connection.customBlocks = data.SupportLevel >= 1
+		return finishLogin(connection)
+	},
+	protocol.PacketID_TwoWayPing: func(connection *Connection, packet protocol.Packet) error {
+		data, err := packetData[encoding.TwoWayPingData](packet, protocol.PacketID_TwoWayPing, "TwoWayPing")
 		if err != nil {
 			return err
 		}
-		connection.Write(level_initialize)
-		return nil
-	}, /*
-		PacketID_SetBlockServerbound: func(connection *Connection, packet Packet) error {
-			if packet.ID() != Protocol.PacketID_SetBlockServerbound {
-				return cerror.NewErrorf(PACKETHANDLER_ID_MISMATCH, idMismatch, "SetBlockServerbound", packet.ID())
-			}
-			var ok bool
-			var data encoding.SetBlockServerboundData
-			if data, ok = packet.Data().(encoding.SetBlockServerboundData); !ok {
-				return cerror.NewErrorf(PACKETHANDLER_DATA_MISMATCH, dataMismatch, "SetBlockServerboundData", packet)
-			}
-			return nil
-		},
-		Protocol.PacketID_SetPositionAndOrientation: func(connection *Connection, packet Packet) error {
-			if packet.ID() != Protocol.PacketID_SetPositionAndOrientation {
-				return cerror.NewErrorf(PACKETHANDLER_ID_MISMATCH, idMismatch, "SetPositionAndOrientation", packet.ID())
-			}
-			var ok bool
-			var data encoding.SetPositionAndOrientationData
-			if data, ok = packet.Data().(encoding.SetPositionAndOrientationData); !ok {
-				return cerror.NewErrorf(PACKETHANDLER_DATA_MISMATCH, dataMismatch, "SetPositionAndOrientationData", packet)
-			}
+		return connection.handleTwoWayPing(data)
+	},
+	protocol.PacketID_PluginMessage: func(connection *Connection, packet protocol.Packet) error {
+		data, err := packetData[encoding.PluginMessageData](packet, protocol.PacketID_PluginMessage, "PluginMessage")
+		if err != nil {
+			return err
+		}
+		return connection.handlePluginMessage(data)
+	},
+	protocol.PacketID_SetBlockServerbound: func(connection *Connection, packet protocol.Packet) error {
+		data, err := packetData[encoding.SetBlockServerboundData](packet, protocol.PacketID_SetBlockServerbound, "SetBlockServerbound")
+		if err != nil {
+			return err
+		}
+		return connection.handleSetBlock(data)
+	},
+	protocol.PacketID_SetPositionAndOrientation: func(connection *Connection, packet protocol.Packet) error {
+		data, err := packetData[encoding.SetPositionAndOrientationData](packet, protocol.PacketID_SetPositionAndOrientation, "SetPositionAndOrientation")
+		if err != nil {
+			return err
+		}
+		player := connection.Player()
+		if player == nil {
 			return nil
-		},
-		Protocol.PacketID_Message: func(connection *Connection, packet Packet) error {
-			if packet.ID() != Protocol.PacketID_Message {
-				return cerror.NewErrorf(PACKETHANDLER_ID_MISMATCH, idMismatch, "Message", packet.ID())
-			}
-			var ok bool
-			var data encoding.MessageData
-			if data, ok = packet.Data().(encoding.MessageData); !ok {
-				return cerror.NewErrorf(PACKETHANDLER_DATA_MISMATCH, dataMismatch, "MessageData", packet)
-			}
+		}
+		return player.handleMovement(world.Position{X: data.X, Y: data.Y, Z: data.Z, Yaw: data.Yaw, Pitch: data.Pitch})
+	},
+	protocol.PacketID_Message: func(connection *Connection, packet protocol.Packet) error {
+		data, err := packetData[encoding.MessageData](packet, protocol.PacketID_Message, "Message")
+		if err != nil {
+			return err
+		}
+		player := connection.Player()
+		if player == nil || !connection.loggedIn.Load() {
+			return cerror.NewErrorf(PACKETHANDLER_UNEXPECTED_PACKET, unexpectedPacket, "Message")
+		}
+		if strings.HasPrefix(data.Message, COMMAND_PREFIX) {
+			connection.Server().ExecuteCommand(player, chat.StripUnprintable(data.Message))
 			return nil
-		},*/
+		}
+		player.Chat(data.Message)
+		return nil
+	},
 }
 
 func HandlePacket(connection *Connection, packet protocol.Packet) error {
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
+)
+
+const PING_INTERVAL = 5 * time.Second
+
+// Pings older than this are assumed lost
+const PING_TIMEOUT = 30 * time.Second
+
+type pingTracker struct {
+	mutex   sync.Mutex
+	next    int16
+	sent    map[int16]time.Time
+	latency time.Duration
+	// Whether latency was measured from TCP writes rather than TwoWayPing
+	estimated bool
+}
+
+func (tracker *pingTracker) start(now time.Time) int16 {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if tracker.sent == nil {
+		tracker.sent = make(map[int16]time.Time)
+	}
+	for id, sent := range tracker.sent {
+		if now.Sub(sent) > PING_TIMEOUT {
+			delete(tracker.sent, id)
+		}
+	}
+	id := tracker.next
+	tracker.next++
+	tracker.sent[id] = now
+	return id
+}
+
+func (tracker *pingTracker) finish(id int16, now time.Time) bool {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	sent, ok := tracker.sent[id]
+	if !ok {
+		return false
+	}
+	delete(tracker.sent, id)
+	tracker.latency = now.Sub(sent)
+	tracker.estimated = false
+	return true
+}
+
+func (tracker *pingTracker) estimate(latency time.Duration) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.latency = latency
+	tracker.estimated = true
+}
+
+// Round trip time of the connection. Estimated is true when the client lacks TwoWayPing, in which case it's only the time taken to write a ping
+func (connection *Connection) Latency() (latency time.Duration, estimated bool) {
+	connection.pingTracker.mutex.Lock()
+	defer connection.pingTracker.mutex.Unlock()
+	return connection.pingTracker.latency, connection.pingTracker.estimated
+}
+
+// Extra leeway to give checks which depend on the client being up to date
+func (connection *Connection) LatencyTolerance() time.Duration {
+	latency, _ := connection.Latency()
+	return latency / 2
+}
+
+func (connection *Connection) Ping() error {
+	if connection.SupportsExtension(protocol.EXT_TWO_WAY_PING, 1) {
+		id := connection.pingTracker.start(time.Now())
+		return connection.SendPacket(protocol.PacketID_TwoWayPing, encoding.TwoWayPingData{
+			Direction: protocol.PING_DIRECTION_SERVER,
+			Data:      id,
+		})
+	}
+	start := time.Now()
+	if err := connection.SendPacket(protocol.PacketID_Ping, encoding.PingPacketData{}); err != nil {
+		return err
+	}
+	connection.pingTracker.estimate(time.Since(start))
+	return nil
+}
+
+func (connection *Connection) handleTwoWayPing(data encoding.TwoWayPingData) error {
+	switch data.Direction {
+	case protocol.PING_DIRECTION_CLIENT:
+		return connection.SendPacket(protocol.PacketID_TwoWayPing, data)
+	case protocol.PING_DIRECTION_SERVER:
+		connection.pingTracker.finish(data.Data, time.Now())
+	}
+	return nil
+}
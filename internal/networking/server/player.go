@@ -0,0 +1,276 @@
+package server
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/ranks"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/servercontext"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+// Entity id which clients treat as themselves
+const SELF_ENTITY_ID = -1
+
+const MAX_PLAYERS = 128
+
+const (
+	PLAYER_SERVER_FULL = iota
+)
+
+type Player struct {
+	connection *Connection
+	name       string
+	entityID   int8
+
+	mutex    sync.RWMutex
+	position world.Position
+	relayed  relayedPosition
+	level    *world.Level
+	// Movements outside the level are ignored until then
+	boundsGrace time.Time
+}
+
+func (player *Player) Connection() *Connection {
+	return player.connection
+}
+
+func (player *Player) Name() string {
+	return player.name
+}
+
+func (player *Player) EntityID() int8 {
+	return player.entityID
+}
+
+// Includes orientation
+func (player *Player) Position() world.Position {
+	player.mutex.RLock()
+	defer player.mutex.RUnlock()
+	return player.position
+}
+
+func (player *Player) setPosition(position world.Position) {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	player.position = position
+}
+
+func (player *Player) Level() *world.Level {
+	player.mutex.RLock()
+	defer player.mutex.RUnlock()
+	return player.level
+}
+
+func (player *Player) setLevel(level *world.Level) {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	player.level = level
+	player.boundsGrace = time.Now().Add(MOVEMENT_GRACE)
+}
+
+func (player *Player) boundsGraceEnd() time.Time {
+	player.mutex.RLock()
+	defer player.mutex.RUnlock()
+	return player.boundsGrace
+}
+
+func (player *Player) extendBoundsGrace() {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	player.boundsGrace = time.Now().Add(MOVEMENT_GRACE)
+}
+
+// Players without a rank, or whose rank no longer exists, have the default rank
+func (player *Player) Rank() *ranks.Rank {
+	context := player.connection.Server().Context()
+	if name, ok := context.PlayerRanks().Get(player.name); ok {
+		if rank, ok := context.Ranks().Get(name); ok {
+			return rank
+		}
+	}
+	return context.Ranks().Default()
+}
+
+func (player *Player) SendMessage(message string) error {
+	return player.connection.SendMessage(message)
+}
+
+// Saved immediately, and applied to the client's user type
+func (player *Player) SetRank(rank *ranks.Rank) error {
+	context := player.connection.Server().Context()
+	context.PlayerRanks().Set(player.name, rank.Name)
+	if err := context.PlayerRanks().Save(servercontext.PLAYER_RANKS_FILE); err != nil {
+		log.Printf("Failed to save %s: %v", servercontext.PLAYER_RANKS_FILE, err)
+	}
+	return player.sendUserType()
+}
+
+func (player *Player) sendUserType() error {
+	return player.connection.SendPacket(protocol.PacketID_UpdateUserType, encoding.UpdateUserTypeData{UserType: userType(player.Rank())})
+}
+
+func (player *Player) HasPermission(node string) bool {
+	return player.connection.Server().Context().Ranks().HasPermission(player.Rank().Name, node)
+}
+
+// Anyone can build in the configured guest world, whatever their rank
+func (player *Player) CanBuild(level *world.Level) bool {
+	guestWorld := player.connection.Server().Context().Config().GuestWorld
+	if guestWorld != "" && strings.EqualFold(level.Name(), guestWorld) {
+		return true
+	}
+	return player.HasPermission(WorldPermission(level, WORLD_PERMISSION_BUILD))
+}
+
+func userType(rank *ranks.Rank) byte {
+	if rank.Op {
+		return protocol.USER_TYPE_OP
+	}
+	return protocol.USER_TYPE_NORMAL
+}
+
+// Spawns other as seen by player
+func (player *Player) spawn(other *Player) error {
+	id := other.entityID
+	position := other.relayedPosition()
+	if other == player {
+		id = SELF_ENTITY_ID
+		position = other.Position()
+	}
+	return player.connection.SendPacket(protocol.PacketID_SpawnPlayer, encoding.SpawnPlayerData{
+		PlayerID:   id,
+		PlayerName: other.name,
+		X:          position.X,
+		Y:          position.Y,
+		Z:          position.Z,
+		Yaw:        position.Yaw,
+		Pitch:      position.Pitch,
+	})
+}
+
+func (player *Player) despawn(other *Player) error {
+	return player.connection.SendPacket(protocol.PacketID_DespawnPlayer, encoding.DespawnPlayerData{PlayerID: other.entityID})
+}
+
+// Shows the player to everyone in their level, and everyone in their level to them
+func (player *Player) spawnToLevel() error {
+	if err := player.spawn(player); err != nil {
+		return err
+	}
+	for _, other := range player.connection.Server().PlayersInLevel(player.Level()) {
+		if other == player {
+			continue
+		}
+		if err := player.spawn(other); err != nil {
+			return err
+		}
+		if err := other.spawn(player); err != nil {
+			log.Printf("Failed to spawn %s for %s: %v", player.name, other.name, err)
+		}
+	}
+	return nil
+}
+
+// Hides the player from everyone else in their level
+func (player *Player) despawnFromLevel() {
+	for _, other := range player.connection.Server().PlayersInLevel(player.Level()) {
+		if other == player {
+			continue
+		}
+		if err := other.despawn(player); err != nil {
+			log.Printf("Failed to despawn %s for %s: %v", player.name, other.name, err)
+		}
+		if err := player.despawn(other); err != nil && !player.connection.closed.Load() {
+			log.Printf("Failed to despawn %s for %s: %v", other.name, player.name, err)
+		}
+	}
+}
+
+// Moves the player to another level, re-streaming it and updating who can see them
+func (player *Player) SwitchLevel(level *world.Level) error {
+	player.despawnFromLevel()
+	if err := player.connection.SendLevel(level); err != nil {
+		return err
+	}
+	return player.spawnToLevel()
+}
+
+// Allocates the player an entity id and adds them to the server
+func (server *Server) addPlayer(connection *Connection, name string) (*Player, error) {
+	server.playerMutex.Lock()
+	defer server.playerMutex.Unlock()
+	if len(server.players) >= server.MaxPlayers() {
+		return nil, cerror.NewError(PLAYER_SERVER_FULL, "Server is full")
+	}
+	var id int8 = SELF_ENTITY_ID
+	for i := range MAX_PLAYERS {
+		if _, taken := server.players[int8(i)]; !taken {
+			id = int8(i)
+			break
+		}
+	}
+	if id == SELF_ENTITY_ID {
+		return nil, cerror.NewError(PLAYER_SERVER_FULL, "Server is full")
+	}
+	player := &Player{
+		connection: connection,
+		name:       name,
+		entityID:   id,
+	}
+	server.players[id] = player
+	connection.player = player
+	return player, nil
+}
+
+// Safe to call more than once
+func (server *Server) removePlayer(player *Player) {
+	server.playerMutex.Lock()
+	if server.players[player.entityID] != player {
+		server.playerMutex.Unlock()
+		return
+	}
+	delete(server.players, player.entityID)
+	server.playerMutex.Unlock()
+	player.despawnFromLevel()
+}
+
+// Configured limit, which can't go above what entity ids allow
+func (server *Server) MaxPlayers() int {
+	return min(server.context.Config().MaxPlayers, MAX_PLAYERS)
+}
+
+func (server *Server) Players() []*Player {
+	server.playerMutex.RLock()
+	defer server.playerMutex.RUnlock()
+	players := make([]*Player, 0, len(server.players))
+	for _, player := range server.players {
+		players = append(players, player)
+	}
+	return players
+}
+
+// Only includes players who have finished logging in
+func (server *Server) PlayersInLevel(level *world.Level) []*Player {
+	var players []*Player
+	for _, player := range server.Players() {
+		if player.connection.loggedIn.Load() && player.Level() == level {
+			players = append(players, player)
+		}
+	}
+	return players
+}
+
+func (server *Server) Player(name string) (*Player, bool) {
+	for _, player := range server.Players() {
+		if strings.EqualFold(player.name, name) {
+			return player, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/encoding"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/pluginmessage"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/networking/protocol"
+)
+
+const (
+	PLUGIN_CHANNEL_EXISTS = iota
+	PLUGIN_CHANNEL_NOT_EXISTS
+)
+
+type PluginMessageHandler func(connection *Connection, data []byte) error
+
+type pluginChannels struct {
+	mutex    sync.RWMutex
+	handlers map[byte]PluginMessageHandler
+}
+
+func (server *Server) RegisterPluginChannel(channel byte, handler PluginMessageHandler) error {
+	channels := &server.pluginChannels
+	channels.mutex.Lock()
+	defer channels.mutex.Unlock()
+	if channels.handlers == nil {
+		channels.handlers = make(map[byte]PluginMessageHandler)
+	}
+	if _, ok := channels.handlers[channel]; ok {
+		return cerror.NewErrorf(PLUGIN_CHANNEL_EXISTS, "Plugin channel %d already has a handler", channel)
+	}
+	channels.handlers[channel] = handler
+	return nil
+}
+
+// Handler receives whole messages reassembled from fragments made by pluginmessage.Fragment
+func (server *Server) RegisterFragmentedPluginChannel(channel byte, handler PluginMessageHandler) error {
+	return server.RegisterPluginChannel(channel, func(connection *Connection, data []byte) error {
+		var fragment [pluginmessage.PACKET_SIZE]byte
+		copy(fragment[:], data)
+		reassembler, ok := connection.pluginReassemblers[channel]
+		if !ok {
+			reassembler = pluginmessage.NewReassembler()
+			connection.pluginReassemblers[channel] = reassembler
+		}
+		message, complete, err := reassembler.Add(fragment)
+		if err != nil || !complete {
+			return err
+		}
+		return handler(connection, message)
+	})
+}
+
+func (server *Server) UnregisterPluginChannel(channel byte) error {
+	channels := &server.pluginChannels
+	channels.mutex.Lock()
+	defer channels.mutex.Unlock()
+	if _, ok := channels.handlers[channel]; !ok {
+		return cerror.NewErrorf(PLUGIN_CHANNEL_NOT_EXISTS, "Plugin channel %d has no handler", channel)
+	}
+	delete(channels.handlers, channel)
+	return nil
+}
+
+func (server *Server) pluginChannelHandler(channel byte) PluginMessageHandler {
+	channels := &server.pluginChannels
+	channels.mutex.RLock()
+	defer channels.mutex.RUnlock()
+	return channels.handlers[channel]
+}
+
+// Messages on channels without a handler are dropped
+func (connection *Connection) handlePluginMessage(data encoding.PluginMessageData) error {
+	handler := connection.Server().pluginChannelHandler(data.Channel)
+	if handler == nil {
+		return nil
+	}
+	return handler(connection, data.Data[:])
+}
+
+func (connection *Connection) SendPluginMessage(channel byte, data [pluginmessage.PACKET_SIZE]byte) error {
+	if !connection.SupportsExtension(protocol.EXT_PLUGIN_MESSAGES, 1) {
+		return nil
+	}
+	return connection.SendPacket(protocol.PacketID_PluginMessage, encoding.PluginMessageData{
+		Channel: channel,
+		Data:    data,
+	})
+}
+
+// Sends a message of any size for a fragmented channel. Message ids should differ between messages in flight on the channel
+func (connection *Connection) SendFragmentedPluginMessage(channel byte, messageID byte, message []byte) error {
+	fragments, err := pluginmessage.Fragment(messageID, message)
+	if err != nil {
+		return err
+	}
+	for _, fragment := range fragments {
+		if err := connection.SendPluginMessage(channel, fragment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
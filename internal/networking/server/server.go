@@ -6,6 +6,12 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"slices"
+	"sync"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/servercontext"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
 )
 
 type Server struct {
@@ -14,8 +20,24 @@ type Server struct {
 	listener     net.Listener
 	started      bool
 	connections  []*Connection
+	connMutex    sync.RWMutex
+	context      *servercontext.ServerContext
+
+	pluginChannels pluginChannels
+	commands       *CommandRegistry
+
+	players          map[int8]*Player
+	playerMutex      sync.RWMutex
+	nextConnectionID uint
+
+	stopping chan struct{}
+	stopOnce sync.Once
 }
 
+const (
+	SERVER_EFFECT_NOT_FOUND = iota
+)
+
 var (
 	ServerAlreadyStarted = errors.New("Server already started!")
 	ListenerWhileStopped = errors.New("Listener present despite server being stopped!")
@@ -35,20 +57,35 @@ func (server *Server) Start(ctx context.Context) error {
 		return err
 	}
 	go func() {
-		<-ctx.Done()
+		select {
+		case <-ctx.Done():
+		case <-server.stopping:
+		}
 		server.Close()
 	}()
+	scheduler := server.context.Scheduler
+	pingTask := scheduler.ScheduleRepeating(PING_INTERVAL, PING_INTERVAL, server.PingAll)
+	defer pingTask.Cancel()
+	movementTask := scheduler.OnTick(server.RelayMovement)
+	defer movementTask.Cancel()
 	for {
 		conn, err := server.listener.Accept()
 		if err != nil {
 			select {
 			case <-ctx.Done():
 				return nil
+			case <-server.stopping:
+				return nil
 			default:
 				return err
 			}
 		}
-		connection := NewConnection(conn)
+		connection := NewConnection(conn, server)
+		server.connMutex.Lock()
+		connection.id = server.nextConnectionID
+		server.nextConnectionID++
+		server.connections = append(server.connections, connection)
+		server.connMutex.Unlock()
 		// FIXME: Wait group perhaps?
 		go func() {
 			err := connection.Start(ctx)
@@ -56,8 +93,8 @@ func (server *Server) Start(ctx context.Context) error {
 				log.Printf("Error in connection: %v", err)
 				connection.Close()
 			}
+			server.removeConnection(connection)
 		}()
-		server.connections = append(server.connections, connection) // TODO: Removal
 
 	}
 }
@@ -77,6 +114,72 @@ func (server *Server) Close() error {
 	return nil
 }
 
-func NewServer(bind_address string, port uint16) *Server {
-	return &Server{bind_address: bind_address, port: port, started: false}
+// Kicks everyone and makes Start return
+func (server *Server) Stop(reason string) {
+	server.stopOnce.Do(func() {
+		server.connMutex.RLock()
+		connections := slices.Clone(server.connections)
+		server.connMutex.RUnlock()
+		for _, connection := range connections {
+			connection.Kick(reason)
+		}
+		close(server.stopping)
+	})
+}
+
+func (server *Server) removeConnection(connection *Connection) {
+	if player := connection.Player(); player != nil {
+		server.removePlayer(player)
+	}
+	server.connMutex.Lock()
+	defer server.connMutex.Unlock()
+	if index := slices.Index(server.connections, connection); index != -1 {
+		server.connections = slices.Delete(server.connections, index, index+1)
+	}
+}
+
+// Open connections which have finished logging in
+func (server *Server) Connections() []*Connection {
+	server.connMutex.RLock()
+	defer server.connMutex.RUnlock()
+	connections := make([]*Connection, 0, len(server.connections))
+	for _, connection := range server.connections {
+		if !connection.closed.Load() && connection.loggedIn.Load() {
+			connections = append(connections, connection)
+		}
+	}
+	return connections
+}
+
+func (server *Server) PingAll() {
+	server.forEachConnection("ping", func(connection *Connection) error {
+		return connection.Ping()
+	})
+}
+
+func (server *Server) SpawnEffect(name string, x, y, z float32, origin world.Position) error {
+	effect, ok := server.context.Particles().Get(name)
+	if !ok {
+		return cerror.NewErrorf(SERVER_EFFECT_NOT_FOUND, "Particle effect %s not found", name)
+	}
+	server.forEachConnection("spawn effect", func(connection *Connection) error {
+		return connection.SpawnEffect(effect, x, y, z, origin)
+	})
+	return nil
+}
+
+func (server *Server) Context() *servercontext.ServerContext {
+	return server.context
+}
+
+func NewServer(bind_address string, port uint16, context *servercontext.ServerContext) *Server {
+	return &Server{
+		bind_address: bind_address,
+		port:         port,
+		started:      false,
+		context:      context,
+		players:      make(map[int8]*Player),
+		commands:     newBuiltinCommandRegistry(),
+		stopping:     make(chan struct{}),
+	}
 }
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/netip"
+	"regexp"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/auth"
+)
+
+const (
+	KICK_UNVERIFIED   = "Could not verify your name, try joining through the server list"
+	KICK_DUPLICATE    = "Logged in from another location"
+	KICK_INVALID_NAME = "Invalid name, only letters, numbers, _ and . are allowed"
+)
+
+var playerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]{1,16}$`)
+
+func ValidPlayerName(name string) bool {
+	return playerNamePattern.MatchString(name)
+}
+
+func (connection *Connection) RemoteAddr() netip.Addr {
+	addrPort, err := netip.ParseAddrPort(connection.conn.RemoteAddr().String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addrPort.Addr().Unmap()
+}
+
+// Trusted networks may skip verification
+func (connection *Connection) verifyName(name, key string) bool {
+	context := connection.Server().Context()
+	config := context.Config()
+	if !config.VerifyNames || config.Trusted(connection.RemoteAddr()) {
+		return true
+	}
+	return auth.VerifyName(context.Salt, name, key)
+}
+
+// Kicks anyone already logged in with the name, so the new session can take over
+func (server *Server) replaceSession(name string) {
+	if existing, ok := server.Player(name); ok {
+		existing.connection.Kick(KICK_DUPLICATE)
+		server.removePlayer(existing)
+	}
+}
@@ -0,0 +1,92 @@
+package particles
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/registry"
+)
+
+const (
+	COLLIDE_LIQUID = 1 << iota
+	COLLIDE_SOLID
+	COLLIDE_LEAVES
+	COLLIDE_EXPIRE
+)
+
+// Region of the terrain atlas used as the particle texture, in pixels
+type TextureRegion struct {
+	U1 byte `json:"u1"`
+	V1 byte `json:"v1"`
+	U2 byte `json:"u2"`
+	V2 byte `json:"v2"`
+}
+
+type Tint struct {
+	Red   byte `json:"red"`
+	Green byte `json:"green"`
+	Blue  byte `json:"blue"`
+}
+
+type Effect struct {
+	EffectName string        `json:"name"`
+	ID         byte          `json:"id"`
+	Texture    TextureRegion `json:"texture"`
+	Tint       Tint          `json:"tint"`
+	FrameCount byte          `json:"frame_count"`
+	Count      byte          `json:"count"`
+	// In blocks
+	Size          float32 `json:"size"`
+	SizeVariation float32 `json:"size_variation"`
+	Spread        float32 `json:"spread"`
+	Speed         float32 `json:"speed"`
+	Gravity       float32 `json:"gravity"`
+	// In seconds
+	Lifetime          float32 `json:"lifetime"`
+	LifetimeVariation float32 `json:"lifetime_variation"`
+	CollideFlags      byte    `json:"collide_flags"`
+	FullBright        bool    `json:"full_bright"`
+}
+
+func (effect *Effect) Name() string {
+	return effect.EffectName
+}
+
+type Registry = registry.NamedRegistry[string, *Effect]
+
+func NewRegistry() *Registry {
+	return registry.NewNamedRegistry[string, *Effect]()
+}
+
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var effects []*Effect
+	if err := json.Unmarshal(data, &effects); err != nil {
+		return nil, err
+	}
+	registry := NewRegistry()
+	for _, effect := range effects {
+		if err := registry.Register(effect); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Effects in current that clients sent previous haven't been given, either new or with a changed definition
+func Changed(previous, current *Registry) []*Effect {
+	defined := make(map[byte]Effect)
+	for _, effect := range previous.Entries() {
+		defined[effect.ID] = *effect
+	}
+	var changed []*Effect
+	for _, effect := range current.Entries() {
+		if old, ok := defined[effect.ID]; !ok || old != *effect {
+			changed = append(changed, effect)
+		}
+	}
+	return changed
+}
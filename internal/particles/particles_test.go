@@ -0,0 +1,27 @@
+package particles
+
+import "testing"
+
+func TestChanged(t *testing.T) {
+	previous := NewRegistry()
+	previous.Register(&Effect{EffectName: "smoke", ID: 1, Count: 4})
+	previous.Register(&Effect{EffectName: "spark", ID: 2, Count: 8})
+	previous.Register(&Effect{EffectName: "gone", ID: 3})
+
+	current := NewRegistry()
+	current.Register(&Effect{EffectName: "smoke", ID: 1, Count: 4})
+	current.Register(&Effect{EffectName: "spark", ID: 2, Count: 16})
+	current.Register(&Effect{EffectName: "rain", ID: 4})
+
+	changed := Changed(previous, current)
+	names := make(map[string]bool)
+	for _, effect := range changed {
+		names[effect.Name()] = true
+	}
+	if len(changed) != 2 || !names["spark"] || !names["rain"] {
+		t.Errorf("Expected spark and rain to have changed, got %v", names)
+	}
+	if changed := Changed(current, current); len(changed) != 0 {
+		t.Errorf("Expected nothing to change against itself, got %d effects", len(changed))
+	}
+}
@@ -0,0 +1,221 @@
+package ranks
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/chat"
+)
+
+const (
+	RANKS_EMPTY = iota
+	RANKS_DUPLICATE
+	RANKS_NOT_FOUND
+)
+
+// Matches any remaining part of a permission node
+const WILDCARD = "*"
+
+const NODE_SEPARATOR = "."
+
+type Rank struct {
+	Name   string `json:"name"`
+	Color  string `json:"color"`
+	Prefix string `json:"prefix,omitempty"`
+	// Shown to clients as op, which lets vanilla clients break bedrock
+	Op bool `json:"op,omitempty"`
+	// Ranks also have every permission of the ranks below them
+	Permissions []string `json:"permissions"`
+}
+
+// Whether the pattern, which may end in a wildcard, covers the node
+func MatchNode(pattern, node string) bool {
+	if pattern == WILDCARD || pattern == node {
+		return true
+	}
+	patternParts := strings.Split(pattern, NODE_SEPARATOR)
+	nodeParts := strings.Split(node, NODE_SEPARATOR)
+	for i, part := range patternParts {
+		if part == WILDCARD && i == len(patternParts)-1 {
+			return true
+		}
+		if i >= len(nodeParts) || (part != WILDCARD && part != nodeParts[i]) {
+			return false
+		}
+	}
+	return len(patternParts) == len(nodeParts)
+}
+
+// Ordered lowest to highest. The lowest rank is given to new players
+type Ranks struct {
+	mutex sync.RWMutex
+	ranks []*Rank
+}
+
+func validateRanks(ranks []*Rank) error {
+	if len(ranks) == 0 {
+		return cerror.NewError(RANKS_EMPTY, "At least one rank is required")
+	}
+	for i, rank := range ranks {
+		if slices.IndexFunc(ranks[:i], func(other *Rank) bool { return other.Name == rank.Name }) != -1 {
+			return cerror.NewErrorf(RANKS_DUPLICATE, "Rank %s is defined twice", rank.Name)
+		}
+	}
+	return nil
+}
+
+// Replaces every rank at once
+func (ranks *Ranks) Set(all []*Rank) error {
+	if err := validateRanks(all); err != nil {
+		return err
+	}
+	ranks.mutex.Lock()
+	defer ranks.mutex.Unlock()
+	ranks.ranks = all
+	return nil
+}
+
+func (ranks *Ranks) Default() *Rank {
+	ranks.mutex.RLock()
+	defer ranks.mutex.RUnlock()
+	return ranks.ranks[0]
+}
+
+func (ranks *Ranks) Get(name string) (*Rank, bool) {
+	ranks.mutex.RLock()
+	defer ranks.mutex.RUnlock()
+	index := ranks.index(name)
+	if index == -1 {
+		return nil, false
+	}
+	return ranks.ranks[index], true
+}
+
+func (ranks *Ranks) index(name string) int {
+	return slices.IndexFunc(ranks.ranks, func(rank *Rank) bool { return strings.EqualFold(rank.Name, name) })
+}
+
+// Position from lowest to highest, or -1 if the rank doesn't exist
+func (ranks *Ranks) Index(name string) int {
+	ranks.mutex.RLock()
+	defer ranks.mutex.RUnlock()
+	return ranks.index(name)
+}
+
+func (ranks *Ranks) All() []*Rank {
+	ranks.mutex.RLock()
+	defer ranks.mutex.RUnlock()
+	return slices.Clone(ranks.ranks)
+}
+
+func (ranks *Ranks) HasPermission(name, node string) bool {
+	ranks.mutex.RLock()
+	defer ranks.mutex.RUnlock()
+	index := ranks.index(name)
+	for _, rank := range ranks.ranks[:index+1] {
+		for _, pattern := range rank.Permissions {
+			if MatchNode(pattern, node) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (ranks *Ranks) Save(path string) error {
+	data, err := json.MarshalIndent(ranks.All(), "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func LoadRanks(path string) (*Ranks, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var all []*Rank
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	ranks := &Ranks{}
+	if err := ranks.Set(all); err != nil {
+		return nil, err
+	}
+	return ranks, nil
+}
+
+func NewRanks() *Ranks {
+	return &Ranks{ranks: []*Rank{
+		{
+			Name:  "guest",
+			Color: chat.COLOR_GRAY,
+			Permissions: []string{
+				"command.help", "command.ping", "command.players", "command.tp", "command.tppos", "command.goto", "command.worlds",
+				"world.*.visit",
+			},
+		},
+		// Guests can only build in the guest world, if one is configured
+		{Name: "builder", Color: chat.COLOR_GREEN, Permissions: []string{"world.*.build", "command.place"}},
+		// Restricted blocks such as bedrock and liquids
+		{Name: "advbuilder", Color: chat.COLOR_DARK_GREEN, Permissions: []string{"block.*", "command.paste"}},
+		{
+			Name:        "op",
+			Color:       chat.COLOR_RED,
+			Prefix:      "[Op] ",
+			Op:          true,
+			Permissions: []string{"command.kick", "command.rank", "command.say", "command.save", "command.reload", "command.setspawn"},
+		},
+		{Name: "owner", Color: chat.COLOR_DARK_RED, Prefix: "[Owner] ", Op: true, Permissions: []string{WILDCARD}},
+	}}
+}
+
+// Rank names of players who have been given one, keyed by lowercase player name
+type PlayerRanks struct {
+	mutex   sync.RWMutex
+	players map[string]string
+}
+
+func (players *PlayerRanks) Get(name string) (string, bool) {
+	players.mutex.RLock()
+	defer players.mutex.RUnlock()
+	rank, ok := players.players[strings.ToLower(name)]
+	return rank, ok
+}
+
+func (players *PlayerRanks) Set(name, rank string) {
+	players.mutex.Lock()
+	defer players.mutex.Unlock()
+	players.players[strings.ToLower(name)] = rank
+}
+
+func (players *PlayerRanks) Save(path string) error {
+	players.mutex.RLock()
+	data, err := json.MarshalIndent(players.players, "", "\t")
+	players.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func LoadPlayerRanks(path string) (*PlayerRanks, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	players := NewPlayerRanks()
+	if err := json.Unmarshal(data, &players.players); err != nil {
+		return nil, err
+	}
+	return players, nil
+}
+
+func NewPlayerRanks() *PlayerRanks {
+	return &PlayerRanks{players: make(map[string]string)}
+}
@@ -46,6 +46,19 @@ func (registry *NamedRegistry[K, V]) UnregisterByValue(entry V) error {
 	return nil
 }
 
+func (registry *NamedRegistry[K, V]) Get(key K) (V, bool) {
+	entry, ok := registry.entries[key]
+	return entry, ok
+}
+
+func (registry *NamedRegistry[K, V]) Entries() []V {
+	entries := make([]V, 0, len(registry.entries))
+	for _, entry := range registry.entries {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
 func NewNamedRegistry[K comparable, V Named[K]]() *NamedRegistry[K, V] {
-	return &NamedRegistry[K, V]{}
+	return &NamedRegistry[K, V]{entries: make(map[K]V)}
 }
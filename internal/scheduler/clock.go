@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	// Receives the time once d has passed
+	After(d time.Duration) <-chan time.Time
+}
+
+type RealClock struct{}
+
+func (clock RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (clock RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type waiter struct {
+	due     time.Time
+	channel chan time.Time
+}
+
+// Clock which only moves when advanced, for deterministic tests
+type VirtualClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+func (clock *VirtualClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *VirtualClock) After(d time.Duration) <-chan time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	channel := make(chan time.Time, 1)
+	due := clock.now.Add(d)
+	if d <= 0 {
+		channel <- clock.now
+		return channel
+	}
+	clock.waiters = append(clock.waiters, waiter{due: due, channel: channel})
+	return channel
+}
+
+// Moves the clock forward, firing any waiters which become due
+func (clock *VirtualClock) Advance(d time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = clock.now.Add(d)
+	remaining := clock.waiters[:0]
+	for _, waiter := range clock.waiters {
+		if waiter.due.After(clock.now) {
+			remaining = append(remaining, waiter)
+			continue
+		}
+		waiter.channel <- clock.now
+	}
+	clock.waiters = remaining
+}
+
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DEFAULT_TICK_RATE = 50 * time.Millisecond
+
+// Weight of the newest tick in the average tick time
+const AVERAGE_WEIGHT = 0.05
+
+type Task struct {
+	due       time.Time
+	interval  time.Duration
+	repeating bool
+	action    func()
+	cancelled atomic.Bool
+}
+
+func (task *Task) Cancel() {
+	task.cancelled.Store(true)
+}
+
+func (task *Task) Cancelled() bool {
+	return task.cancelled.Load()
+}
+
+type Metrics struct {
+	Ticks       uint64
+	LastTick    time.Duration
+	AverageTick time.Duration
+	Overruns    uint64
+}
+
+// Runs tasks on a fixed rate tick. Tasks only run on ticks, so delays are rounded up to the tick rate
+type Scheduler struct {
+	clock    Clock
+	tickRate time.Duration
+
+	mutex   sync.Mutex
+	tasks   []*Task
+	metrics Metrics
+}
+
+func (scheduler *Scheduler) Clock() Clock {
+	return scheduler.clock
+}
+
+func (scheduler *Scheduler) TickRate() time.Duration {
+	return scheduler.tickRate
+}
+
+func (scheduler *Scheduler) add(task *Task) *Task {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	scheduler.tasks = append(scheduler.tasks, task)
+	return task
+}
+
+func (scheduler *Scheduler) Schedule(delay time.Duration, action func()) *Task {
+	return scheduler.add(&Task{due: scheduler.clock.Now().Add(delay), action: action})
+}
+
+// An interval of 0 runs the task every tick
+func (scheduler *Scheduler) ScheduleRepeating(delay time.Duration, interval time.Duration, action func()) *Task {
+	return scheduler.add(&Task{
+		due:       scheduler.clock.Now().Add(delay),
+		interval:  interval,
+		repeating: true,
+		action:    action,
+	})
+}
+
+func (scheduler *Scheduler) OnTick(action func()) *Task {
+	return scheduler.ScheduleRepeating(0, 0, action)
+}
+
+func (scheduler *Scheduler) Metrics() Metrics {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	return scheduler.metrics
+}
+
+// Runs every due task once
+func (scheduler *Scheduler) Tick() {
+	start := scheduler.clock.Now()
+
+	scheduler.mutex.Lock()
+	var due []*Task
+	scheduler.tasks = slices.DeleteFunc(scheduler.tasks, func(task *Task) bool {
+		if task.Cancelled() {
+			return true
+		}
+		if task.due.After(start) {
+			return false
+		}
+		due = append(due, task)
+		if task.repeating {
+			task.due = task.due.Add(task.interval)
+			if task.due.Before(start) {
+				task.due = start.Add(task.interval)
+			}
+			return false
+		}
+		return true
+	})
+	scheduler.mutex.Unlock()
+
+	for _, task := range due {
+		if !task.Cancelled() {
+			task.action()
+		}
+	}
+
+	elapsed := scheduler.clock.Now().Sub(start)
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	scheduler.metrics.Ticks++
+	scheduler.metrics.LastTick = elapsed
+	if scheduler.metrics.Ticks == 1 {
+		scheduler.metrics.AverageTick = elapsed
+	} else {
+		scheduler.metrics.AverageTick += time.Duration(AVERAGE_WEIGHT * float64(elapsed-scheduler.metrics.AverageTick))
+	}
+}
+
+// Ticks until the context is cancelled. Ticks which overrun push back the next one rather than being caught up
+func (scheduler *Scheduler) Run(ctx context.Context) error {
+	next := scheduler.clock.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-scheduler.clock.After(next.Sub(scheduler.clock.Now())):
+		}
+		scheduler.Tick()
+		next = next.Add(scheduler.tickRate)
+		if now := scheduler.clock.Now(); now.After(next) {
+			behind := now.Sub(next)
+			scheduler.mutex.Lock()
+			scheduler.metrics.Overruns++
+			scheduler.mutex.Unlock()
+			log.Printf("Warning: Tick overran by %v, skipping %d ticks", behind, behind/scheduler.tickRate)
+			next = now
+		}
+	}
+}
+
+func NewScheduler(clock Clock, tickRate time.Duration) *Scheduler {
+	return &Scheduler{clock: clock, tickRate: tickRate}
+}
@@ -0,0 +1,241 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestScheduler() (*Scheduler, *VirtualClock) {
+	clock := NewVirtualClock(start)
+	return NewScheduler(clock, DEFAULT_TICK_RATE), clock
+}
+
+// Advances one tick at a time, ticking after each
+func tickFor(scheduler *Scheduler, clock *VirtualClock, ticks int) {
+	for range ticks {
+		clock.Advance(scheduler.TickRate())
+		scheduler.Tick()
+	}
+}
+
+func TestScheduleRunsOnceWhenDue(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	runs := 0
+	scheduler.Schedule(120*time.Millisecond, func() { runs++ })
+	tickFor(scheduler, clock, 2)
+	if runs != 0 {
+		t.Fatalf("Ran %d times before it was due", runs)
+	}
+	// Delays are rounded up to the next tick
+	tickFor(scheduler, clock, 1)
+	if runs != 1 {
+		t.Fatalf("Expected 1 run once due, got %d", runs)
+	}
+	tickFor(scheduler, clock, 10)
+	if runs != 1 {
+		t.Fatalf("Delayed task ran %d times", runs)
+	}
+}
+
+func TestScheduleRepeating(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	var times []time.Duration
+	scheduler.ScheduleRepeating(100*time.Millisecond, 200*time.Millisecond, func() {
+		times = append(times, clock.Now().Sub(start))
+	})
+	tickFor(scheduler, clock, 12)
+	expected := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 500 * time.Millisecond}
+	if len(times) != len(expected) {
+		t.Fatalf("Expected runs at %v, got %v", expected, times)
+	}
+	for i := range expected {
+		if times[i] != expected[i] {
+			t.Fatalf("Expected runs at %v, got %v", expected, times)
+		}
+	}
+}
+
+func TestOnTickRunsEveryTick(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	runs := 0
+	scheduler.OnTick(func() { runs++ })
+	tickFor(scheduler, clock, 7)
+	if runs != 7 {
+		t.Fatalf("Expected 7 runs, got %d", runs)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	runs := 0
+	task := scheduler.OnTick(func() { runs++ })
+	tickFor(scheduler, clock, 2)
+	task.Cancel()
+	tickFor(scheduler, clock, 3)
+	if runs != 2 {
+		t.Fatalf("Expected 2 runs before cancelling, got %d", runs)
+	}
+	if !task.Cancelled() {
+		t.Fatal("Task doesn't report being cancelled")
+	}
+
+	// Cancelling a task due in the same tick stops it running
+	var second *Task
+	scheduler.OnTick(func() { second.Cancel() })
+	ran := false
+	second = scheduler.OnTick(func() { ran = true })
+	tickFor(scheduler, clock, 1)
+	if ran {
+		t.Fatal("Task cancelled earlier in the tick still ran")
+	}
+}
+
+// A repeating task which fell behind runs once, then keeps its interval from then on
+func TestRepeatingDoesNotCatchUp(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	runs := 0
+	scheduler.ScheduleRepeating(0, 100*time.Millisecond, func() { runs++ })
+	clock.Advance(time.Second)
+	scheduler.Tick()
+	scheduler.Tick()
+	if runs != 1 {
+		t.Fatalf("Expected 1 run after falling behind, got %d", runs)
+	}
+	clock.Advance(100 * time.Millisecond)
+	scheduler.Tick()
+	if runs != 2 {
+		t.Fatalf("Expected the next run an interval later, got %d runs", runs)
+	}
+}
+
+func TestTasksScheduledDuringTick(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	runs := 0
+	scheduler.Schedule(0, func() {
+		scheduler.Schedule(0, func() { runs++ })
+	})
+	tickFor(scheduler, clock, 1)
+	if runs != 0 {
+		t.Fatal("Task scheduled during a tick ran in the same tick")
+	}
+	tickFor(scheduler, clock, 1)
+	if runs != 1 {
+		t.Fatalf("Expected the new task to run on the next tick, got %d runs", runs)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	scheduler.OnTick(func() { clock.Advance(10 * time.Millisecond) })
+	tickFor(scheduler, clock, 3)
+	metrics := scheduler.Metrics()
+	if metrics.Ticks != 3 {
+		t.Fatalf("Expected 3 ticks, got %d", metrics.Ticks)
+	}
+	if metrics.LastTick != 10*time.Millisecond || metrics.AverageTick != 10*time.Millisecond {
+		t.Fatalf("Expected 10ms ticks, got last %v and average %v", metrics.LastTick, metrics.AverageTick)
+	}
+}
+
+// Blocks until Run is waiting on the clock, so advancing it fires the next tick
+func waitForWaiter(t *testing.T, clock *VirtualClock) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		clock.mutex.Lock()
+		waiting := len(clock.waiters) > 0
+		clock.mutex.Unlock()
+		if waiting {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("Scheduler never waited on the clock")
+}
+
+func TestRunTicksAtFixedRate(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	ticks := make(chan time.Time, 16)
+	scheduler.OnTick(func() { ticks <- clock.Now() })
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- scheduler.Run(ctx) }()
+
+	if first := <-ticks; !first.Equal(start) {
+		t.Fatalf("First tick at %v, expected immediately", first.Sub(start))
+	}
+	for i := 1; i <= 3; i++ {
+		waitForWaiter(t, clock)
+		clock.Advance(DEFAULT_TICK_RATE)
+		if tick := <-ticks; tick.Sub(start) != time.Duration(i)*DEFAULT_TICK_RATE {
+			t.Fatalf("Tick %d at %v", i, tick.Sub(start))
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if overruns := scheduler.Metrics().Overruns; overruns != 0 {
+		t.Fatalf("Expected no overruns, got %d", overruns)
+	}
+}
+
+func TestRunCountsOverruns(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	ticked := make(chan struct{}, 16)
+	slow := true
+	scheduler.OnTick(func() {
+		if slow {
+			slow = false
+			clock.Advance(3 * DEFAULT_TICK_RATE)
+		}
+		ticked <- struct{}{}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- scheduler.Run(ctx) }()
+	<-ticked
+	// The overrun runs one tick straight away rather than all the missed ones
+	<-ticked
+	select {
+	case <-ticked:
+		t.Fatal("Missed ticks were caught up")
+	case <-time.After(20 * time.Millisecond):
+	}
+	waitForWaiter(t, clock)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if overruns := scheduler.Metrics().Overruns; overruns != 1 {
+		t.Fatalf("Expected 1 overrun, got %d", overruns)
+	}
+}
+
+func TestVirtualClockAfter(t *testing.T) {
+	clock := NewVirtualClock(start)
+	fired := clock.After(time.Second)
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-fired:
+		t.Fatal("Fired early")
+	default:
+	}
+	clock.Advance(time.Millisecond)
+	select {
+	case now := <-fired:
+		if now.Sub(start) != time.Second {
+			t.Fatalf("Fired with time %v", now.Sub(start))
+		}
+	default:
+		t.Fatal("Didn't fire once due")
+	}
+	select {
+	case <-clock.After(0):
+	default:
+		t.Fatal("A zero wait should fire immediately")
+	}
+}
@@ -0,0 +1,101 @@
+package servercontext
+
+import (
+	"encoding/json"
+	"net/netip"
+	"os"
+	"slices"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+)
+
+const (
+	CONFIG_INVALID_NETWORK = iota
+)
+
+const (
+	CHAT_SCOPE_GLOBAL = iota
+	CHAT_SCOPE_LEVEL
+)
+
+var DEFAULT_HEARTBEAT_URLS = []string{"https://www.classicube.net/server/heartbeat/"}
+
+type Config struct {
+	Name string `json:"name"`
+	Motd string `json:"motd"`
+	// Require the key server lists give players, derived from the salt and their name
+	VerifyNames bool `json:"verify_names"`
+	// CIDR ranges which can join as anyone without a key, such as 192.168.0.0/16 for the LAN, and none by default.
+	// Behind a proxy, tunnel or NAT every player comes from its address, so trusting that address trusts everyone
+	TrustedNetworks []string `json:"trusted_networks"`
+	trusted         []netip.Prefix
+	// Whether chat reaches everyone or only those in the sender's level
+	ChatScope  int `json:"chat_scope"`
+	MaxPlayers int `json:"max_players"`
+	// Whether server lists show the server to everyone
+	Public bool `json:"public"`
+	// Server lists to send heartbeats to. Empty to not be listed at all
+	HeartbeatURLs []string `json:"heartbeat_urls"`
+	// World players join, loaded from the levels directory
+	MainWorld string `json:"main_world"`
+	// World anyone can build in, whatever their rank. Empty for none
+	GuestWorld string `json:"guest_world"`
+	// How long a world can be empty before it's saved and unloaded. 0 keeps worlds loaded
+	IdleUnloadSeconds int `json:"idle_unload_seconds"`
+}
+
+func (config *Config) parseNetworks() error {
+	config.trusted = config.trusted[:0]
+	for _, network := range config.TrustedNetworks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return cerror.NewErrorf(CONFIG_INVALID_NETWORK, "Invalid trusted network %s: %v", network, err)
+		}
+		config.trusted = append(config.trusted, prefix.Masked())
+	}
+	return nil
+}
+
+func (config *Config) Trusted(address netip.Addr) bool {
+	address = address.Unmap()
+	for _, prefix := range config.trusted {
+		if prefix.Contains(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing fields keep their defaults
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := NewConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	if err := config.parseNetworks(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func NewConfig() *Config {
+	config := &Config{
+		Name:              SOFTWARE,
+		Motd:              "Where we're going, we don't need a motd.",
+		VerifyNames:       true,
+		TrustedNetworks:   []string{},
+		MaxPlayers:        64,
+		Public:            true,
+		HeartbeatURLs:     slices.Clone(DEFAULT_HEARTBEAT_URLS),
+		MainWorld:         DEFAULT_LEVEL_NAME,
+		IdleUnloadSeconds: 600,
+	}
+	if err := config.parseNetworks(); err != nil {
+		panic(err)
+	}
+	return config
+}
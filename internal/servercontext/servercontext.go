@@ -0,0 +1,168 @@
+package servercontext
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync/atomic"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/auth"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/chat"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/formats"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/hotkeys"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/particles"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/ranks"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/scheduler"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+const SOFTWARE = "Burrowing Classic"
+
+const (
+	CONFIG_FILE       = "config.json"
+	PARTICLES_FILE    = "particles.json"
+	HOTKEYS_FILE      = "hotkeys.json"
+	TEXT_COLORS_FILE  = "textcolors.json"
+	RANKS_FILE        = "ranks.json"
+	PLAYER_RANKS_FILE = "playerranks.json"
+	PALETTE_FILE      = "palette.json"
+)
+
+// Where the main world's behaviors and settings were kept before each world had its own
+const (
+	LEGACY_BEHAVIORS_FILE      = "behaviors.json"
+	LEGACY_LEVEL_SETTINGS_FILE = "level.json"
+)
+
+const LEVELS_DIRECTORY = "levels"
+
+const SCHEMATICS_DIRECTORY = "schematics"
+
+const DEFAULT_LEVEL_NAME = "main"
+
+const (
+	DEFAULT_LEVEL_WIDTH  = 128
+	DEFAULT_LEVEL_HEIGHT = 64
+	DEFAULT_LEVEL_LENGTH = 128
+)
+
+type ServerContext struct {
+	// Generated each run, and shared with server lists to verify names
+	Salt      string
+	Worlds    *Worlds
+	Scheduler *scheduler.Scheduler
+
+	// Replaced whole when reloaded, so readers get a consistent snapshot without locking
+	config      atomic.Pointer[Config]
+	particles   atomic.Pointer[particles.Registry]
+	hotKeys     atomic.Pointer[[]hotkeys.HotKey]
+	textColors  atomic.Pointer[chat.TextColors]
+	ranks       atomic.Pointer[ranks.Ranks]
+	playerRanks atomic.Pointer[ranks.PlayerRanks]
+	// Maps schematic block ids onto Classic blocks
+	palette atomic.Pointer[formats.Palette]
+}
+
+// Must not be modified, as it's shared by everything reading it
+func (context *ServerContext) Config() *Config {
+	return context.config.Load()
+}
+
+func (context *ServerContext) Particles() *particles.Registry {
+	return context.particles.Load()
+}
+
+func (context *ServerContext) HotKeys() []hotkeys.HotKey {
+	return *context.hotKeys.Load()
+}
+
+func (context *ServerContext) TextColors() *chat.TextColors {
+	return context.textColors.Load()
+}
+
+func (context *ServerContext) Ranks() *ranks.Ranks {
+	return context.ranks.Load()
+}
+
+func (context *ServerContext) PlayerRanks() *ranks.PlayerRanks {
+	return context.playerRanks.Load()
+}
+
+func (context *ServerContext) Palette() *formats.Palette {
+	return context.palette.Load()
+}
+
+// Missing files leave the current value in place
+func loadFile[T any](path string, load func(string) (T, error), set func(T)) {
+	value, err := load(path)
+	if err == nil {
+		set(value)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to load %s: %v", path, err)
+	}
+}
+
+// Switches to the configured main world, loading it from the levels directory if it has been saved. Only safe before the server starts
+func (context *ServerContext) LoadWorlds() {
+	name := context.Config().MainWorld
+	if !ValidWorldName(name) {
+		log.Printf("Invalid main world name %s, using %s", name, DEFAULT_LEVEL_NAME)
+		name = DEFAULT_LEVEL_NAME
+	}
+	if context.Worlds.Main().Name() != name {
+		level, err := world.NewFlatLevel(name, DEFAULT_LEVEL_WIDTH, DEFAULT_LEVEL_HEIGHT, DEFAULT_LEVEL_LENGTH)
+		if err != nil {
+			panic(err)
+		}
+		context.Worlds = NewWorlds(LEVELS_DIRECTORY, level, nil)
+	}
+	context.Worlds.LoadMain(name)
+	main := context.Worlds.Main()
+	if _, err := os.Stat(context.Worlds.path(main.Name(), WORLD_BEHAVIORS_SUFFIX)); errors.Is(err, os.ErrNotExist) {
+		loadFile(LEGACY_BEHAVIORS_FILE, world.LoadBehaviors, main.SetBehaviors)
+	}
+	if _, err := os.Stat(context.Worlds.path(main.Name(), WORLD_SETTINGS_SUFFIX)); errors.Is(err, os.ErrNotExist) {
+		loadFile(LEGACY_LEVEL_SETTINGS_FILE, world.LoadSettings, main.SetSettings)
+	}
+}
+
+// Safe to call while the server is running, as each setting is swapped in whole
+func (context *ServerContext) LoadFiles() {
+	loadFile(CONFIG_FILE, LoadConfig, context.config.Store)
+	loadFile(PARTICLES_FILE, particles.Load, context.particles.Store)
+	loadFile(HOTKEYS_FILE, hotkeys.Load, func(hotKeys []hotkeys.HotKey) { context.hotKeys.Store(&hotKeys) })
+	loadFile(TEXT_COLORS_FILE, chat.LoadTextColors, context.textColors.Store)
+	loadFile(RANKS_FILE, ranks.LoadRanks, context.ranks.Store)
+	loadFile(PLAYER_RANKS_FILE, ranks.LoadPlayerRanks, context.playerRanks.Store)
+	loadFile(PALETTE_FILE, formats.LoadPalette, context.palette.Store)
+	context.Worlds.LoadSettings()
+}
+
+// Writes out everything LoadFiles reads which can be changed while running
+func (context *ServerContext) SaveFiles() error {
+	return errors.Join(
+		context.Worlds.SaveAll(),
+		context.TextColors().Save(TEXT_COLORS_FILE),
+		context.PlayerRanks().Save(PLAYER_RANKS_FILE),
+	)
+}
+
+func DefaultServerContext() *ServerContext {
+	level, err := world.NewFlatLevel(DEFAULT_LEVEL_NAME, DEFAULT_LEVEL_WIDTH, DEFAULT_LEVEL_HEIGHT, DEFAULT_LEVEL_LENGTH)
+	if err != nil {
+		panic(err)
+	}
+	context := &ServerContext{
+		Salt:      auth.GenerateSalt(),
+		Worlds:    NewWorlds(LEVELS_DIRECTORY, level, nil),
+		Scheduler: scheduler.NewScheduler(scheduler.RealClock{}, scheduler.DEFAULT_TICK_RATE),
+	}
+	context.config.Store(NewConfig())
+	context.particles.Store(particles.NewRegistry())
+	context.hotKeys.Store(&[]hotkeys.HotKey{})
+	context.textColors.Store(chat.NewTextColors())
+	context.ranks.Store(ranks.NewRanks())
+	context.playerRanks.Store(ranks.NewPlayerRanks())
+	context.palette.Store(formats.NewPalette())
+	return context
+}
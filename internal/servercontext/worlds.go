@@ -0,0 +1,294 @@
+package servercontext
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+const (
+	WORLD_BEHAVIORS_SUFFIX = ".behaviors.json"
+	WORLD_SETTINGS_SUFFIX  = ".settings.json"
+)
+
+const (
+	WORLDS_NOT_FOUND = iota
+	WORLDS_INVALID_NAME
+)
+
+// Names become file names, so are kept to characters which are safe in paths
+var worldNamePattern = regexp.MustCompile(`^[A-Za-z0-9_\-]{1,64}$`)
+
+func ValidWorldName(name string) bool {
+	return worldNamePattern.MatchString(name)
+}
+
+type loadedWorld struct {
+	level    *world.Level
+	lastUsed time.Time
+}
+
+// Worlds loaded from a directory on demand. The main world, where players join, is never unloaded
+type Worlds struct {
+	mutex sync.Mutex
+	// Held while unloading, so slow saves don't overlap with the next check
+	unloading sync.Mutex
+	directory string
+	main      *world.Level
+	// Keyed by lowercase name, including the main world
+	loaded map[string]*loadedWorld
+	now    func() time.Time
+}
+
+func (worlds *Worlds) path(name, suffix string) string {
+	return filepath.Join(worlds.directory, name+suffix)
+}
+
+func (worlds *Worlds) Main() *world.Level {
+	worlds.mutex.Lock()
+	defer worlds.mutex.Unlock()
+	return worlds.main
+}
+
+// Loaded worlds, sorted by name
+func (worlds *Worlds) Loaded() []*world.Level {
+	worlds.mutex.Lock()
+	defer worlds.mutex.Unlock()
+	levels := make([]*world.Level, 0, len(worlds.loaded))
+	for _, loaded := range worlds.loaded {
+		levels = append(levels, loaded.level)
+	}
+	slices.SortFunc(levels, func(a, b *world.Level) int {
+		return strings.Compare(strings.ToLower(a.Name()), strings.ToLower(b.Name()))
+	})
+	return levels
+}
+
+func (worlds *Worlds) IsLoaded(name string) bool {
+	worlds.mutex.Lock()
+	defer worlds.mutex.Unlock()
+	_, ok := worlds.loaded[strings.ToLower(name)]
+	return ok
+}
+
+// Names of every world, loaded or saved in the directory, sorted
+func (worlds *Worlds) Available() ([]string, error) {
+	entries, err := os.ReadDir(worlds.directory)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, level := range worlds.Loaded() {
+		seen[strings.ToLower(level.Name())] = true
+		names = append(names, level.Name())
+	}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), world.CLASSICWORLD_EXTENSION)
+		if !ok || entry.IsDir() || !ValidWorldName(name) || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	return names, nil
+}
+
+// Saved file name matching the world name, ignoring case
+func (worlds *Worlds) find(name string) (string, error) {
+	if !ValidWorldName(name) {
+		return "", cerror.NewErrorf(WORLDS_INVALID_NAME, "Invalid world name %s", name)
+	}
+	entries, err := os.ReadDir(worlds.directory)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	for _, entry := range entries {
+		saved, ok := strings.CutSuffix(entry.Name(), world.CLASSICWORLD_EXTENSION)
+		if ok && !entry.IsDir() && strings.EqualFold(saved, name) {
+			return saved, nil
+		}
+	}
+	return "", cerror.NewErrorf(WORLDS_NOT_FOUND, "World %s does not exist", name)
+}
+
+// Behaviors and settings are optional, so a world can be dropped in as a single file
+func (worlds *Worlds) loadSettings(level *world.Level) {
+	loadFile(worlds.path(level.Name(), WORLD_BEHAVIORS_SUFFIX), world.LoadBehaviors, level.SetBehaviors)
+	loadFile(worlds.path(level.Name(), WORLD_SETTINGS_SUFFIX), world.LoadSettings, func(settings *world.Settings) {
+		// Settings saved without an environment keep the one read from the world file
+		if settings.Env == nil {
+			settings.Env = level.Settings().Environment()
+		}
+		level.SetSettings(settings)
+	})
+}
+
+// Reads a saved world and its settings. Failures other than the world not existing are also logged
+func (worlds *Worlds) load(name string) (*world.Level, error) {
+	saved, err := worlds.find(name)
+	if err != nil {
+		return nil, err
+	}
+	level, err := world.LoadClassicWorld(worlds.path(saved, world.CLASSICWORLD_EXTENSION))
+	if err != nil {
+		log.Printf("Failed to load world %s: %v", saved, err)
+		return nil, err
+	}
+	worlds.loadSettings(level)
+	log.Printf("Loaded world %s", level.Name())
+	return level, nil
+}
+
+// Marks a loaded world as used. Must hold the mutex
+func (worlds *Worlds) use(name string) (*world.Level, bool) {
+	loaded, ok := worlds.loaded[strings.ToLower(name)]
+	if !ok {
+		return nil, false
+	}
+	loaded.lastUsed = worlds.now()
+	return loaded.level, true
+}
+
+// Loads the world from the directory if it isn't already, and marks it as used. Reading happens outside the lock so other worlds aren't held up
+func (worlds *Worlds) Get(name string) (*world.Level, error) {
+	worlds.mutex.Lock()
+	level, ok := worlds.use(name)
+	worlds.mutex.Unlock()
+	if ok {
+		return level, nil
+	}
+	level, err := worlds.load(name)
+	if err != nil {
+		return nil, err
+	}
+	worlds.mutex.Lock()
+	defer worlds.mutex.Unlock()
+	// Whoever finished loading first wins, so everyone shares one copy
+	if existing, ok := worlds.use(level.Name()); ok {
+		return existing, nil
+	}
+	worlds.loaded[strings.ToLower(level.Name())] = &loadedWorld{level: level, lastUsed: worlds.now()}
+	return level, nil
+}
+
+// Keeps a world from being unloaded for another timeout
+func (worlds *Worlds) Touch(level *world.Level) {
+	worlds.mutex.Lock()
+	defer worlds.mutex.Unlock()
+	if loaded, ok := worlds.loaded[strings.ToLower(level.Name())]; ok && loaded.level == level {
+		loaded.lastUsed = worlds.now()
+	}
+}
+
+func (worlds *Worlds) Save(level *world.Level) error {
+	if err := os.MkdirAll(worlds.directory, 0755); err != nil {
+		return err
+	}
+	return errors.Join(
+		level.SaveClassicWorld(worlds.path(level.Name(), world.CLASSICWORLD_EXTENSION)),
+		level.Behaviors().Save(worlds.path(level.Name(), WORLD_BEHAVIORS_SUFFIX)),
+		level.Settings().Save(worlds.path(level.Name(), WORLD_SETTINGS_SUFFIX)),
+	)
+}
+
+// Only the settings, for changes made while the world is in use
+func (worlds *Worlds) SaveSettings(level *world.Level) error {
+	if err := os.MkdirAll(worlds.directory, 0755); err != nil {
+		return err
+	}
+	return level.Settings().Save(worlds.path(level.Name(), WORLD_SETTINGS_SUFFIX))
+}
+
+func (worlds *Worlds) SaveAll() error {
+	var errs []error
+	for _, level := range worlds.Loaded() {
+		errs = append(errs, worlds.Save(level))
+	}
+	return errors.Join(errs...)
+}
+
+func (worlds *Worlds) idle(level *world.Level, timeout time.Duration) bool {
+	loaded, ok := worlds.loaded[strings.ToLower(level.Name())]
+	return ok && loaded.level == level && worlds.now().Sub(loaded.lastUsed) >= timeout
+}
+
+// Saves and unloads worlds unused for longer than the timeout. Worlds inUse reports as occupied count as used now
+func (worlds *Worlds) UnloadIdle(timeout time.Duration, inUse func(level *world.Level) bool) {
+	if !worlds.unloading.TryLock() {
+		return
+	}
+	defer worlds.unloading.Unlock()
+	main := worlds.Main()
+	for _, level := range worlds.Loaded() {
+		if level == main {
+			continue
+		}
+		if inUse(level) {
+			worlds.Touch(level)
+			continue
+		}
+		worlds.mutex.Lock()
+		idle := worlds.idle(level, timeout)
+		worlds.mutex.Unlock()
+		if !idle {
+			continue
+		}
+		if err := worlds.Save(level); err != nil {
+			log.Printf("Failed to save world %s, so it was kept loaded: %v", level.Name(), err)
+			continue
+		}
+		// Someone may have gone to the world while it was saving
+		worlds.mutex.Lock()
+		if worlds.idle(level, timeout) && !inUse(level) {
+			delete(worlds.loaded, strings.ToLower(level.Name()))
+			log.Printf("Unloaded world %s", level.Name())
+		}
+		worlds.mutex.Unlock()
+	}
+}
+
+// Rereads behaviors and settings of every loaded world
+func (worlds *Worlds) LoadSettings() {
+	for _, level := range worlds.Loaded() {
+		worlds.loadSettings(level)
+	}
+}
+
+// Replaces the main world with the saved one of the given name, if there is one. Only safe before the server starts
+func (worlds *Worlds) LoadMain(name string) {
+	level, err := worlds.load(name)
+	if err != nil {
+		return
+	}
+	worlds.mutex.Lock()
+	defer worlds.mutex.Unlock()
+	delete(worlds.loaded, strings.ToLower(worlds.main.Name()))
+	worlds.main = level
+	worlds.loaded[strings.ToLower(level.Name())] = &loadedWorld{level: level, lastUsed: worlds.now()}
+}
+
+// The clock is used to time idle worlds, and may be nil for the real one
+func NewWorlds(directory string, main *world.Level, now func() time.Time) *Worlds {
+	if now == nil {
+		now = time.Now
+	}
+	return &Worlds{
+		directory: directory,
+		main:      main,
+		loaded:    map[string]*loadedWorld{strings.ToLower(main.Name()): {level: main, lastUsed: now()}},
+		now:       now,
+	}
+}
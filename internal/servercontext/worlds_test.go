@@ -0,0 +1,180 @@
+package servercontext
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/world"
+)
+
+type testClock struct {
+	mutex sync.Mutex
+	time  time.Time
+}
+
+func (clock *testClock) now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.time
+}
+
+func (clock *testClock) advance(duration time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.time = clock.time.Add(duration)
+}
+
+func newTestLevel(t *testing.T, name string) *world.Level {
+	t.Helper()
+	level, err := world.NewLevelFromBlocks(name, 4, 4, 4, make([]byte, 4*4*4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return level
+}
+
+// Worlds with a main world and a saved world named Other
+func newTestWorlds(t *testing.T) (*Worlds, *testClock) {
+	t.Helper()
+	clock := &testClock{time: time.Unix(1000, 0)}
+	worlds := NewWorlds(t.TempDir(), newTestLevel(t, "main"), clock.now)
+	if err := worlds.Save(newTestLevel(t, "Other")); err != nil {
+		t.Fatal(err)
+	}
+	return worlds, clock
+}
+
+func notInUse(level *world.Level) bool {
+	return false
+}
+
+func TestWorldsGet(t *testing.T) {
+	worlds, _ := newTestWorlds(t)
+	if worlds.IsLoaded("other") {
+		t.Fatal("World was loaded before it was used")
+	}
+	level, err := worlds.Get("OTHER")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level.Name() != "Other" {
+		t.Fatalf("Expected the saved name Other, got %s", level.Name())
+	}
+	if again, err := worlds.Get("other"); err != nil || again != level {
+		t.Fatalf("Loaded a second copy: %v", err)
+	}
+	if main, err := worlds.Get("Main"); err != nil || main != worlds.Main() {
+		t.Fatalf("Main world wasn't returned: %v", err)
+	}
+	for _, name := range []string{"missing", "../other", ""} {
+		if _, err := worlds.Get(name); err == nil {
+			t.Errorf("Got world %q", name)
+		}
+	}
+}
+
+func TestWorldsGetConcurrent(t *testing.T) {
+	worlds, _ := newTestWorlds(t)
+	levels := make([]*world.Level, 8)
+	var group sync.WaitGroup
+	for i := range levels {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			level, err := worlds.Get("other")
+			if err != nil {
+				t.Error(err)
+			}
+			levels[i] = level
+		}()
+	}
+	group.Wait()
+	for _, level := range levels {
+		if level != levels[0] {
+			t.Fatal("Concurrent loads returned different copies of the world")
+		}
+	}
+}
+
+func TestWorldsUnloadIdle(t *testing.T) {
+	worlds, clock := newTestWorlds(t)
+	level, err := worlds.Get("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	level.SetBlock(0, 0, 0, world.BLOCK_GOLD)
+
+	clock.advance(time.Minute - time.Second)
+	worlds.UnloadIdle(time.Minute, notInUse)
+	if !worlds.IsLoaded("other") {
+		t.Fatal("Unloaded a world before the timeout")
+	}
+
+	clock.advance(time.Hour)
+	worlds.UnloadIdle(time.Minute, notInUse)
+	if worlds.IsLoaded("other") {
+		t.Fatal("Idle world wasn't unloaded")
+	}
+	if !worlds.IsLoaded("main") {
+		t.Fatal("Main world was unloaded")
+	}
+	if _, err := os.Stat(filepath.Join(worlds.directory, "Other"+WORLD_SETTINGS_SUFFIX)); err != nil {
+		t.Fatalf("World wasn't saved before unloading: %v", err)
+	}
+	reloaded, err := worlds.Get("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded == level {
+		t.Fatal("Unloaded world was still cached")
+	}
+	if block, _ := reloaded.GetBlock(0, 0, 0); block != world.BLOCK_GOLD {
+		t.Fatalf("Changes were lost when unloading, got block %d", block)
+	}
+}
+
+// Players travelling to a world keep it loaded, and it only times out once they leave
+func TestWorldsTravel(t *testing.T) {
+	worlds, clock := newTestWorlds(t)
+	level, err := worlds.Get("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	occupied := true
+	inUse := func(candidate *world.Level) bool {
+		return candidate == level && occupied
+	}
+
+	clock.advance(time.Hour)
+	worlds.UnloadIdle(time.Minute, inUse)
+	if !worlds.IsLoaded("other") {
+		t.Fatal("Unloaded a world with players in it")
+	}
+
+	occupied = false
+	clock.advance(time.Minute - time.Second)
+	worlds.UnloadIdle(time.Minute, inUse)
+	if !worlds.IsLoaded("other") {
+		t.Fatal("Timeout didn't restart when the last player left")
+	}
+
+	// Going back to the world counts as using it
+	if again, err := worlds.Get("other"); err != nil || again != level {
+		t.Fatalf("Travelling back loaded a second copy: %v", err)
+	}
+	clock.advance(time.Minute - time.Second)
+	worlds.UnloadIdle(time.Minute, inUse)
+	if !worlds.IsLoaded("other") {
+		t.Fatal("Travelling to the world didn't keep it loaded")
+	}
+
+	worlds.Touch(level)
+	clock.advance(time.Minute)
+	worlds.UnloadIdle(time.Minute, inUse)
+	if worlds.IsLoaded("other") {
+		t.Fatal("World stayed loaded after everyone left")
+	}
+}
@@ -0,0 +1,123 @@
+package world
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	VELOCITY_MODE_ADD = iota
+	VELOCITY_MODE_SET
+)
+
+type BlockPos struct {
+	X int16 `json:"x"`
+	Y int16 `json:"y"`
+	Z int16 `json:"z"`
+}
+
+type Position struct {
+	X     float32 `json:"x"`
+	Y     float32 `json:"y"`
+	Z     float32 `json:"z"`
+	Yaw   byte    `json:"yaw"`
+	Pitch byte    `json:"pitch"`
+}
+
+type LaunchPad struct {
+	X     float32 `json:"x"`
+	Y     float32 `json:"y"`
+	Z     float32 `json:"z"`
+	XMode byte    `json:"x_mode"`
+	YMode byte    `json:"y_mode"`
+	ZMode byte    `json:"z_mode"`
+	// Where to teleport clients without VelocityControl. Nil leaves them in place
+	Fallback *Position `json:"fallback,omitempty"`
+}
+
+type Behavior struct {
+	LaunchPad     *LaunchPad `json:"launch_pad,omitempty"`
+	Checkpoint    *Position  `json:"checkpoint,omitempty"`
+	ClickDistance *float32   `json:"click_distance,omitempty"`
+}
+
+type Zone struct {
+	Name     string   `json:"name"`
+	Min      BlockPos `json:"min"`
+	Max      BlockPos `json:"max"`
+	Behavior Behavior `json:"behavior"`
+	// Only builders may change blocks inside a protected zone
+	Protected bool     `json:"protected"`
+	Builders  []string `json:"builders,omitempty"`
+}
+
+func (zone *Zone) Contains(x, y, z int16) bool {
+	return x >= zone.Min.X && x <= zone.Max.X &&
+		y >= zone.Min.Y && y <= zone.Max.Y &&
+		z >= zone.Min.Z && z <= zone.Max.Z
+}
+
+// Names are case insensitive, like everywhere else players are looked up
+func (zone *Zone) CanBuild(name string) bool {
+	return !zone.Protected || slices.ContainsFunc(zone.Builders, func(builder string) bool {
+		return strings.EqualFold(builder, name)
+	})
+}
+
+type Behaviors struct {
+	// Reach applied to everyone on join
+	ClickDistance *float32 `json:"click_distance,omitempty"`
+	Zones         []Zone   `json:"zones"`
+	// Triggered when standing on top of the block type
+	Blocks map[byte]Behavior `json:"blocks"`
+}
+
+// Returns every behavior triggered at the given block position, where below is the block type underneath
+func (behaviors *Behaviors) At(x, y, z int16, below byte) []Behavior {
+	var triggered []Behavior
+	if behavior, ok := behaviors.Blocks[below]; ok {
+		triggered = append(triggered, behavior)
+	}
+	for i := range behaviors.Zones {
+		if behaviors.Zones[i].Contains(x, y, z) {
+			triggered = append(triggered, behaviors.Zones[i].Behavior)
+		}
+	}
+	return triggered
+}
+
+func (behaviors *Behaviors) ZonesAt(x, y, z int16) []*Zone {
+	var zones []*Zone
+	for i := range behaviors.Zones {
+		if behaviors.Zones[i].Contains(x, y, z) {
+			zones = append(zones, &behaviors.Zones[i])
+		}
+	}
+	return zones
+}
+
+func (behaviors *Behaviors) Save(path string) error {
+	data, err := json.MarshalIndent(behaviors, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func LoadBehaviors(path string) (*Behaviors, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	behaviors := NewBehaviors()
+	if err := json.Unmarshal(data, behaviors); err != nil {
+		return nil, err
+	}
+	return behaviors, nil
+}
+
+func NewBehaviors() *Behaviors {
+	return &Behaviors{Blocks: make(map[byte]Behavior)}
+}
@@ -0,0 +1,19 @@
+package world
+
+import "testing"
+
+func TestZoneCanBuild(t *testing.T) {
+	zone := Zone{Name: "spawn", Protected: true, Builders: []string{"Builder"}}
+	for _, name := range []string{"Builder", "builder", "BUILDER"} {
+		if !zone.CanBuild(name) {
+			t.Errorf("%s can't build in the zone", name)
+		}
+	}
+	if zone.CanBuild("Griefer") {
+		t.Error("Someone who isn't a builder can build in a protected zone")
+	}
+	zone.Protected = false
+	if !zone.CanBuild("Griefer") {
+		t.Error("Unprotected zone only allows builders")
+	}
+}
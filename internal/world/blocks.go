@@ -0,0 +1,205 @@
+package world
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	BLOCK_AIR = iota
+	BLOCK_STONE
+	BLOCK_GRASS
+	BLOCK_DIRT
+	BLOCK_COBBLESTONE
+	BLOCK_PLANKS
+	BLOCK_SAPLING
+	BLOCK_BEDROCK
+	BLOCK_FLOWING_WATER
+	BLOCK_STATIONARY_WATER
+	BLOCK_FLOWING_LAVA
+	BLOCK_STATIONARY_LAVA
+	BLOCK_SAND
+	BLOCK_GRAVEL
+	BLOCK_GOLD_ORE
+	BLOCK_IRON_ORE
+	BLOCK_COAL_ORE
+	BLOCK_WOOD
+	BLOCK_LEAVES
+	BLOCK_SPONGE
+	BLOCK_GLASS
+	BLOCK_RED_CLOTH
+	BLOCK_ORANGE_CLOTH
+	BLOCK_YELLOW_CLOTH
+	BLOCK_CHARTREUSE_CLOTH
+	BLOCK_GREEN_CLOTH
+	BLOCK_SPRING_GREEN_CLOTH
+	BLOCK_CYAN_CLOTH
+	BLOCK_CAPRI_CLOTH
+	BLOCK_ULTRAMARINE_CLOTH
+	BLOCK_VIOLET_CLOTH
+	BLOCK_PURPLE_CLOTH
+	BLOCK_MAGENTA_CLOTH
+	BLOCK_ROSE_CLOTH
+	BLOCK_DARK_GRAY_CLOTH
+	BLOCK_LIGHT_GRAY_CLOTH
+	BLOCK_WHITE_CLOTH
+	BLOCK_DANDELION
+	BLOCK_ROSE
+	BLOCK_BROWN_MUSHROOM
+	BLOCK_RED_MUSHROOM
+	BLOCK_GOLD
+	BLOCK_IRON
+	BLOCK_DOUBLE_SLAB
+	BLOCK_SLAB
+	BLOCK_BRICK
+	BLOCK_TNT
+	BLOCK_BOOKSHELF
+	BLOCK_MOSSY_COBBLESTONE
+	BLOCK_OBSIDIAN
+	// Added by the CustomBlocks extension
+	BLOCK_COBBLESTONE_SLAB
+	BLOCK_ROPE
+	BLOCK_SANDSTONE
+	BLOCK_SNOW
+	BLOCK_FIRE
+	BLOCK_LIGHT_PINK_CLOTH
+	BLOCK_FOREST_GREEN_CLOTH
+	BLOCK_BROWN_CLOTH
+	BLOCK_DEEP_BLUE_CLOTH
+	BLOCK_TURQUOISE_CLOTH
+	BLOCK_ICE
+	BLOCK_CERAMIC_TILE
+	BLOCK_MAGMA
+	BLOCK_PILLAR
+	BLOCK_CRATE
+	BLOCK_STONE_BRICK
+	BLOCK_COUNT
+)
+
+// Blocks below this are known to every client
+const CLASSIC_BLOCK_COUNT = BLOCK_COBBLESTONE_SLAB
+
+// Blocks which can't be placed or broken without extra permissions
+var RESTRICTED_BLOCKS = []byte{
+	BLOCK_BEDROCK,
+	BLOCK_FLOWING_WATER,
+	BLOCK_STATIONARY_WATER,
+	BLOCK_FLOWING_LAVA,
+	BLOCK_STATIONARY_LAVA,
+}
+
+var BLOCK_NAMES = [BLOCK_COUNT]string{
+	BLOCK_AIR:                "air",
+	BLOCK_STONE:              "stone",
+	BLOCK_GRASS:              "grass",
+	BLOCK_DIRT:               "dirt",
+	BLOCK_COBBLESTONE:        "cobblestone",
+	BLOCK_PLANKS:             "planks",
+	BLOCK_SAPLING:            "sapling",
+	BLOCK_BEDROCK:            "bedrock",
+	BLOCK_FLOWING_WATER:      "flowing_water",
+	BLOCK_STATIONARY_WATER:   "stationary_water",
+	BLOCK_FLOWING_LAVA:       "flowing_lava",
+	BLOCK_STATIONARY_LAVA:    "stationary_lava",
+	BLOCK_SAND:               "sand",
+	BLOCK_GRAVEL:             "gravel",
+	BLOCK_GOLD_ORE:           "gold_ore",
+	BLOCK_IRON_ORE:           "iron_ore",
+	BLOCK_COAL_ORE:           "coal_ore",
+	BLOCK_WOOD:               "wood",
+	BLOCK_LEAVES:             "leaves",
+	BLOCK_SPONGE:             "sponge",
+	BLOCK_GLASS:              "glass",
+	BLOCK_RED_CLOTH:          "red_cloth",
+	BLOCK_ORANGE_CLOTH:       "orange_cloth",
+	BLOCK_YELLOW_CLOTH:       "yellow_cloth",
+	BLOCK_CHARTREUSE_CLOTH:   "chartreuse_cloth",
+	BLOCK_GREEN_CLOTH:        "green_cloth",
+	BLOCK_SPRING_GREEN_CLOTH: "spring_green_cloth",
+	BLOCK_CYAN_CLOTH:         "cyan_cloth",
+	BLOCK_CAPRI_CLOTH:        "capri_cloth",
+	BLOCK_ULTRAMARINE_CLOTH:  "ultramarine_cloth",
+	BLOCK_VIOLET_CLOTH:       "violet_cloth",
+	BLOCK_PURPLE_CLOTH:       "purple_cloth",
+	BLOCK_MAGENTA_CLOTH:      "magenta_cloth",
+	BLOCK_ROSE_CLOTH:         "rose_cloth",
+	BLOCK_DARK_GRAY_CLOTH:    "dark_gray_cloth",
+	BLOCK_LIGHT_GRAY_CLOTH:   "light_gray_cloth",
+	BLOCK_WHITE_CLOTH:        "white_cloth",
+	BLOCK_DANDELION:          "dandelion",
+	BLOCK_ROSE:               "rose",
+	BLOCK_BROWN_MUSHROOM:     "brown_mushroom",
+	BLOCK_RED_MUSHROOM:       "red_mushroom",
+	BLOCK_GOLD:               "gold",
+	BLOCK_IRON:               "iron",
+	BLOCK_DOUBLE_SLAB:        "double_slab",
+	BLOCK_SLAB:               "slab",
+	BLOCK_BRICK:              "brick",
+	BLOCK_TNT:                "tnt",
+	BLOCK_BOOKSHELF:          "bookshelf",
+	BLOCK_MOSSY_COBBLESTONE:  "mossy_cobblestone",
+	BLOCK_OBSIDIAN:           "obsidian",
+	BLOCK_COBBLESTONE_SLAB:   "cobblestone_slab",
+	BLOCK_ROPE:               "rope",
+	BLOCK_SANDSTONE:          "sandstone",
+	BLOCK_SNOW:               "snow",
+	BLOCK_FIRE:               "fire",
+	BLOCK_LIGHT_PINK_CLOTH:   "light_pink_cloth",
+	BLOCK_FOREST_GREEN_CLOTH: "forest_green_cloth",
+	BLOCK_BROWN_CLOTH:        "brown_cloth",
+	BLOCK_DEEP_BLUE_CLOTH:    "deep_blue_cloth",
+	BLOCK_TURQUOISE_CLOTH:    "turquoise_cloth",
+	BLOCK_ICE:                "ice",
+	BLOCK_CERAMIC_TILE:       "ceramic_tile",
+	BLOCK_MAGMA:              "magma",
+	BLOCK_PILLAR:             "pillar",
+	BLOCK_CRATE:              "crate",
+	BLOCK_STONE_BRICK:        "stone_brick",
+}
+
+// Classic blocks shown instead of CustomBlocks ones to clients which don't support them
+var BLOCK_FALLBACKS = [BLOCK_COUNT - CLASSIC_BLOCK_COUNT]byte{
+	BLOCK_COBBLESTONE_SLAB - CLASSIC_BLOCK_COUNT:   BLOCK_SLAB,
+	BLOCK_ROPE - CLASSIC_BLOCK_COUNT:               BLOCK_BROWN_MUSHROOM,
+	BLOCK_SANDSTONE - CLASSIC_BLOCK_COUNT:          BLOCK_SAND,
+	BLOCK_SNOW - CLASSIC_BLOCK_COUNT:               BLOCK_AIR,
+	BLOCK_FIRE - CLASSIC_BLOCK_COUNT:               BLOCK_STATIONARY_LAVA,
+	BLOCK_LIGHT_PINK_CLOTH - CLASSIC_BLOCK_COUNT:   BLOCK_ROSE_CLOTH,
+	BLOCK_FOREST_GREEN_CLOTH - CLASSIC_BLOCK_COUNT: BLOCK_GREEN_CLOTH,
+	BLOCK_BROWN_CLOTH - CLASSIC_BLOCK_COUNT:        BLOCK_DIRT,
+	BLOCK_DEEP_BLUE_CLOTH - CLASSIC_BLOCK_COUNT:    BLOCK_ULTRAMARINE_CLOTH,
+	BLOCK_TURQUOISE_CLOTH - CLASSIC_BLOCK_COUNT:    BLOCK_CAPRI_CLOTH,
+	BLOCK_ICE - CLASSIC_BLOCK_COUNT:                BLOCK_GLASS,
+	BLOCK_CERAMIC_TILE - CLASSIC_BLOCK_COUNT:       BLOCK_IRON,
+	BLOCK_MAGMA - CLASSIC_BLOCK_COUNT:              BLOCK_OBSIDIAN,
+	BLOCK_PILLAR - CLASSIC_BLOCK_COUNT:             BLOCK_WHITE_CLOTH,
+	BLOCK_CRATE - CLASSIC_BLOCK_COUNT:              BLOCK_WOOD,
+	BLOCK_STONE_BRICK - CLASSIC_BLOCK_COUNT:        BLOCK_STONE,
+}
+
+func ValidBlock(block byte) bool {
+	return block < BLOCK_COUNT
+}
+
+// Block a client without CustomBlocks is shown instead. Classic and unknown blocks are unchanged
+func Fallback(block byte) byte {
+	if block < CLASSIC_BLOCK_COUNT || block >= BLOCK_COUNT {
+		return block
+	}
+	return BLOCK_FALLBACKS[block-CLASSIC_BLOCK_COUNT]
+}
+
+// Accepts either the block's name or its numeric id
+func BlockByName(name string) (byte, bool) {
+	name = strings.ToLower(name)
+	for block, blockName := range BLOCK_NAMES {
+		if blockName == name {
+			return byte(block), true
+		}
+	}
+	id, err := strconv.ParseUint(name, 10, 8)
+	if err != nil || !ValidBlock(byte(id)) {
+		return 0, false
+	}
+	return byte(id), true
+}
@@ -0,0 +1,250 @@
+package world
+
+import (
+	"compress/gzip"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/nbt"
+)
+
+const CLASSICWORLD_EXTENSION = ".cw"
+
+const CLASSICWORLD_ROOT = "ClassicWorld"
+
+const CLASSICWORLD_FORMAT_VERSION = 1
+
+const (
+	CLASSICWORLD_UNSUPPORTED_VERSION = iota
+	CLASSICWORLD_MISSING_TAG
+)
+
+// Tags written from the level itself, rather than carried over in Extra
+var classicWorldTags = []string{"FormatVersion", "Name", "UUID", "X", "Y", "Z", "Spawn", "BlockArray"}
+
+// Compound where software keeps its own data, with CPE metadata in its CPE compound
+const CLASSICWORLD_METADATA = "Metadata"
+
+// CPE metadata read into the level's environment. Other metadata, such as BlockDefinitions, is kept in Extra as it is
+const (
+	CLASSICWORLD_ENV_COLORS     = "EnvColors"
+	CLASSICWORLD_ENV_APPEARANCE = "EnvMapAppearance"
+)
+
+// Indexed by ENV_COLOR_*
+var classicWorldEnvColors = [ENV_COLOR_COUNT]string{"Sky", "Cloud", "Fog", "Ambient", "Sunlight"}
+
+func missingTag(name string) error {
+	return cerror.NewErrorf(CLASSICWORLD_MISSING_TAG, "ClassicWorld is missing %s", name)
+}
+
+// Spawn is stored as the block the player's feet are in
+func readClassicWorldSpawn(root nbt.Compound, spawn *Position) {
+	compound, ok := nbt.Get[nbt.Compound](root, "Spawn")
+	if !ok {
+		return
+	}
+	x, _ := nbt.Get[int16](compound, "X")
+	y, _ := nbt.Get[int16](compound, "Y")
+	z, _ := nbt.Get[int16](compound, "Z")
+	yaw, _ := nbt.Get[int8](compound, "H")
+	pitch, _ := nbt.Get[int8](compound, "P")
+	*spawn = Position{
+		X:     float32(x) + 0.5,
+		Y:     float32(y) + PLAYER_HEIGHT,
+		Z:     float32(z) + 0.5,
+		Yaw:   byte(yaw),
+		Pitch: byte(pitch),
+	}
+}
+
+func readClassicWorldColor(colors nbt.Compound, name string) EnvColor {
+	compound, ok := nbt.Get[nbt.Compound](colors, name)
+	if !ok {
+		return DEFAULT_ENV_COLOR
+	}
+	red, redOk := nbt.Get[int16](compound, "R")
+	green, greenOk := nbt.Get[int16](compound, "G")
+	blue, blueOk := nbt.Get[int16](compound, "B")
+	if !redOk || !greenOk || !blueOk {
+		return DEFAULT_ENV_COLOR
+	}
+	return EnvColor{Red: red, Green: green, Blue: blue}
+}
+
+// Removes the environment from the CPE metadata in extra, so what's saved comes from the level's settings. Nil if there isn't one
+func takeClassicWorldEnvironment(extra nbt.Compound, height int16) *Environment {
+	metadata, _ := nbt.Get[nbt.Compound](extra, CLASSICWORLD_METADATA)
+	cpe, _ := nbt.Get[nbt.Compound](metadata, "CPE")
+	colors, hasColors := nbt.Get[nbt.Compound](cpe, CLASSICWORLD_ENV_COLORS)
+	appearance, hasAppearance := nbt.Get[nbt.Compound](cpe, CLASSICWORLD_ENV_APPEARANCE)
+	if !hasColors && !hasAppearance {
+		return nil
+	}
+	delete(cpe, CLASSICWORLD_ENV_COLORS)
+	delete(cpe, CLASSICWORLD_ENV_APPEARANCE)
+
+	environment := NewEnvironment()
+	for i, name := range classicWorldEnvColors {
+		environment.Colors[i] = readClassicWorldColor(colors, name)
+	}
+	if hasAppearance {
+		url, _ := nbt.Get[string](appearance, "TextureURL")
+		environment.Appearance = &MapAppearance{TextureURL: url, SideBlock: BLOCK_BEDROCK, EdgeBlock: BLOCK_STATIONARY_WATER, SideLevel: height / 2}
+		if block, ok := nbt.Get[int8](appearance, "SideBlock"); ok {
+			environment.Appearance.SideBlock = byte(block)
+		}
+		if block, ok := nbt.Get[int8](appearance, "EdgeBlock"); ok {
+			environment.Appearance.EdgeBlock = byte(block)
+		}
+		if level, ok := nbt.Get[int16](appearance, "SideLevel"); ok {
+			environment.Appearance.SideLevel = level
+		}
+	}
+	return environment
+}
+
+// Created if missing
+func childCompound(parent nbt.Compound, name string) nbt.Compound {
+	child, ok := nbt.Get[nbt.Compound](parent, name)
+	if !ok {
+		child = make(nbt.Compound)
+		parent[name] = child
+	}
+	return child
+}
+
+func writeClassicWorldEnvironment(root nbt.Compound, environment *Environment) {
+	cpe := childCompound(childCompound(root, CLASSICWORLD_METADATA), "CPE")
+	colors := nbt.Compound{"ExtensionVersion": int32(1)}
+	for i, name := range classicWorldEnvColors {
+		color := environment.Colors[i]
+		colors[name] = nbt.Compound{"R": color.Red, "G": color.Green, "B": color.Blue}
+	}
+	cpe[CLASSICWORLD_ENV_COLORS] = colors
+	if appearance := environment.Appearance; appearance != nil {
+		cpe[CLASSICWORLD_ENV_APPEARANCE] = nbt.Compound{
+			"ExtensionVersion": int32(1),
+			"TextureURL":       appearance.TextureURL,
+			"SideBlock":        int8(appearance.SideBlock),
+			"EdgeBlock":        int8(appearance.EdgeBlock),
+			"SideLevel":        appearance.SideLevel,
+		}
+	}
+}
+
+// Reads a gzip compressed ClassicWorld. The name is used for the level rather than the one stored in the file
+func ReadClassicWorld(name string, reader io.Reader) (*Level, error) {
+	decompressed, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer decompressed.Close()
+	_, root, err := nbt.Read(decompressed)
+	if err != nil {
+		return nil, err
+	}
+	version, ok := nbt.Get[int8](root, "FormatVersion")
+	if !ok {
+		return nil, missingTag("FormatVersion")
+	}
+	if version != CLASSICWORLD_FORMAT_VERSION {
+		return nil, cerror.NewErrorf(CLASSICWORLD_UNSUPPORTED_VERSION, "Unsupported ClassicWorld version %d", version)
+	}
+	var size [3]int16
+	for i, axis := range []string{"X", "Y", "Z"} {
+		if size[i], ok = nbt.Get[int16](root, axis); !ok {
+			return nil, missingTag(axis)
+		}
+	}
+	blocks, ok := nbt.Get[[]byte](root, "BlockArray")
+	if !ok {
+		return nil, missingTag("BlockArray")
+	}
+	level, err := NewLevelFromBlocks(name, size[0], size[1], size[2], blocks)
+	if err != nil {
+		return nil, err
+	}
+	readClassicWorldSpawn(root, &level.spawn)
+	if uuid, ok := nbt.Get[[]byte](root, "UUID"); ok && len(uuid) == len(level.uuid) {
+		copy(level.uuid[:], uuid)
+	}
+	for tag, value := range root {
+		level.Extra[tag] = value
+	}
+	for _, tag := range classicWorldTags {
+		delete(level.Extra, tag)
+	}
+	if environment := takeClassicWorldEnvironment(level.Extra, level.height); environment != nil {
+		level.Settings().SetEnvironment(environment)
+	}
+	return level, nil
+}
+
+func LoadClassicWorld(path string) (*Level, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	name := filepath.Base(path)
+	return ReadClassicWorld(name[:len(name)-len(filepath.Ext(name))], file)
+}
+
+func clampShort(value float64) int16 {
+	return int16(max(math.MinInt16, min(math.MaxInt16, math.Floor(value))))
+}
+
+// Writes the level as a gzip compressed ClassicWorld, along with any extra tags it was loaded with
+func (level *Level) WriteClassicWorld(writer io.Writer) error {
+	root := level.Extra.Clone()
+	if environment := level.Settings().Environment(); environment != nil {
+		writeClassicWorldEnvironment(root, environment)
+	}
+	spawn := level.Spawn()
+	uuid := level.UUID()
+	now := time.Now().Unix()
+	if _, ok := root["TimeCreated"]; !ok {
+		root["TimeCreated"] = now
+	}
+	root["LastModified"] = now
+	root["FormatVersion"] = int8(CLASSICWORLD_FORMAT_VERSION)
+	root["Name"] = level.name
+	root["UUID"] = uuid[:]
+	root["X"], root["Y"], root["Z"] = level.width, level.height, level.length
+	root["Spawn"] = nbt.Compound{
+		"X": clampShort(float64(spawn.X)),
+		"Y": clampShort(float64(spawn.Y - PLAYER_HEIGHT)),
+		"Z": clampShort(float64(spawn.Z)),
+		"H": int8(spawn.Yaw),
+		"P": int8(spawn.Pitch),
+	}
+	root["BlockArray"] = level.Blocks()
+
+	compressed := gzip.NewWriter(writer)
+	if err := nbt.Write(compressed, CLASSICWORLD_ROOT, root); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// Written to a temporary file first, so a failed save doesn't leave a corrupt level behind
+func (level *Level) SaveClassicWorld(path string) error {
+	temporary, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	if err := level.WriteClassicWorld(temporary); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), path)
+}
@@ -0,0 +1,253 @@
+package world
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/nbt"
+)
+
+// CPE metadata as ClassiCube writes it, with block definitions the level doesn't use itself
+func cpeMetadata() nbt.Compound {
+	return nbt.Compound{
+		"Metadata": nbt.Compound{
+			"CPE": nbt.Compound{
+				"EnvColors": nbt.Compound{
+					"ExtensionVersion": int32(1),
+					"Sky":              nbt.Compound{"R": int16(153), "G": int16(204), "B": int16(255)},
+					"Fog":              nbt.Compound{"R": int16(-1), "G": int16(-1), "B": int16(-1)},
+				},
+				"EnvMapAppearance": nbt.Compound{
+					"ExtensionVersion": int32(1),
+					"TextureURL":       "https://example.com/terrain.png",
+					"SideBlock":        int8(BLOCK_GLASS),
+					"EdgeBlock":        int8(BLOCK_STATIONARY_LAVA),
+				},
+				"BlockDefinitions": nbt.Compound{
+					"Block66": nbt.Compound{"ID": int8(66), "Name": "Custom", "Textures": []byte{1, 2, 3, 4, 5, 6}},
+				},
+			},
+		},
+	}
+}
+
+// What the level keeps in Extra from cpeMetadata, once the environment has been read out of it
+func opaqueMetadata() nbt.Compound {
+	metadata := cpeMetadata()
+	cpe := metadata["Metadata"].(nbt.Compound)["CPE"].(nbt.Compound)
+	delete(cpe, "EnvColors")
+	delete(cpe, "EnvMapAppearance")
+	return metadata
+}
+
+func writeClassicWorld(t *testing.T, level *Level) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := level.WriteClassicWorld(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// Reads the raw tags, as another server or client would
+func readClassicWorldTags(t *testing.T, data []byte) (string, nbt.Compound) {
+	t.Helper()
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, root, err := nbt.Read(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name, root
+}
+
+func TestClassicWorldRoundTrip(t *testing.T) {
+	level := noisyLevel(t, 37, 19, 23)
+	level.SetSpawn(Position{X: 10.5, Y: 5 + PLAYER_HEIGHT, Z: 20.5, Yaw: 64, Pitch: 200})
+	level.Extra = cpeMetadata()
+
+	read, err := ReadClassicWorld("copy", bytes.NewReader(writeClassicWorld(t, level)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Name() != "copy" {
+		t.Fatalf("Expected the given name, got %q", read.Name())
+	}
+	width, height, length := read.Size()
+	if width != 37 || height != 19 || length != 23 {
+		t.Fatalf("Expected size 37x19x23, got %dx%dx%d", width, height, length)
+	}
+	if !bytes.Equal(read.Blocks(), level.Blocks()) {
+		t.Fatal("Blocks changed after a round trip")
+	}
+	if read.Spawn() != level.Spawn() {
+		t.Fatalf("Expected spawn %+v, got %+v", level.Spawn(), read.Spawn())
+	}
+	if read.UUID() != level.UUID() {
+		t.Fatal("UUID changed after a round trip")
+	}
+	extra := read.Extra.Clone()
+	for _, tag := range []string{"TimeCreated", "LastModified"} {
+		if _, ok := nbt.Get[int64](extra, tag); !ok {
+			t.Fatalf("Missing %s", tag)
+		}
+		delete(extra, tag)
+	}
+	if !reflect.DeepEqual(extra, opaqueMetadata()) {
+		t.Fatalf("Unknown tags changed after a round trip:\n%#v\n%#v", opaqueMetadata(), extra)
+	}
+}
+
+func TestClassicWorldEnvironment(t *testing.T) {
+	level := noisyLevel(t, 8, 20, 8)
+	level.Extra = cpeMetadata()
+	read, err := ReadClassicWorld("env", bytes.NewReader(writeClassicWorld(t, level)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NewEnvironment()
+	expected.Colors[ENV_COLOR_SKY] = EnvColor{Red: 153, Green: 204, Blue: 255}
+	// The side level wasn't given, so is the default of half the height
+	expected.Appearance = &MapAppearance{TextureURL: "https://example.com/terrain.png", SideBlock: BLOCK_GLASS, EdgeBlock: BLOCK_STATIONARY_LAVA, SideLevel: 10}
+	environment := read.Settings().Environment()
+	if !reflect.DeepEqual(environment, expected) {
+		t.Fatalf("Expected environment %+v, got %+v", expected, environment)
+	}
+
+	// Changes to the settings are what's saved
+	environment.Colors[ENV_COLOR_CLOUD] = EnvColor{Red: 1, Green: 2, Blue: 3}
+	environment.Appearance = nil
+	read.Settings().SetEnvironment(environment)
+	_, root := readClassicWorldTags(t, writeClassicWorld(t, read))
+	cpe := root["Metadata"].(nbt.Compound)["CPE"].(nbt.Compound)
+	if _, ok := cpe["EnvMapAppearance"]; ok {
+		t.Fatal("Saved a map appearance which was removed")
+	}
+	if _, ok := cpe["BlockDefinitions"]; !ok {
+		t.Fatal("Lost the block definitions")
+	}
+	cloud, _ := nbt.Get[nbt.Compound](cpe["EnvColors"].(nbt.Compound), "Cloud")
+	if red, _ := nbt.Get[int16](cloud, "R"); red != 1 {
+		t.Fatalf("Expected the changed cloud color to be saved, got %v", cloud)
+	}
+}
+
+func TestClassicWorldWithoutEnvironment(t *testing.T) {
+	level := noisyLevel(t, 4, 4, 4)
+	read, err := ReadClassicWorld("plain", bytes.NewReader(writeClassicWorld(t, level)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if environment := read.Settings().Environment(); environment != nil {
+		t.Fatalf("Expected no environment, got %+v", environment)
+	}
+}
+
+// Files need the tags and types other ClassicWorld readers expect
+func TestClassicWorldLayout(t *testing.T) {
+	level, err := NewFlatLevel("layout", 16, 8, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	level.SetSpawn(Position{X: 8.5, Y: 4 + PLAYER_HEIGHT, Z: 16.5})
+	name, root := readClassicWorldTags(t, writeClassicWorld(t, level))
+	if name != CLASSICWORLD_ROOT {
+		t.Fatalf("Expected root %q, got %q", CLASSICWORLD_ROOT, name)
+	}
+	if version, _ := nbt.Get[int8](root, "FormatVersion"); version != CLASSICWORLD_FORMAT_VERSION {
+		t.Fatalf("Expected format version %d, got %d", CLASSICWORLD_FORMAT_VERSION, version)
+	}
+	if levelName, _ := nbt.Get[string](root, "Name"); levelName != "layout" {
+		t.Fatalf("Expected name layout, got %q", levelName)
+	}
+	if uuid, _ := nbt.Get[[]byte](root, "UUID"); len(uuid) != 16 {
+		t.Fatalf("Expected a 16 byte UUID, got %d bytes", len(uuid))
+	}
+	for axis, expected := range map[string]int16{"X": 16, "Y": 8, "Z": 32} {
+		if size, _ := nbt.Get[int16](root, axis); size != expected {
+			t.Fatalf("Expected %s of %d, got %d", axis, expected, size)
+		}
+	}
+	if blocks, _ := nbt.Get[[]byte](root, "BlockArray"); len(blocks) != 16*8*32 {
+		t.Fatalf("Expected %d blocks, got %d", 16*8*32, len(blocks))
+	}
+	spawn, _ := nbt.Get[nbt.Compound](root, "Spawn")
+	for axis, expected := range map[string]int16{"X": 8, "Y": 4, "Z": 16} {
+		if value, _ := nbt.Get[int16](spawn, axis); value != expected {
+			t.Fatalf("Expected spawn %s of %d, got %d", axis, expected, value)
+		}
+	}
+}
+
+func TestClassicWorldKeepsTimeCreated(t *testing.T) {
+	level, err := NewFlatLevel("created", 4, 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	level.Extra["TimeCreated"] = int64(1234)
+	_, root := readClassicWorldTags(t, writeClassicWorld(t, level))
+	if created, _ := nbt.Get[int64](root, "TimeCreated"); created != 1234 {
+		t.Fatalf("Expected the original creation time, got %d", created)
+	}
+}
+
+func TestClassicWorldRejectsInvalid(t *testing.T) {
+	level, err := NewFlatLevel("invalid", 4, 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, valid := readClassicWorldTags(t, writeClassicWorld(t, level))
+	tests := map[string]func(nbt.Compound){
+		"unsupported version": func(root nbt.Compound) { root["FormatVersion"] = int8(2) },
+		"missing version":     func(root nbt.Compound) { delete(root, "FormatVersion") },
+		"missing size":        func(root nbt.Compound) { delete(root, "Y") },
+		"wrong size type":     func(root nbt.Compound) { root["X"] = int32(4) },
+		"missing blocks":      func(root nbt.Compound) { delete(root, "BlockArray") },
+		"short blocks":        func(root nbt.Compound) { root["BlockArray"] = make([]byte, 63) },
+	}
+	for name, change := range tests {
+		root := valid.Clone()
+		change(root)
+		var buffer bytes.Buffer
+		compressed := gzip.NewWriter(&buffer)
+		if err := nbt.Write(compressed, CLASSICWORLD_ROOT, root); err != nil {
+			t.Fatal(err)
+		}
+		compressed.Close()
+		if _, err := ReadClassicWorld(name, &buffer); err == nil {
+			t.Errorf("Read a ClassicWorld with %s", name)
+		}
+	}
+}
+
+func TestSaveClassicWorld(t *testing.T) {
+	directory := t.TempDir()
+	path := filepath.Join(directory, "saved"+CLASSICWORLD_EXTENSION)
+	level := noisyLevel(t, 8, 8, 8)
+	if err := level.SaveClassicWorld(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadClassicWorld(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Name() != "saved" {
+		t.Fatalf("Expected the name from the file, got %q", loaded.Name())
+	}
+	if !bytes.Equal(loaded.Blocks(), level.Blocks()) {
+		t.Fatal("Blocks changed after saving")
+	}
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected only the saved level, found %d files", len(entries))
+	}
+}
@@ -0,0 +1,191 @@
+package world
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"runtime"
+	"sync"
+)
+
+// Amount of serialized level compressed independently, so a block change only recompresses its segment
+const COMPRESSION_SEGMENT_SIZE = 64 * 1024
+
+const LENGTH_PREFIX_SIZE = 4
+
+var gzipHeader = []byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 0xff}
+
+// Empty final deflate block using fixed huffman codes
+var deflateEnd = []byte{0x03, 0x00}
+
+type compressionCache struct {
+	mutex sync.Mutex
+	// Blocks are replaced with their fallbacks before compressing, for clients without CustomBlocks
+	fallbacks  bool
+	segments   [][]byte
+	compressed []byte
+	// Guarded by the level mutex rather than the cache mutex, as it's set alongside block changes
+	dirty []bool
+}
+
+func (cache *compressionCache) init(serializedSize int) {
+	count := (serializedSize + COMPRESSION_SEGMENT_SIZE - 1) / COMPRESSION_SEGMENT_SIZE
+	cache.segments = make([][]byte, count)
+	cache.dirty = make([]bool, count)
+	cache.invalidate()
+}
+
+// Must be called with the level write lock held
+func (cache *compressionCache) invalidate() {
+	for i := range cache.dirty {
+		cache.dirty[i] = true
+	}
+}
+
+// Must be called with the level write lock held
+func (cache *compressionCache) markDirty(index int) {
+	cache.dirty[(index+LENGTH_PREFIX_SIZE)/COMPRESSION_SEGMENT_SIZE] = true
+}
+
+func compressSegment(raw []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	compressor, err := flate.NewWriter(&buffer, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := compressor.Write(raw); err != nil {
+		return nil, err
+	}
+	// Flushing rather than closing leaves the stream byte aligned and unterminated so segments can be joined
+	if err := compressor.Flush(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Serialized bytes between start and end, with the length prefix counted as part of the data
+func (level *Level) serializedRange(start, end int, fallbacks bool) []byte {
+	var prefix [LENGTH_PREFIX_SIZE]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(len(level.blocks)))
+	raw := make([]byte, 0, end-start)
+	if start < LENGTH_PREFIX_SIZE {
+		raw = append(raw, prefix[start:min(end, LENGTH_PREFIX_SIZE)]...)
+	}
+	if end > LENGTH_PREFIX_SIZE {
+		blocks := level.blocks[max(start-LENGTH_PREFIX_SIZE, 0) : end-LENGTH_PREFIX_SIZE]
+		if !fallbacks {
+			return append(raw, blocks...)
+		}
+		for _, block := range blocks {
+			raw = append(raw, Fallback(block))
+		}
+	}
+	return raw
+}
+
+// Gzip compressed serialized level, as sent to clients. The result is shared and must not be modified
+func (level *Level) Compressed() ([]byte, error) {
+	return level.compressed(&level.compression)
+}
+
+// Like Compressed, but with blocks replaced by their fallbacks for clients without CustomBlocks
+func (level *Level) FallbackCompressed() ([]byte, error) {
+	return level.compressed(&level.fallbackCompression)
+}
+
+func (level *Level) compressed(cache *compressionCache) ([]byte, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	level.mutex.Lock()
+	serializedSize := len(level.blocks) + LENGTH_PREFIX_SIZE
+	jobs := make(map[int][]byte)
+	for i, dirty := range cache.dirty {
+		if dirty {
+			jobs[i] = level.serializedRange(i*COMPRESSION_SEGMENT_SIZE, min((i+1)*COMPRESSION_SEGMENT_SIZE, serializedSize), cache.fallbacks)
+			cache.dirty[i] = false
+		}
+	}
+	if len(jobs) == 0 && cache.compressed != nil {
+		level.mutex.Unlock()
+		return cache.compressed, nil
+	}
+	checksum := level.checksum(serializedSize, cache.fallbacks)
+	level.mutex.Unlock()
+
+	if err := cache.compressSegments(jobs); err != nil {
+		// Whatever failed needs redoing next time
+		level.mutex.Lock()
+		for i := range jobs {
+			cache.dirty[i] = true
+		}
+		level.mutex.Unlock()
+		return nil, err
+	}
+
+	size := len(gzipHeader) + len(deflateEnd) + 8
+	for _, segment := range cache.segments {
+		size += len(segment)
+	}
+	compressed := make([]byte, 0, size)
+	compressed = append(compressed, gzipHeader...)
+	for _, segment := range cache.segments {
+		compressed = append(compressed, segment...)
+	}
+	compressed = append(compressed, deflateEnd...)
+	compressed = binary.LittleEndian.AppendUint32(compressed, checksum)
+	compressed = binary.LittleEndian.AppendUint32(compressed, uint32(serializedSize))
+	cache.compressed = compressed
+	return compressed, nil
+}
+
+// CRC of the whole serialized level, taken a segment at a time when blocks are replaced so the level isn't copied whole
+func (level *Level) checksum(serializedSize int, fallbacks bool) uint32 {
+	if !fallbacks {
+		checksum := crc32.ChecksumIEEE(level.serializedRange(0, LENGTH_PREFIX_SIZE, false))
+		return crc32.Update(checksum, crc32.IEEETable, level.blocks)
+	}
+	var checksum uint32
+	for start := 0; start < serializedSize; start += COMPRESSION_SEGMENT_SIZE {
+		checksum = crc32.Update(checksum, crc32.IEEETable, level.serializedRange(start, min(start+COMPRESSION_SEGMENT_SIZE, serializedSize), true))
+	}
+	return checksum
+}
+
+func (cache *compressionCache) compressSegments(jobs map[int][]byte) error {
+	type result struct {
+		index   int
+		segment []byte
+		err     error
+	}
+	queue := make(chan int)
+	results := make(chan result)
+	workers := min(runtime.GOMAXPROCS(0), len(jobs))
+	for range workers {
+		go func() {
+			for index := range queue {
+				segment, err := compressSegment(jobs[index])
+				results <- result{index: index, segment: segment, err: err}
+			}
+		}()
+	}
+	go func() {
+		for index := range jobs {
+			queue <- index
+		}
+		close(queue)
+	}()
+	var firstErr error
+	for range jobs {
+		result := <-results
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			continue
+		}
+		cache.segments[result.index] = result.segment
+	}
+	return firstErr
+}
@@ -0,0 +1,205 @@
+package world
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// Random terrain compresses far worse than a flat level, like a built up map
+func noisyLevel(tb testing.TB, width, height, length int16) *Level {
+	tb.Helper()
+	random := rand.New(rand.NewSource(1))
+	level, err := NewLevel("bench", width, height, length)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i := range level.blocks {
+		if random.Intn(4) == 0 {
+			level.blocks[i] = byte(random.Intn(BLOCK_COUNT))
+		}
+	}
+	return level
+}
+
+func decompress(t *testing.T, compressed []byte) []byte {
+	t.Helper()
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func serialized(t *testing.T, level *Level) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := level.Serialize(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestCompressedMatchesSerialized(t *testing.T) {
+	level := noisyLevel(t, 100, 40, 90)
+	compressed, err := level.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompress(t, compressed), serialized(t, level)) {
+		t.Fatal("Decompressed level differs from its serialized blocks")
+	}
+
+	// Changes in the first and last segments, the first touching the length prefix
+	for _, position := range [][3]int16{{0, 0, 0}, {99, 39, 89}, {50, 20, 45}} {
+		if err := level.SetBlock(position[0], position[1], position[2], BLOCK_GOLD); err != nil {
+			t.Fatal(err)
+		}
+	}
+	patched, err := level.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompress(t, patched), serialized(t, level)) {
+		t.Fatal("Decompressed level differs after block changes")
+	}
+}
+
+func TestFallbackCompressed(t *testing.T) {
+	level := noisyLevel(t, 100, 40, 90)
+	expected := func() []byte {
+		data := serialized(t, level)
+		for i := LENGTH_PREFIX_SIZE; i < len(data); i++ {
+			data[i] = Fallback(data[i])
+		}
+		return data
+	}
+	compressed, err := level.FallbackCompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompress(t, compressed), expected()) {
+		t.Fatal("Decompressed level differs from its serialized fallback blocks")
+	}
+
+	if err := level.SetBlock(50, 20, 45, BLOCK_STONE_BRICK); err != nil {
+		t.Fatal(err)
+	}
+	patched, err := level.FallbackCompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompress(t, patched), expected()) {
+		t.Fatal("Decompressed level differs after block changes")
+	}
+	unchanged, err := level.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompress(t, unchanged), serialized(t, level)) {
+		t.Fatal("Compressed level has fallback blocks")
+	}
+}
+
+func TestCompressedIsShared(t *testing.T) {
+	level := noisyLevel(t, 64, 32, 64)
+	first, err := level.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := level.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &first[0] != &second[0] {
+		t.Fatal("Unchanged level was compressed again")
+	}
+}
+
+// Every join after the first reuses the cached stream
+func BenchmarkJoinCached(b *testing.B) {
+	level := noisyLevel(b, 512, 64, 512)
+	if _, err := level.Compressed(); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		if _, err := level.Compressed(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The first join, or one after the whole level was replaced
+func BenchmarkJoinCold(b *testing.B) {
+	level := noisyLevel(b, 512, 64, 512)
+	b.ResetTimer()
+	for range b.N {
+		level.mutex.Lock()
+		level.compression.invalidate()
+		level.mutex.Unlock()
+		if _, err := level.Compressed(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// A join after someone changed a block, which only recompresses that segment
+func BenchmarkJoinAfterBlockChange(b *testing.B) {
+	level := noisyLevel(b, 512, 64, 512)
+	if _, err := level.Compressed(); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := range b.N {
+		if err := level.SetBlock(int16(i%512), 32, int16(i/512%512), byte(i%2+BLOCK_STONE)); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := level.Compressed(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Many players joining at once while others build
+func BenchmarkJoinUnderLoad(b *testing.B) {
+	level := noisyLevel(b, 512, 64, 512)
+	if _, err := level.Compressed(); err != nil {
+		b.Fatal(err)
+	}
+	stop := make(chan struct{})
+	var builders sync.WaitGroup
+	for builder := range 4 {
+		builders.Add(1)
+		go func() {
+			defer builders.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				level.SetBlock(int16((i*7+builder*101)%512), int16(i%64), int16((i*13)%512), byte(i%2+BLOCK_STONE))
+			}
+		}()
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := level.Compressed(); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	builders.Wait()
+}
@@ -0,0 +1,211 @@
+package world
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Hedwig7s/Burrowing-Classic/internal/cerror"
+	"github.com/Hedwig7s/Burrowing-Classic/internal/nbt"
+)
+
+// Distance from a player's feet to the position sent over the network
+const PLAYER_HEIGHT = 51.0 / 32.0
+
+// Most blocks a level can have, such as 1024x256x1024, so sizes read from untrusted files can't exhaust memory
+const MAX_LEVEL_VOLUME = 1 << 28
+
+const (
+	LEVEL_OUT_OF_BOUNDS = iota
+	LEVEL_INVALID_SIZE
+	LEVEL_BLOCK_COUNT_MISMATCH
+)
+
+type Level struct {
+	mutex  sync.RWMutex
+	name   string
+	width  int16
+	height int16
+	length int16
+	blocks []byte
+	spawn  Position
+	uuid   [16]byte
+
+	compression         compressionCache
+	fallbackCompression compressionCache
+
+	// Tags from a loaded ClassicWorld file which aren't otherwise used, kept so saving doesn't lose them
+	Extra nbt.Compound
+
+	// Replaced whole when reloaded, so readers never see one half loaded
+	behaviors atomic.Pointer[Behaviors]
+	settings  atomic.Pointer[Settings]
+}
+
+func (level *Level) Name() string {
+	return level.name
+}
+
+// X, Y and Z sizes
+func (level *Level) Size() (width, height, length int16) {
+	return level.width, level.height, level.length
+}
+
+func (level *Level) Spawn() Position {
+	level.mutex.RLock()
+	defer level.mutex.RUnlock()
+	return level.spawn
+}
+
+func (level *Level) SetSpawn(spawn Position) {
+	level.mutex.Lock()
+	defer level.mutex.Unlock()
+	level.spawn = spawn
+}
+
+// Must not be modified once the level is in use. Replace it with SetBehaviors instead
+func (level *Level) Behaviors() *Behaviors {
+	return level.behaviors.Load()
+}
+
+func (level *Level) SetBehaviors(behaviors *Behaviors) {
+	level.behaviors.Store(behaviors)
+}
+
+func (level *Level) Settings() *Settings {
+	return level.settings.Load()
+}
+
+func (level *Level) SetSettings(settings *Settings) {
+	level.settings.Store(settings)
+}
+
+func (level *Level) UUID() [16]byte {
+	return level.uuid
+}
+
+// Copy of every block, ordered by Y, then Z, then X
+func (level *Level) Blocks() []byte {
+	level.mutex.RLock()
+	defer level.mutex.RUnlock()
+	return slices.Clone(level.blocks)
+}
+
+func (level *Level) InBounds(x, y, z int16) bool {
+	return x >= 0 && y >= 0 && z >= 0 && x < level.width && y < level.height && z < level.length
+}
+
+func (level *Level) index(x, y, z int16) int {
+	return (int(y)*int(level.length)+int(z))*int(level.width) + int(x)
+}
+
+func (level *Level) GetBlock(x, y, z int16) (byte, error) {
+	if !level.InBounds(x, y, z) {
+		return 0, cerror.NewErrorf(LEVEL_OUT_OF_BOUNDS, "Block %d,%d,%d is outside of level %s", x, y, z, level.name)
+	}
+	level.mutex.RLock()
+	defer level.mutex.RUnlock()
+	return level.blocks[level.index(x, y, z)], nil
+}
+
+func (level *Level) SetBlock(x, y, z int16, block byte) error {
+	if !level.InBounds(x, y, z) {
+		return cerror.NewErrorf(LEVEL_OUT_OF_BOUNDS, "Block %d,%d,%d is outside of level %s", x, y, z, level.name)
+	}
+	level.mutex.Lock()
+	defer level.mutex.Unlock()
+	index := level.index(x, y, z)
+	if level.blocks[index] == block {
+		return nil
+	}
+	level.blocks[index] = block
+	level.compression.markDirty(index)
+	level.fallbackCompression.markDirty(index)
+	return nil
+}
+
+// Writes the block count followed by the blocks, as clients expect before compression
+func (level *Level) Serialize(writer io.Writer) error {
+	level.mutex.RLock()
+	defer level.mutex.RUnlock()
+	if err := binary.Write(writer, binary.BigEndian, int32(len(level.blocks))); err != nil {
+		return err
+	}
+	_, err := writer.Write(level.blocks)
+	return err
+}
+
+// Whether a level can be created with the size. Takes ints so sizes can be checked before they're narrowed
+func ValidLevelSize(width, height, length int) bool {
+	if width <= 0 || height <= 0 || length <= 0 || width > math.MaxInt16 || height > math.MaxInt16 || length > math.MaxInt16 {
+		return false
+	}
+	return width*height*length <= MAX_LEVEL_VOLUME
+}
+
+func NewLevel(name string, width, height, length int16) (*Level, error) {
+	if !ValidLevelSize(int(width), int(height), int(length)) {
+		return nil, cerror.NewErrorf(LEVEL_INVALID_SIZE, "Invalid level size %dx%dx%d", width, height, length)
+	}
+	level := &Level{
+		name:   name,
+		width:  width,
+		height: height,
+		length: length,
+		blocks: make([]byte, int(width)*int(height)*int(length)),
+		spawn:  Position{X: float32(width) / 2, Y: float32(height) / 2, Z: float32(length) / 2},
+		Extra:  make(nbt.Compound),
+	}
+	level.behaviors.Store(NewBehaviors())
+	level.settings.Store(NewSettings())
+	rand.Read(level.uuid[:])
+	// Version 4, variant 1
+	level.uuid[6] = level.uuid[6]&0x0f | 0x40
+	level.uuid[8] = level.uuid[8]&0x3f | 0x80
+	level.compression.init(len(level.blocks) + LENGTH_PREFIX_SIZE)
+	level.fallbackCompression.init(len(level.blocks) + LENGTH_PREFIX_SIZE)
+	level.fallbackCompression.fallbacks = true
+	return level, nil
+}
+
+// Blocks are ordered by Y, then Z, then X, and are copied
+func NewLevelFromBlocks(name string, width, height, length int16, blocks []byte) (*Level, error) {
+	level, err := NewLevel(name, width, height, length)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) != len(level.blocks) {
+		return nil, cerror.NewErrorf(LEVEL_BLOCK_COUNT_MISMATCH, "Expected %d blocks for a %dx%dx%d level, got %d", len(level.blocks), width, height, length, len(blocks))
+	}
+	copy(level.blocks, blocks)
+	return level, nil
+}
+
+// Grass on top of dirt up to half the height, with bedrock at the bottom
+func NewFlatLevel(name string, width, height, length int16) (*Level, error) {
+	level, err := NewLevel(name, width, height, length)
+	if err != nil {
+		return nil, err
+	}
+	ground := height / 2
+	layer := int(width) * int(length)
+	for y := range ground {
+		block := byte(BLOCK_DIRT)
+		switch y {
+		case 0:
+			block = BLOCK_BEDROCK
+		case ground - 1:
+			block = BLOCK_GRASS
+		}
+		start := int(y) * layer
+		for i := start; i < start+layer; i++ {
+			level.blocks[i] = block
+		}
+	}
+	level.spawn.Y = float32(ground) + PLAYER_HEIGHT
+	return level, nil
+}
@@ -0,0 +1,161 @@
+package world
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"sync"
+)
+
+const (
+	LIGHTING_MODE_CLIENT = iota // Left up to the client
+	LIGHTING_MODE_CLASSIC
+	LIGHTING_MODE_FANCY
+)
+
+// Parts of the environment clients can be given colors for
+const (
+	ENV_COLOR_SKY = iota
+	ENV_COLOR_CLOUD
+	ENV_COLOR_FOG
+	ENV_COLOR_AMBIENT
+	ENV_COLOR_SUNLIGHT
+	ENV_COLOR_COUNT
+)
+
+// Any component being -1 leaves the client's default color
+type EnvColor struct {
+	Red   int16 `json:"red"`
+	Green int16 `json:"green"`
+	Blue  int16 `json:"blue"`
+}
+
+var DEFAULT_ENV_COLOR = EnvColor{Red: -1, Green: -1, Blue: -1}
+
+func (color EnvColor) IsDefault() bool {
+	return color.Red < 0 || color.Green < 0 || color.Blue < 0
+}
+
+type MapAppearance struct {
+	// Texture pack, or empty for the client's own
+	TextureURL string `json:"texture_url"`
+	// Blocks shown past the sides of the level, and the water level they meet at
+	SideBlock byte  `json:"side_block"`
+	EdgeBlock byte  `json:"edge_block"`
+	SideLevel int16 `json:"side_level"`
+}
+
+type Environment struct {
+	// Indexed by ENV_COLOR_*
+	Colors [ENV_COLOR_COUNT]EnvColor `json:"colors"`
+	// Nil leaves the client's default appearance
+	Appearance *MapAppearance `json:"appearance,omitempty"`
+}
+
+func NewEnvironment() *Environment {
+	environment := &Environment{}
+	for i := range environment.Colors {
+		environment.Colors[i] = DEFAULT_ENV_COLOR
+	}
+	return environment
+}
+
+func (environment *Environment) Clone() *Environment {
+	clone := *environment
+	if environment.Appearance != nil {
+		appearance := *environment.Appearance
+		clone.Appearance = &appearance
+	}
+	return &clone
+}
+
+type Settings struct {
+	mutex          sync.RWMutex
+	LightingMode   byte `json:"lighting_mode"`
+	LightingLocked bool `json:"lighting_locked"`
+	// Removed from the inventory of clients supporting InventoryOrder
+	HiddenBlocks []byte `json:"hidden_blocks"`
+	// Nil until one is set, which leaves everything to the client
+	Env *Environment `json:"environment,omitempty"`
+}
+
+func (settings *Settings) Lighting() (mode byte, locked bool) {
+	settings.mutex.RLock()
+	defer settings.mutex.RUnlock()
+	return settings.LightingMode, settings.LightingLocked
+}
+
+func (settings *Settings) SetLighting(mode byte, locked bool) {
+	settings.mutex.Lock()
+	defer settings.mutex.Unlock()
+	settings.LightingMode = mode
+	settings.LightingLocked = locked
+}
+
+func (settings *Settings) Hidden() []byte {
+	settings.mutex.RLock()
+	defer settings.mutex.RUnlock()
+	return slices.Clone(settings.HiddenBlocks)
+}
+
+// Returns whether anything changed
+func (settings *Settings) SetHidden(block byte, hidden bool) bool {
+	settings.mutex.Lock()
+	defer settings.mutex.Unlock()
+	index := slices.Index(settings.HiddenBlocks, block)
+	if hidden == (index != -1) {
+		return false
+	}
+	if hidden {
+		settings.HiddenBlocks = append(settings.HiddenBlocks, block)
+	} else {
+		settings.HiddenBlocks = slices.Delete(settings.HiddenBlocks, index, index+1)
+	}
+	return true
+}
+
+// Copy of the environment, or nil if none has been set
+func (settings *Settings) Environment() *Environment {
+	settings.mutex.RLock()
+	defer settings.mutex.RUnlock()
+	if settings.Env == nil {
+		return nil
+	}
+	return settings.Env.Clone()
+}
+
+// The environment is copied, and may be nil to leave everything to the client
+func (settings *Settings) SetEnvironment(environment *Environment) {
+	settings.mutex.Lock()
+	defer settings.mutex.Unlock()
+	if environment != nil {
+		environment = environment.Clone()
+	}
+	settings.Env = environment
+}
+
+func (settings *Settings) Save(path string) error {
+	settings.mutex.RLock()
+	data, err := json.MarshalIndent(settings, "", "\t")
+	settings.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func LoadSettings(path string) (*Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := NewSettings()
+	if err := json.Unmarshal(data, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func NewSettings() *Settings {
+	return &Settings{}
+}